@@ -0,0 +1,69 @@
+/*
+管理接口，用于查看和失效本地缓存
+*/
+package ccache
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const defaultAdminPath = "/_ccache/admin/"
+
+// AdminHandler 管理接口
+//
+//	GET    /_ccache/admin/<group>/keys?prefix=<prefix>  列出本地缓存中以prefix开头的key
+//	DELETE /_ccache/admin/<group>/keys?prefix=<prefix>  失效本地缓存中以prefix开头的key
+type AdminHandler struct {
+	basePath string
+	registry *Registry
+}
+
+// NewAdminHandler create an admin handler for DefaultRegistry, 需挂载在 /_ccache/admin/ 路径下
+func NewAdminHandler() *AdminHandler {
+	return NewAdminHandlerWithRegistry(DefaultRegistry)
+}
+
+// NewAdminHandlerWithRegistry create an admin handler for registry
+func NewAdminHandlerWithRegistry(registry *Registry) *AdminHandler {
+	return &AdminHandler{basePath: defaultAdminPath, registry: registry}
+}
+
+func (a *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, a.basePath) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	// 假设请求路径是 /<basePath>/<groupname>/keys
+	parts := strings.SplitN(r.URL.Path[len(a.basePath):], "/", 2)
+	if len(parts) != 2 || parts[1] != "keys" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	group := a.registry.GetGroup(parts[0])
+	if group == nil {
+		http.Error(w, "No such group", http.StatusNotFound)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"keys": group.ListPrefix(prefix)})
+	case http.MethodDelete:
+		writeJSON(w, map[string]interface{}{"removed": group.RemovePrefix(prefix)})
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,66 @@
+package ccache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixScan(t *testing.T) {
+	group := NewGroup("prefix", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	for _, key := range []string{"user:2", "user:1", "order:1"} {
+		_, err := group.Get(key)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, []string{"user:1", "user:2"}, group.ListPrefix("user:"))
+	assert.Equal(t, []string{"order:1", "user:1", "user:2"}, group.ListPrefix(""))
+
+	keys := make([]string, 0)
+	group.Range(func(key string, value ByteView) bool {
+		assert.Equal(t, key, value.String())
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []string{"order:1", "user:1", "user:2"}, keys)
+
+	assert.Equal(t, 2, group.RemovePrefix("user:"))
+	assert.Equal(t, []string{"order:1"}, group.ListPrefix(""))
+}
+
+func TestAdminHandler(t *testing.T) {
+	group := NewGroup("admin", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	_, _ = group.Get("a:1")
+	_, _ = group.Get("b:1")
+
+	srv := httptest.NewServer(NewAdminHandler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/_ccache/admin/admin/keys?prefix=a:")
+	assert.Nil(t, err)
+	var listed struct{ Keys []string }
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&listed))
+	res.Body.Close()
+	assert.Equal(t, []string{"a:1"}, listed.Keys)
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/_ccache/admin/admin/keys?prefix=a:", nil)
+	res, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	var removed struct{ Removed int }
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&removed))
+	res.Body.Close()
+	assert.Equal(t, 1, removed.Removed)
+	assert.Equal(t, []string{"b:1"}, group.ListPrefix(""))
+
+	res, err = http.Get(srv.URL + "/_ccache/admin/missing/keys")
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,76 @@
+/*
+本地缓存的准入控制：限制单个条目的大小、缓存已满时按访问频率决定是否替换旧条目，
+以及由数据源标记不写入缓存的结果
+*/
+package ccache
+
+import "errors"
+
+// ErrEntryTooLarge Set写入的值超过单个条目的大小限制
+var ErrEntryTooLarge = errors.New("ccache: entry too large")
+
+// AdmissionPolicy 缓存已满时决定新条目能否替换最久未使用的条目，可使用ccache/tinylfu
+type AdmissionPolicy interface {
+	// Record 每次访问key时调用
+	Record(key string)
+	// Admit 写入key需要淘汰victim时调用，淘汰多个条目时对每个条目调用，任一返回false时不写入
+	Admit(key, victim string) bool
+}
+
+// Hint 数据源对加载结果的提示
+type Hint struct {
+	// 结果只返回给本次的调用方，不写入缓存
+	NoCache bool
+}
+
+// HintGetter 可以返回Hint的Getter，Group加载时优先调用GetWithHint
+type HintGetter interface {
+	Getter
+	GetWithHint(key string) ([]byte, Hint, error)
+}
+
+// HintGetterFunc callback func with hint
+type HintGetterFunc func(key string) ([]byte, Hint, error)
+
+// Get callback
+func (f HintGetterFunc) Get(key string) ([]byte, error) {
+	b, _, err := f(key)
+	return b, err
+}
+
+// GetWithHint callback
+func (f HintGetterFunc) GetWithHint(key string) ([]byte, Hint, error) {
+	return f(key)
+}
+
+// admitResult 写入缓存的结果
+type admitResult int
+
+const (
+	admitted admitResult = iota
+	rejectedTooLarge
+	rejectedByPolicy
+)
+
+// tooLarge 单个条目超过大小限制或缓存总容量，调用方需持有锁
+func (c *cache) tooLarge(key string, value ByteView) bool {
+	size := int64(len(key)) + int64(value.Len())
+	return (c.maxEntryBytes > 0 && size > c.maxEntryBytes) || (c.cacheBytes > 0 && size > c.cacheBytes)
+}
+
+// admit 判断新条目能否写入，调用方需持有锁
+func (c *cache) admit(key string, value ByteView) admitResult {
+	if c.tooLarge(key, value) {
+		return rejectedTooLarge
+	}
+	if c.admission == nil || c.cacheBytes == 0 {
+		return admitted
+	}
+	// 新条目较大时会淘汰多个条目，需要比每个被淘汰条目的访问频率都高
+	for _, victim := range c.lru.Victims(int64(len(key)) + int64(value.Len())) {
+		if !c.admission.Admit(key, victim) {
+			return rejectedByPolicy
+		}
+	}
+	return admitted
+}
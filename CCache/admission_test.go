@@ -0,0 +1,134 @@
+package ccache
+
+import (
+	"ccache/tinylfu"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxEntryBytes(t *testing.T) {
+	var loads int64
+	group := NewGroupWithOpts("admission-size", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt64(&loads, 1)
+		if key == "big" {
+			return []byte(strings.Repeat("x", 100)), nil
+		}
+		return []byte("small"), nil
+	}), GroupOptions{MaxEntryBytes: 64})
+
+	for i := 0; i < 2; i++ {
+		v, err := group.Get("big")
+		assert.Nil(t, err)
+		assert.Equal(t, 100, v.Len())
+		_, err = group.Get("small")
+		assert.Nil(t, err)
+	}
+	// 超过大小限制的值每次都从数据源加载
+	assert.Equal(t, int64(3), atomic.LoadInt64(&loads))
+	assert.Equal(t, int64(2), group.Stats().RejectedTooLarge)
+
+	_, err := group.Set("big", make([]byte, 100))
+	assert.Equal(t, ErrEntryTooLarge, err)
+}
+
+func TestAdmissionPolicy(t *testing.T) {
+	// 容量只够存放两个条目
+	group := NewGroupWithOpts("admission-lfu", 20, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	}), GroupOptions{Admission: tinylfu.New(16)})
+
+	for i := 0; i < 3; i++ {
+		group.Get("hot1")
+		group.Get("hot2")
+	}
+	// 只访问过一次的key不能替换热点数据
+	_, err := group.Get("cold")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), group.Stats().RejectedByPolicy)
+	assert.ElementsMatch(t, []string{"hot1", "hot2"}, group.ListPrefix(""))
+
+	// 未写入缓存的值没有版本
+	v, err := group.Get("cold")
+	assert.Nil(t, err)
+	assert.Zero(t, v.Version())
+
+	// 访问频率超过淘汰对象后写入
+	for i := 0; i < 5; i++ {
+		group.Get("cold")
+	}
+	assert.Contains(t, group.ListPrefix(""), "cold")
+}
+
+func TestAdmissionPolicyVictims(t *testing.T) {
+	// 容量可以存放三个小条目或一个大条目
+	group := NewGroupWithOpts("admission-victims", 30, GetterFunc(func(key string) ([]byte, error) {
+		if key == "big" {
+			return []byte(strings.Repeat("x", 17)), nil
+		}
+		return []byte("value"), nil
+	}), GroupOptions{Admission: tinylfu.New(16)})
+
+	group.Get("cold")
+	for i := 0; i < 3; i++ {
+		group.Get("hot1")
+		group.Get("hot2")
+	}
+	for i := 0; i < 2; i++ {
+		group.Get("big")
+	}
+	// big的访问频率高于最久未使用的cold，但写入时还需要淘汰hot1
+	assert.Equal(t, int64(2), group.Stats().RejectedByPolicy)
+	assert.ElementsMatch(t, []string{"cold", "hot1", "hot2"}, group.ListPrefix(""))
+}
+
+func TestHintNoCache(t *testing.T) {
+	var loads int64
+	group := NewGroup("admission-hint", 2<<10, HintGetterFunc(func(key string) ([]byte, Hint, error) {
+		atomic.AddInt64(&loads, 1)
+		return []byte("v"), Hint{NoCache: key == "volatile"}, nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		v, err := group.Get("volatile")
+		assert.Nil(t, err)
+		assert.Equal(t, "v", v.String())
+		assert.Zero(t, v.Version())
+	}
+	assert.Equal(t, int64(3), atomic.LoadInt64(&loads))
+	assert.Equal(t, int64(3), group.Stats().NotCached)
+	assert.Empty(t, group.ListPrefix(""))
+}
+
+func TestHintNoCacheRemote(t *testing.T) {
+	var loads int64
+	// 所属节点使用独立的Registry，与本节点的同名group隔离
+	registry := NewRegistry()
+	_, err := registry.NewGroup("admission-remote", 2<<10, HintGetterFunc(func(key string) ([]byte, Hint, error) {
+		atomic.AddInt64(&loads, 1)
+		return []byte("v"), Hint{NoCache: true}, nil
+	}), GroupOptions{})
+	assert.Nil(t, err)
+	srv := httptest.NewServer(NewHTTPPoolWithOpts("owner", HTTPPoolOptions{Registry: registry}))
+	defer srv.Close()
+
+	group := NewGroup("admission-remote", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		t.Fatal("should load from owner")
+		return nil, nil
+	}))
+	group.RegisterPeers(&fixedPicker{peer: &httpGetter{baseURL: srv.URL + defaultBasePath, client: http.DefaultClient}})
+
+	for i := 0; i < 2; i++ {
+		v, err := group.Get("volatile")
+		assert.Nil(t, err)
+		assert.Equal(t, "v", v.String())
+	}
+	// 未缓存的值不会进入远程值缓存，也不会被304确认
+	assert.Equal(t, int64(2), atomic.LoadInt64(&loads))
+	_, ok := group.hotCache.get("volatile")
+	assert.False(t, ok)
+}
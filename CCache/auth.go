@@ -0,0 +1,260 @@
+/*
+节点间通信的安全配置：TLS/mTLS 与 HMAC 请求签名
+*/
+package ccache
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	authKeyIDHeader     = "X-Ccache-Key-Id"
+	authTimestampHeader = "X-Ccache-Timestamp"
+	authSignatureHeader = "X-Ccache-Signature"
+	authNonceHeader     = "X-Ccache-Nonce"
+
+	defaultMaxSkew = 5 * time.Minute
+)
+
+var (
+	errMissingSignature = errors.New("missing request signature")
+	errUnknownKey       = errors.New("unknown signing key")
+	errBadSignature     = errors.New("invalid request signature")
+	errExpiredSignature = errors.New("request signature expired")
+	errReplayedRequest  = errors.New("request nonce already used")
+	errClientCert       = errors.New("client certificate required")
+)
+
+// TLSOptions 节点间通信的TLS配置
+type TLSOptions struct {
+	// 本节点证书，作为服务端证书；开启mTLS时同时作为客户端证书
+	Certificate tls.Certificate
+	// 用于校验对端证书的CA，为nil时使用系统CA
+	RootCAs *x509.CertPool
+	// 要求对端提供由RootCAs签发的客户端证书（mTLS）
+	RequireClientCert bool
+}
+
+// LoadTLSOptions 从PEM文件加载TLS配置，caFile为空时使用系统CA
+func LoadTLSOptions(certFile, keyFile, caFile string, mutual bool) (*TLSOptions, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load key pair: %v", err)
+	}
+	opts := &TLSOptions{Certificate: cert, RequireClientCert: mutual}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %v", err)
+		}
+		opts.RootCAs = x509.NewCertPool()
+		if !opts.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	return opts, nil
+}
+
+// ServerConfig 用于http.Server的TLS配置
+func (o *TLSOptions) ServerConfig() *tls.Config {
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{o.Certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if o.RequireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = o.RootCAs
+	}
+	return cfg
+}
+
+// ClientConfig 用于访问其他节点的TLS配置
+func (o *TLSOptions) ClientConfig() *tls.Config {
+	cfg := &tls.Config{
+		RootCAs:    o.RootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	if o.RequireClientCert {
+		cfg.Certificates = []tls.Certificate{o.Certificate}
+	}
+	return cfg
+}
+
+// HMACAuth 基于共享密钥的请求签名
+// 签名覆盖方法、路径、查询参数、请求体的SHA-256、时间戳与随机nonce
+// MaxSkew内重复使用的nonce被拒绝，防止重放截获的请求
+// 签名使用当前密钥，校验时接受所有未下线的密钥，轮换时先在所有节点Rotate，再Retire旧密钥
+type HMACAuth struct {
+	mu      sync.RWMutex // guards
+	current string
+	keys    map[string][]byte
+	// 允许的时钟偏差，默认5分钟
+	MaxSkew time.Duration
+	now     func() time.Time
+
+	nonceMu sync.Mutex // guards nonces and swept
+	// 已使用的nonce及其时间戳，超过MaxSkew后清理
+	nonces map[string]time.Time
+	swept  time.Time
+}
+
+// NewHMACAuth create a signer with initial key
+func NewHMACAuth(id string, secret []byte) *HMACAuth {
+	return &HMACAuth{
+		current: id,
+		keys:    map[string][]byte{id: secret},
+		MaxSkew: defaultMaxSkew,
+		now:     time.Now,
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+// Rotate 添加新密钥并用于之后的签名，旧密钥仍可用于校验
+func (a *HMACAuth) Rotate(id string, secret []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys[id] = secret
+	a.current = id
+}
+
+// Retire 下线密钥，使用该密钥签名的请求将被拒绝；不能下线当前密钥
+func (a *HMACAuth) Retire(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if id != a.current {
+		delete(a.keys, id)
+	}
+}
+
+// Sign 为请求添加签名头，请求体会被读取后替换为相同内容
+func (a *HMACAuth) Sign(r *http.Request) error {
+	a.mu.RLock()
+	id, secret := a.current, a.keys[a.current]
+	a.mu.RUnlock()
+
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(a.now().Unix(), 10)
+	r.Header.Set(authKeyIDHeader, id)
+	r.Header.Set(authTimestampHeader, ts)
+	r.Header.Set(authNonceHeader, hex.EncodeToString(nonce))
+	r.Header.Set(authSignatureHeader, signature(secret, r, id, ts, r.Header.Get(authNonceHeader), body))
+	return nil
+}
+
+// Verify 校验请求签名，请求体会被读取后替换为相同内容
+func (a *HMACAuth) Verify(r *http.Request) error {
+	id := r.Header.Get(authKeyIDHeader)
+	ts := r.Header.Get(authTimestampHeader)
+	nonce := r.Header.Get(authNonceHeader)
+	sig := r.Header.Get(authSignatureHeader)
+	if id == "" || ts == "" || nonce == "" || sig == "" {
+		return errMissingSignature
+	}
+
+	a.mu.RLock()
+	secret, ok := a.keys[id]
+	a.mu.RUnlock()
+	if !ok {
+		return errUnknownKey
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errBadSignature
+	}
+	now := a.now()
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew > a.MaxSkew || skew < -a.MaxSkew {
+		return errExpiredSignature
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return errBadSignature
+	}
+	expected := signature(secret, r, id, ts, nonce, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return errBadSignature
+	}
+	// 签名通过后才记录nonce，未签名的请求无法占用nonce
+	return a.useNonce(id+"/"+nonce, time.Unix(unix, 0), now)
+}
+
+// useNonce 记录nonce，MaxSkew内重复出现时返回errReplayedRequest
+func (a *HMACAuth) useNonce(nonce string, ts, now time.Time) error {
+	a.nonceMu.Lock()
+	defer a.nonceMu.Unlock()
+	// 时间戳超出MaxSkew的请求已被拒绝，对应的nonce无需保留
+	if now.Sub(a.swept) > a.MaxSkew {
+		for n, t := range a.nonces {
+			if now.Sub(t) > a.MaxSkew {
+				delete(a.nonces, n)
+			}
+		}
+		a.swept = now
+	}
+	if _, ok := a.nonces[nonce]; ok {
+		return errReplayedRequest
+	}
+	a.nonces[nonce] = ts
+	return nil
+}
+
+// readBody 读取请求体并替换为可重复读取的副本
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+func signature(secret []byte, r *http.Request, id, ts, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(r.Method + "\n" + r.URL.EscapedPath() + "\n" + r.URL.RawQuery + "\n" +
+		id + "\n" + ts + "\n" + nonce + "\n" + hex.EncodeToString(sum[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticate 校验来自其他节点的请求
+func (p *HTTPPool) authenticate(r *http.Request) error {
+	if p.opts.TLS != nil && p.opts.TLS.RequireClientCert {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return errClientCert
+		}
+	}
+	if p.opts.Auth != nil {
+		return p.opts.Auth.Verify(r)
+	}
+	return nil
+}
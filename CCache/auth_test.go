@@ -0,0 +1,192 @@
+package ccache
+
+import (
+	"ccache/ccachepb"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCA 测试用的本地CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ccache test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue 签发同时可用于服务端和客户端的证书
+func (ca *testCA) issue(t *testing.T, serial int64) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "ccache peer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func newSecureGroup(name string) {
+	NewGroup(name, 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+}
+
+func TestMutualTLS(t *testing.T) {
+	newSecureGroup("mtls")
+	ca := newTestCA(t)
+	server := NewHTTPPoolWithOpts("server", HTTPPoolOptions{
+		TLS: &TLSOptions{Certificate: ca.issue(t, 2), RootCAs: ca.pool, RequireClientCert: true},
+	})
+	srv := httptest.NewUnstartedServer(server)
+	srv.TLS = server.ServerTLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := NewHTTPPoolWithOpts("client", HTTPPoolOptions{
+		TLS: &TLSOptions{Certificate: ca.issue(t, 3), RootCAs: ca.pool, RequireClientCert: true},
+	})
+	client.Set(srv.URL)
+	req := &ccachepb.Request{Group: "mtls", Key: "key"}
+	res, err := client.httpGetters[srv.URL].Get(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, "key", string(res.GetValue()))
+
+	// 没有客户端证书的请求在握手阶段被拒绝
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.pool}}}
+	_, err = (&httpGetter{baseURL: srv.URL + defaultBasePath, client: anonymous}).Get(context.Background(), req)
+	assert.NotNil(t, err)
+
+	// 其他CA签发的客户端证书同样被拒绝
+	other := newTestCA(t)
+	forged := NewHTTPPoolWithOpts("forged", HTTPPoolOptions{
+		TLS: &TLSOptions{Certificate: other.issue(t, 4), RootCAs: ca.pool, RequireClientCert: true},
+	})
+	forged.Set(srv.URL)
+	_, err = forged.httpGetters[srv.URL].Get(context.Background(), req)
+	assert.NotNil(t, err)
+}
+
+func TestHMACAuth(t *testing.T) {
+	newSecureGroup("hmac")
+	serverAuth := NewHMACAuth("k1", []byte("secret-1"))
+	server := NewHTTPPoolWithOpts("server", HTTPPoolOptions{Auth: serverAuth})
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	clientAuth := NewHMACAuth("k1", []byte("secret-1"))
+	client := NewHTTPPoolWithOpts("client", HTTPPoolOptions{Auth: clientAuth})
+	client.Set(srv.URL)
+	getter := client.httpGetters[srv.URL]
+	req := &ccachepb.Request{Group: "hmac", Key: "key"}
+
+	_, err := getter.Get(context.Background(), req)
+	assert.Nil(t, err)
+
+	// 未签名请求
+	res, err := http.Get(srv.URL + defaultBasePath + "hmac/key")
+	assert.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// 错误的密钥
+	wrong := &httpGetter{baseURL: srv.URL + defaultBasePath, client: http.DefaultClient, auth: NewHMACAuth("k1", []byte("guess"))}
+	_, err = wrong.Get(context.Background(), req)
+	assert.NotNil(t, err)
+
+	// 密钥轮换：服务端先接受新密钥，客户端切换后下线旧密钥
+	serverAuth.Rotate("k2", []byte("secret-2"))
+	_, err = getter.Get(context.Background(), req)
+	assert.Nil(t, err)
+	clientAuth.Rotate("k2", []byte("secret-2"))
+	serverAuth.Retire("k1")
+	_, err = getter.Get(context.Background(), req)
+	assert.Nil(t, err)
+
+	stale := &httpGetter{baseURL: srv.URL + defaultBasePath, client: http.DefaultClient, auth: NewHMACAuth("k1", []byte("secret-1"))}
+	_, err = stale.Get(context.Background(), req)
+	assert.NotNil(t, err)
+}
+
+func TestHMACAuthExpired(t *testing.T) {
+	auth := NewHMACAuth("k1", []byte("secret"))
+	r, _ := http.NewRequest(http.MethodGet, "http://peer/ccache/g/k", nil)
+	auth.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	assert.Nil(t, auth.Sign(r))
+	auth.now = time.Now
+	assert.Equal(t, errExpiredSignature, auth.Verify(r))
+
+	r.Header.Del(authSignatureHeader)
+	assert.Equal(t, errMissingSignature, auth.Verify(r))
+}
+
+func TestHMACAuthTampered(t *testing.T) {
+	auth := NewHMACAuth("k1", []byte("secret"))
+	sign := func(target, body string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPut, target, strings.NewReader(body))
+		assert.Nil(t, auth.Sign(r))
+		return r
+	}
+
+	// 签名后请求体仍可被读取
+	r := sign("http://peer/ccache/g/k?lease=1", "value")
+	assert.Nil(t, auth.Verify(r))
+	b, _ := ioutil.ReadAll(r.Body)
+	assert.Equal(t, "value", string(b))
+
+	// 篡改请求体
+	r = sign("http://peer/ccache/g/k", "value")
+	r.Body = ioutil.NopCloser(strings.NewReader("forged"))
+	assert.Equal(t, errBadSignature, auth.Verify(r))
+
+	// 篡改查询参数
+	r = sign("http://peer/ccache/g/k?lease=1", "")
+	r.URL.RawQuery = "lease=2"
+	assert.Equal(t, errBadSignature, auth.Verify(r))
+
+	// 重放已校验过的请求
+	r = sign("http://peer/ccache/g/k", "value")
+	assert.Nil(t, auth.Verify(r))
+	assert.Equal(t, errReplayedRequest, auth.Verify(r))
+}
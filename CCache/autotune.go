@@ -0,0 +1,105 @@
+/*
+根据命中率与内存压力自动调整本地缓存容量
+*/
+package ccache
+
+import (
+	"runtime"
+	"time"
+)
+
+// AutoTuneOptions 自动调整容量的配置
+type AutoTuneOptions struct {
+	// 目标命中率，命中率低于该值且缓存已满时扩容
+	TargetHitRate float64
+	// 容量调整范围，缩容不会低于1字节
+	MinBytes int64
+	MaxBytes int64
+	// 堆内存超过该值时缩容，为0时不检查内存压力
+	MaxHeapBytes uint64
+	// 每次调整的比例，默认0.1
+	Step float64
+	// 检查间隔，默认10秒
+	Interval time.Duration
+}
+
+// tuneInput 一个检查周期内的统计
+type tuneInput struct {
+	hits, misses   int64
+	used, capacity int64
+	heapBytes      uint64
+}
+
+// nextCapacity 计算下一个周期的容量，内存压力优先于命中率；不设限的缓存不做调整
+func (o AutoTuneOptions) nextCapacity(in tuneInput) int64 {
+	if in.capacity == 0 {
+		return 0
+	}
+	step := int64(float64(in.capacity) * o.Step)
+	if step == 0 {
+		step = 1
+	}
+	next := in.capacity
+	switch {
+	case o.MaxHeapBytes > 0 && in.heapBytes > o.MaxHeapBytes:
+		next = in.capacity - step
+	case in.hits+in.misses == 0:
+	case float64(in.hits)/float64(in.hits+in.misses) < o.TargetHitRate && in.used >= in.capacity-step:
+		// 缓存未满时命中率低说明是冷启动或访问分散，扩容无济于事
+		next = in.capacity + step
+	}
+	if next < o.MinBytes {
+		next = o.MinBytes
+	}
+	if o.MaxBytes > 0 && next > o.MaxBytes {
+		next = o.MaxBytes
+	}
+	// 容量为0表示不设限，缩容时至少保留1字节
+	if next < 1 {
+		next = 1
+	}
+	return next
+}
+
+// AutoTune 启动后台协程周期性地调整容量，返回的函数用于停止
+func (g *Group) AutoTune(opts AutoTuneOptions) (stop func()) {
+	if opts.Step <= 0 {
+		opts.Step = 0.1
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		last := g.Stats()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			stats := g.Stats()
+			used, capacity := g.mainCache.usage()
+			in := tuneInput{
+				hits:     stats.Hits - last.Hits,
+				misses:   stats.Misses - last.Misses,
+				used:     used,
+				capacity: capacity,
+			}
+			last = stats
+			if opts.MaxHeapBytes > 0 {
+				var m runtime.MemStats
+				runtime.ReadMemStats(&m)
+				in.heapBytes = m.HeapAlloc
+			}
+			if next := opts.nextCapacity(in); next != capacity {
+				g.logger.Info("resize cache", "group", g.name, "from", capacity, "to", next)
+				g.SetCapacity(next)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,73 @@
+package ccache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCapacity(t *testing.T) {
+	evicted := make([]string, 0)
+	group := NewGroupWithOpts("capacity", 0, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("vv"), nil
+	}), GroupOptions{OnEvicted: func(key string, value ByteView) {
+		evicted = append(evicted, key)
+	}})
+	for i := 0; i < 4; i++ {
+		_, _ = group.Get("k" + strconv.Itoa(i))
+	}
+	assert.Empty(t, evicted)
+
+	// 每个条目占用4字节，缩容到8字节后淘汰最久未使用的两个
+	group.SetCapacity(8)
+	assert.Equal(t, int64(8), group.Capacity())
+	assert.Equal(t, []string{"k0", "k1"}, evicted)
+	assert.Equal(t, []string{"k2", "k3"}, group.ListPrefix(""))
+
+	_, _ = group.Get("k2")
+	assert.Equal(t, Stats{Hits: 1, Misses: 4}, group.Stats())
+}
+
+func TestNextCapacity(t *testing.T) {
+	opts := AutoTuneOptions{TargetHitRate: 0.9, MinBytes: 100, MaxBytes: 1000, MaxHeapBytes: 1 << 20, Step: 0.1}
+	cases := []struct {
+		name string
+		in   tuneInput
+		want int64
+	}{
+		{"low hit rate and full", tuneInput{hits: 50, misses: 50, used: 500, capacity: 500}, 550},
+		{"low hit rate but not full", tuneInput{hits: 50, misses: 50, used: 100, capacity: 500}, 500},
+		{"target reached", tuneInput{hits: 95, misses: 5, used: 500, capacity: 500}, 500},
+		{"no traffic", tuneInput{used: 500, capacity: 500}, 500},
+		{"memory pressure", tuneInput{hits: 50, misses: 50, used: 500, capacity: 500, heapBytes: 2 << 20}, 450},
+		{"capped by max", tuneInput{hits: 0, misses: 10, used: 1000, capacity: 1000}, 1000},
+		{"capped by min", tuneInput{capacity: 100, heapBytes: 2 << 20}, 100},
+		{"unlimited", tuneInput{hits: 0, misses: 10}, 0},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, opts.nextCapacity(c.in), c.name)
+	}
+	// MinBytes为0时持续的内存压力不会把容量降为0（不设限）
+	opts.MinBytes = 0
+	assert.Equal(t, int64(1), opts.nextCapacity(tuneInput{capacity: 1, heapBytes: 2 << 20}))
+}
+
+func TestAutoTune(t *testing.T) {
+	group := NewGroup("autotune", 8, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("vv"), nil
+	}))
+	stop := group.AutoTune(AutoTuneOptions{TargetHitRate: 0.9, MaxBytes: 64, Step: 0.5, Interval: 5 * time.Millisecond})
+	defer stop()
+
+	// 访问的key多于缓存容量，命中率低，容量逐步增大
+	deadline := time.Now().Add(time.Second)
+	for group.Capacity() <= 8 && time.Now().Before(deadline) {
+		for i := 0; i < 8; i++ {
+			_, _ = group.Get("k" + strconv.Itoa(i))
+		}
+	}
+	assert.Greater(t, group.Capacity(), int64(8))
+	assert.LessOrEqual(t, group.Capacity(), int64(64))
+}
@@ -3,8 +3,20 @@
 */
 package ccache
 
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
 type ByteView struct {
 	b []byte
+	// 所属节点写入缓存时分配的版本，用于CompareAndSet与条件请求
+	version uint64
+	// 所属节点从数据源加载或写入的时间
+	loadedAt time.Time
 }
 
 func (bv ByteView) Len() int {
@@ -15,13 +27,67 @@ func (bv ByteView) String() string {
 	return string(bv.b)
 }
 
+// ByteSlice 返回值的拷贝，只读时使用WriteTo或Reader避免拷贝
 func (bv ByteView) ByteSlice() []byte {
 	return cloneBytes(bv.b)
 }
 
+// WriteTo 将值直接写入w而不拷贝，实现io.WriterTo
+func (bv ByteView) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(bv.b)
+	return int64(n), err
+}
+
+// Reader 返回值的只读视图，不拷贝底层数据，可用于http.ServeContent等需要io.ReadSeeker的场景
+func (bv ByteView) Reader() *bytes.Reader {
+	return bytes.NewReader(bv.b)
+}
+
+// Version 缓存值的版本，每次从数据源加载或CompareAndSet成功后都会变化
+func (bv ByteView) Version() uint64 {
+	return bv.version
+}
+
+// LoadedAt 所属节点从数据源加载或写入该值的时间，未知时为零值
+func (bv ByteView) LoadedAt() time.Time {
+	return bv.loadedAt
+}
+
+// ETag 版本对应的HTTP实体标签，形如 "17f0c3a2"
+func (bv ByteView) ETag() string {
+	return etag(bv.version)
+}
+
+func etag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 16) + `"`
+}
+
 // 对缓存值进行拷贝，防止返回后外部对其有控制权
 func cloneBytes(b []byte) []byte {
 	c := make([]byte, len(b))
 	copy(c, b)
 	return c
 }
+
+// 超过该大小的缓冲区用完后直接丢弃，避免池中长期持有大块内存
+const maxPooledBuffer = 4 << 20
+
+// bufferPool 节点间响应序列化与读取复用的缓冲区
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 4<<10)
+		return &b
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
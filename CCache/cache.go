@@ -5,24 +5,108 @@ package ccache
 
 import (
 	"ccache/lru"
+	"strings"
 	"sync"
 )
 
+// 遍历与批量删除时每次加锁处理的key数量，避免长时间持有锁
+const rangeBatch = 128
+
 type cache struct {
 	// 使用Mutex封装lru的方法
 	mu         sync.Mutex // guards
 	lru        *lru.Cache
 	cacheBytes int64
+	onEvicted  func(key string, value ByteView)
+	// 单个条目的最大字节数，为0时只受总容量限制
+	maxEntryBytes int64
+	admission     AdmissionPolicy
 }
 
 func (c *cache) add(key string, value lru.Value) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
+	c.lazyInit()
+	c.lru.Add(key, value)
+}
+
+// addIfAbsent key不存在且通过准入检查时写入，返回缓存中最终的值
+// 避免从数据源加载的旧值覆盖加载期间CompareAndSet写入的新值
+func (c *cache) addIfAbsent(key string, value ByteView) (ByteView, admitResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lazyInit()
+	if v, ok := c.lru.Get(key); ok {
+		return v.(ByteView), admitted
+	}
+	result := c.admit(key, value)
+	if result == admitted {
+		c.lru.Add(key, value)
 	}
+	return value, result
+}
+
+// fits 条目未超过大小限制，Set写入前检查
+func (c *cache) fits(key string, value ByteView) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.tooLarge(key, value)
+}
 
+// compareAndSwap 当前版本等于expected时写入value，key不存在时当前版本视为0
+func (c *cache) compareAndSwap(key string, expected uint64, value ByteView) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lazyInit()
+	var current uint64
+	if v, ok := c.lru.Peek(key); ok {
+		current = v.(ByteView).version
+	}
+	if current != expected {
+		return false
+	}
 	c.lru.Add(key, value)
+	return true
+}
+
+// setCapacity 调整容量，超出部分立即淘汰
+func (c *cache) setCapacity(cacheBytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cacheBytes = cacheBytes
+	if c.lru != nil {
+		c.lru.SetMaxBytes(cacheBytes)
+	}
+}
+
+// usage 返回已使用容量与总容量
+func (c *cache) usage() (used, capacity int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru != nil {
+		used = c.lru.UsedBytes()
+	}
+	return used, c.cacheBytes
+}
+
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru != nil {
+		c.lru.Remove(key)
+	}
+}
+
+func (c *cache) lazyInit() {
+	if c.lru == nil {
+		var onEvicted func(string, lru.Value)
+		if c.onEvicted != nil {
+			onEvicted = func(key string, value lru.Value) {
+				c.onEvicted(key, value.(ByteView))
+			}
+		}
+		c.lru = lru.New(c.cacheBytes, onEvicted)
+	}
 }
 
 func (c *cache) get(key string) (value ByteView, ok bool) {
@@ -38,3 +122,67 @@ func (c *cache) get(key string) (value ByteView, ok bool) {
 
 	return v.(ByteView), true
 }
+
+func (c *cache) peek(key string) (value ByteView, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru == nil {
+		return
+	}
+	v, ok := c.lru.Peek(key)
+	if !ok {
+		return
+	}
+
+	return v.(ByteView), true
+}
+
+// keys 返回key快照，按最近访问到最久未访问排序
+func (c *cache) keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lru == nil {
+		return nil
+	}
+	return c.lru.Keys()
+}
+
+// rangeEntries 基于key快照遍历，fn在锁外执行；遍历期间被淘汰的key会被跳过
+func (c *cache) rangeEntries(fn func(key string, value ByteView) bool) {
+	for _, key := range c.keys() {
+		v, ok := c.peek(key)
+		if !ok {
+			continue
+		}
+		if !fn(key, v) {
+			return
+		}
+	}
+}
+
+// removePrefix 删除所有以prefix开头的key，返回删除数量
+func (c *cache) removePrefix(prefix string) int {
+	var matched []string
+	for _, key := range c.keys() {
+		if strings.HasPrefix(key, prefix) {
+			matched = append(matched, key)
+		}
+	}
+
+	removed := 0
+	for len(matched) > 0 {
+		n := rangeBatch
+		if n > len(matched) {
+			n = len(matched)
+		}
+		c.mu.Lock()
+		for _, key := range matched[:n] {
+			if c.lru.Remove(key) {
+				removed++
+			}
+		}
+		c.mu.Unlock()
+		matched = matched[n:]
+	}
+	return removed
+}
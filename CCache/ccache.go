@@ -3,14 +3,18 @@ package ccache
 import (
 	"ccache/ccachepb"
 	"ccache/singleflight"
-	"fmt"
-	"log"
-	"sync"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 )
 
 // Getter 缓存未命中时，获取源数据的回调函数，暴露给用户自定义，可定义多个适配器
+// 需要控制结果是否写入缓存时实现HintGetter
 type Getter interface {
 	Get(key string) ([]byte, error)
 }
@@ -25,57 +29,188 @@ type Group struct {
 	// callback when not hit cache
 	getter    Getter
 	mainCache cache
+	// 从远程节点获取的值，仅用于条件请求，每次使用前都会向所属节点确认版本
+	hotCache cache
+	// 数据源中不存在的key，未开启时为nil
+	negative  *negativeCache
 	peers     PeerPicker
 	loadGroup *singleflight.Group
+	observer  Observer
+	logger    Logger
+	// 最近分配的版本号
+	versions uint64
+	leases   *leaseTable
+	// 从所属节点获取失败时是否在本节点加载
+	peerFallback bool
+	// 所属租户，未指定租户时为nil
+	tenant *tenant
+	stats  Stats
 }
 
-var (
-	mu     sync.RWMutex
-	groups = make(map[string]*Group)
-)
+// Stats 本地缓存的命中与准入统计
+type Stats struct {
+	Hits   int64
+	Misses int64
+	// 超过单个条目大小限制而未写入的次数
+	RejectedTooLarge int64
+	// 被准入策略拒绝的次数
+	RejectedByPolicy int64
+	// 数据源提示不缓存的次数
+	NotCached int64
+	// 超过租户加载速率配额而未加载的次数
+	RateLimited int64
+	// 命中负缓存的次数
+	NegativeHits int64
+}
+
+// GroupOptions 可选配置
+type GroupOptions struct {
+	// 接收命中、未命中、加载、淘汰等事件
+	Observer Observer
+	// 默认使用标准库log
+	Logger Logger
+	// 从所属节点获取失败时，向所属节点申请租约后在本节点加载，默认直接返回错误
+	PeerFallback bool
+	// 本地缓存淘汰或删除key时调用，调用时持有缓存锁，不能在其中访问同一个Group
+	OnEvicted func(key string, value ByteView)
+	// 加载租约的有效期，默认2秒，持有者在有效期内未释放时其他加载方可重新获取
+	LeaseTTL time.Duration
+	// 单个条目（key与value）的最大字节数，超过时只返回给调用方而不写入缓存，默认只受缓存容量限制
+	MaxEntryBytes int64
+	// 缓存已满时的准入策略，默认总是淘汰最久未使用的条目
+	Admission AdmissionPolicy
+	// 所属租户，配额通过Registry.SetTenantQuota设置
+	Tenant string
+	// 数据源返回ErrNotFound后在该时长内直接返回ErrNotFound，默认不缓存不存在的key
+	NegativeTTL time.Duration
+}
+
+// ErrVersionMismatch CompareAndSet时缓存中的版本与期望版本不一致
+var ErrVersionMismatch = errors.New("ccache: version mismatch")
 
 // Get callback
 func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
-// NewGroup create a group
+// ProtoGetter 以protobuf消息作为源数据的Getter，Group加载时只序列化一次并直接缓存结果，不再额外拷贝
+type ProtoGetter interface {
+	Getter
+	GetProto(key string) (proto.Message, error)
+}
+
+// ProtoGetterFunc callback func returning a protobuf message
+type ProtoGetterFunc func(key string) (proto.Message, error)
+
+// Get callback
+func (f ProtoGetterFunc) Get(key string) ([]byte, error) {
+	m, err := f(key)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+// GetProto callback
+func (f ProtoGetterFunc) GetProto(key string) (proto.Message, error) {
+	return f(key)
+}
+
+// NewGroup create a group in DefaultRegistry
 func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+	return NewGroupWithOpts(name, cacheBytes, getter, GroupOptions{})
+}
+
+// NewGroupWithOpts create a group with options in DefaultRegistry，名称重复或超出配额时panic
+func NewGroupWithOpts(name string, cacheBytes int64, getter Getter, opts GroupOptions) *Group {
 	if getter == nil {
 		panic("nil getter")
 	}
-	mu.Lock()
-	defer mu.Unlock()
+	g, err := DefaultRegistry.NewGroup(name, cacheBytes, getter, opts)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+func newGroup(name string, cacheBytes int64, getter Getter, opts GroupOptions) *Group {
 	g := &Group{
 		name:      name,
 		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
+		mainCache: cache{cacheBytes: cacheBytes, maxEntryBytes: opts.MaxEntryBytes, admission: opts.Admission},
+		hotCache:  cache{cacheBytes: hotCacheBytes(cacheBytes)},
+		negative:  newNegativeCache(opts.NegativeTTL, hotCacheBytes(cacheBytes)),
 		loadGroup: &singleflight.Group{},
+		observer:  opts.Observer,
+		logger:    opts.Logger,
+		// 以启动时间为起点，避免重启后复用之前分配过的版本
+		versions:     uint64(time.Now().UnixNano()),
+		leases:       newLeaseTable(opts.LeaseTTL),
+		peerFallback: opts.PeerFallback,
+	}
+	if g.logger == nil {
+		g.logger = defaultLogger
+	}
+	if g.observer != nil || opts.OnEvicted != nil {
+		g.mainCache.onEvicted = func(key string, value ByteView) {
+			notify(g.observer, context.Background(), Event{Type: EventEviction, Group: name, Key: key})
+			if opts.OnEvicted != nil {
+				opts.OnEvicted(key, value)
+			}
+		}
 	}
-
-	groups[name] = g
 	return g
 }
 
-// GetGroup get a group
-func GetGroup(name string) *Group {
-	// read lock
-	mu.RLock()
-	defer mu.RUnlock()
+// hotCacheBytes 远程值缓存占用主缓存的1/8，主缓存不设限时同样不设限
+func hotCacheBytes(cacheBytes int64) int64 {
+	if cacheBytes > 0 && cacheBytes < 8 {
+		return 1
+	}
+	return cacheBytes / 8
+}
 
-	g := groups[name]
-	return g
+// GetGroup get a group from DefaultRegistry
+func GetGroup(name string) *Group {
+	return DefaultRegistry.GetGroup(name)
 }
 
 // Get value from cache if exists, else get value from other resources using callback function
 func (g *Group) Get(key string) (value ByteView, err error) {
-	viewi, err := g.loadGroup.Do(key, func() (interface{}, error) {
-		if v, ok := g.mainCache.get(key); ok {
-			log.Println("Cache hit")
-			return v, nil
-		}
-		return g.load(key)
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 同Get，ctx中的链路上下文会随请求传递到远程节点，ctx中没有时开启新的链路
+func (g *Group) GetContext(ctx context.Context, key string) (value ByteView, err error) {
+	ctx = ensureTrace(ctx)
+	start := time.Now()
+	if g.mainCache.admission != nil {
+		g.mainCache.admission.Record(key)
+	}
+	if v, ok := g.mainCache.get(key); ok {
+		g.logger.Debug("cache hit", "group", g.name, "key", key)
+		atomic.AddInt64(&g.stats.Hits, 1)
+		notify(g.observer, ctx, Event{Type: EventHit, Group: g.name, Key: key, Duration: time.Since(start)})
+		return v, nil
+	}
+	if g.negative.contains(key) {
+		atomic.AddInt64(&g.stats.NegativeHits, 1)
+		notify(g.observer, ctx, Event{Type: EventHit, Group: g.name, Key: key, Duration: time.Since(start), Err: ErrNotFound})
+		return ByteView{}, ErrNotFound
+	}
+	atomic.AddInt64(&g.stats.Misses, 1)
+	notify(g.observer, ctx, Event{Type: EventMiss, Group: g.name, Key: key, Duration: time.Since(start)})
+
+	// fn只会在发起加载的协程中执行，其余协程等待其结果
+	loaded := false
+	start = time.Now()
+	viewi, err, _ := g.loadGroup.Do(key, func() (interface{}, error) {
+		loaded = true
+		return g.load(ctx, key)
 	})
+	if !loaded {
+		notify(g.observer, ctx, Event{Type: EventWait, Group: g.name, Key: key, Duration: time.Since(start), Err: err})
+	}
 
 	if err == nil {
 		return viewi.(ByteView), err
@@ -85,31 +220,220 @@ func (g *Group) Get(key string) (value ByteView, err error) {
 
 }
 
-// 单机调用
-func (g *Group) getLocally(key string) (ByteView, error) {
-	b, err := g.getter.Get(key)
+// GetProto 获取值并反序列化到m，直接从缓存中的值解码而不拷贝
+func (g *Group) GetProto(ctx context.Context, key string, m proto.Message) error {
+	value, err := g.GetContext(ctx, key)
 	if err != nil {
-		return ByteView{}, err
+		return err
 	}
+	return proto.Unmarshal(value.b, m)
+}
 
-	value := ByteView{b: cloneBytes(b)}
-	// write cache
-	g.populateCache(key, value)
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	// 从远程节点获取值
+	if g.peers != nil {
+		var (
+			peer PeerGetter
+			ok   bool
+		)
+		if sp, isSelfPicker := g.peers.(selfPicker); isSelfPicker {
+			var done func()
+			peer, ok, done = sp.pickPeerOrSelf(key)
+			defer done()
+		} else {
+			peer, ok = g.peers.PickPeer(key)
+		}
+		if ok {
+			start := time.Now()
+			value, err = g.getFromPeer(ctx, peer, key)
+			notify(g.observer, ctx, Event{Type: EventPeerFetch, Group: g.name, Key: key, Peer: peerName(peer), Duration: time.Since(start), Err: err})
+			if err != nil && g.peerFallback {
+				// 租约由唯一的所属节点管理，按负载选出的节点不一定是所属节点
+				owner, ok := g.pickOwner(key)
+				if !ok {
+					return g.getLocally(ctx, key)
+				}
+				return g.loadWithPeerLease(ctx, owner, key)
+			}
+			return
+		}
+	}
+	return g.getLocally(ctx, key)
+}
 
-	return value, nil
+func (g *Group) populateCache(key string, value ByteView) ByteView {
+	value, result := g.mainCache.addIfAbsent(key, value)
+	switch result {
+	case rejectedTooLarge:
+		atomic.AddInt64(&g.stats.RejectedTooLarge, 1)
+		g.logger.Debug("entry too large to cache", "group", g.name, "key", key, "bytes", value.Len())
+	case rejectedByPolicy:
+		atomic.AddInt64(&g.stats.RejectedByPolicy, 1)
+	}
+	// 未写入缓存的值没有版本，不能用于ETag或CompareAndSet
+	if result != admitted {
+		value.version = 0
+	}
+	return value
 }
 
-func (g *Group) load(key string) (value ByteView, err error) {
-	// 从远程节点获取值
-	if peer, ok := g.peers.PickPeer(key); ok {
-		value, err = g.getFromPeer(peer, key)
-		return
+func (g *Group) nextVersion() uint64 {
+	return atomic.AddUint64(&g.versions, 1)
+}
+
+// CompareAndSet 当key在所属节点缓存中的版本等于expected时写入value，返回新版本
+// expected为0表示key不在缓存中，版本不一致时返回ErrVersionMismatch
+// 写入只作用于缓存而不会回写数据源，被淘汰后会重新从数据源加载
+func (g *Group) CompareAndSet(key string, expected uint64, value []byte) (uint64, error) {
+	return g.CompareAndSetContext(context.Background(), key, expected, value)
+}
+
+// CompareAndSetContext 同CompareAndSet，请求会被转发到key所属的节点
+func (g *Group) CompareAndSetContext(ctx context.Context, key string, expected uint64, value []byte) (uint64, error) {
+	return g.set(ensureTrace(ctx), &ccachepb.SetRequest{
+		Group:           g.name,
+		Key:             key,
+		Value:           value,
+		ExpectedVersion: expected,
+	})
+}
+
+// Set 无条件写入key所属节点的缓存，返回新版本，同样不会回写数据源
+func (g *Group) Set(key string, value []byte) (uint64, error) {
+	return g.set(ensureTrace(context.Background()), &ccachepb.SetRequest{
+		Group:         g.name,
+		Key:           key,
+		Value:         value,
+		Unconditional: true,
+	})
+}
+
+// Delete 从key所属节点的缓存中删除key，下次访问时重新从数据源加载
+func (g *Group) Delete(key string) error {
+	ctx := ensureTrace(context.Background())
+	g.hotCache.remove(key)
+	g.negative.remove(key)
+	setter, err := g.pickSetter(key)
+	if err != nil {
+		return err
+	}
+	if setter != nil {
+		return setter.Delete(ctx, &ccachepb.Request{Group: g.name, Key: key})
+	}
+	g.mainCache.remove(key)
+	return nil
+}
+
+func (g *Group) set(ctx context.Context, req *ccachepb.SetRequest) (uint64, error) {
+	g.hotCache.remove(req.GetKey())
+	g.negative.remove(req.GetKey())
+	setter, err := g.pickSetter(req.GetKey())
+	if err != nil {
+		return 0, err
 	}
-	return g.getLocally(key)
+	if setter != nil {
+		res, err := setter.CompareAndSet(ctx, req)
+		if err != nil {
+			return 0, err
+		}
+		return res.GetVersion(), nil
+	}
+	return g.setLocally(req)
 }
 
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+// pickOwner 返回key唯一的所属节点，PeerPicker未实现ownerPicker时与PickPeer相同
+func (g *Group) pickOwner(key string) (PeerGetter, bool) {
+	if op, ok := g.peers.(ownerPicker); ok {
+		return op.pickOwner(key)
+	}
+	return g.peers.PickPeer(key)
+}
+
+// pickSetter key属于远程节点时返回该节点，属于本节点时返回nil
+// 写入与删除总是发往唯一的所属节点，不按负载选择
+func (g *Group) pickSetter(key string) (PeerSetter, error) {
+	if g.peers == nil {
+		return nil, nil
+	}
+	peer, ok := g.pickOwner(key)
+	if !ok {
+		return nil, nil
+	}
+	setter, ok := peer.(PeerSetter)
+	if !ok {
+		return nil, errors.New("ccache: peer does not support writes")
+	}
+	return setter, nil
+}
+
+func (g *Group) setLocally(req *ccachepb.SetRequest) (uint64, error) {
+	v := ByteView{b: cloneBytes(req.GetValue()), version: g.nextVersion(), loadedAt: time.Now()}
+	if !g.mainCache.fits(req.GetKey(), v) {
+		return 0, ErrEntryTooLarge
+	}
+	g.negative.remove(req.GetKey())
+	if req.GetUnconditional() {
+		g.mainCache.add(req.GetKey(), v)
+		return v.version, nil
+	}
+	if !g.mainCache.compareAndSwap(req.GetKey(), req.GetExpectedVersion(), v) {
+		return 0, ErrVersionMismatch
+	}
+	return v.version, nil
+}
+
+// Stats 返回本地缓存的命中与准入统计
+func (g *Group) Stats() Stats {
+	return Stats{
+		Hits:             atomic.LoadInt64(&g.stats.Hits),
+		Misses:           atomic.LoadInt64(&g.stats.Misses),
+		RejectedTooLarge: atomic.LoadInt64(&g.stats.RejectedTooLarge),
+		RejectedByPolicy: atomic.LoadInt64(&g.stats.RejectedByPolicy),
+		NotCached:        atomic.LoadInt64(&g.stats.NotCached),
+		RateLimited:      atomic.LoadInt64(&g.stats.RateLimited),
+		NegativeHits:     atomic.LoadInt64(&g.stats.NegativeHits),
+	}
+}
+
+// SetCapacity 运行时调整本地缓存容量，超出新容量的部分按LRU立即淘汰，为0时表示不设限
+// 属于有容量配额的租户时，容量不超过租户剩余的配额
+func (g *Group) SetCapacity(cacheBytes int64) {
+	if g.tenant != nil {
+		g.tenant.mu.Lock()
+		defer g.tenant.mu.Unlock()
+		cacheBytes = g.tenant.clampCapacity(g, cacheBytes)
+	}
+	g.mainCache.setCapacity(cacheBytes)
+	g.hotCache.setCapacity(hotCacheBytes(cacheBytes))
+}
+
+// Capacity 返回本地缓存容量
+func (g *Group) Capacity() int64 {
+	_, capacity := g.mainCache.usage()
+	return capacity
+}
+
+// Range 遍历本地缓存，按最近访问到最久未访问排序，fn返回false时停止
+// 遍历基于key快照，不会在整个遍历期间持有缓存锁
+func (g *Group) Range(fn func(key string, value ByteView) bool) {
+	g.mainCache.rangeEntries(fn)
+}
+
+// ListPrefix 列出本地缓存中以prefix开头的key，按字典序排序
+func (g *Group) ListPrefix(prefix string) []string {
+	keys := make([]string, 0)
+	for _, key := range g.mainCache.keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RemovePrefix 失效本地缓存中以prefix开头的key，返回删除数量
+func (g *Group) RemovePrefix(prefix string) int {
+	return g.mainCache.removePrefix(prefix)
 }
 
 func (g *Group) RegisterPeers(peers PeerPicker) {
@@ -119,20 +443,35 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 	g.peers = peers
 }
 
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
 	req := &ccachepb.Request{
 		Group: g.name,
 		Key:   key,
 	}
-	value, err := peer.Get(req)
+	// 携带已有的版本，所属节点上版本未变化时不再传输value
+	stale, hasStale := g.hotCache.get(key)
+	if hasStale {
+		req.IfNoneMatch = stale.version
+	}
+	res, err := peer.Get(ctx, req)
 	if err != nil {
-		log.Println("get value from peer failed")
+		g.logger.Error("get value from peer failed", "group", g.name, "key", key, "peer", peerName(peer), "err", err)
 		return ByteView{}, err
 	}
+	if hasStale && res.GetNotModified() {
+		return stale, nil
+	}
+	if res.GetNotFound() {
+		g.negative.add(key)
+		return ByteView{}, ErrNotFound
+	}
 
-	bytes, err := proto.Marshal(value)
-	if err != nil {
-		return ByteView{}, fmt.Errorf("marshal proto msg err: %v", err)
+	value := ByteView{b: res.GetValue(), version: res.GetVersion()}
+	if res.GetLoadedAt() != 0 {
+		value.loadedAt = time.Unix(0, res.GetLoadedAt())
+	}
+	if value.version != 0 {
+		g.hotCache.add(key, value)
 	}
-	return ByteView{bytes}, nil
+	return value, nil
 }
@@ -0,0 +1,302 @@
+/*
+Package client 供非节点应用访问ccache集群的客户端
+使用与节点相同的一致性哈希将请求直接发送到key所属的节点
+*/
+package client
+
+import (
+	"bytes"
+	"ccache"
+	"ccache/ccachepb"
+	"ccache/consistenthash"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultBasePath       = "/ccache/"
+	defaultReplicas       = 3
+	defaultRetries        = 2
+	defaultRetryBackoff   = 50 * time.Millisecond
+	defaultTimeout        = 3 * time.Second
+	defaultMaxIdleConns   = 32
+	defaultGetConcurrency = 16
+)
+
+// ErrNoPeers 没有可用节点
+var ErrNoPeers = errors.New("ccache client: no peers")
+
+// Options 客户端配置，Replicas与NewPartitioner需与节点的HTTPPoolOptions一致
+type Options struct {
+	Replicas       int
+	NewPartitioner func() ccache.Partitioner
+	// 网络错误或502、503、504响应时的重试次数，为0时使用默认的2次，小于0时不重试，CompareAndSet不重试
+	Retries      int
+	RetryBackoff time.Duration
+	// 单次请求超时时间，默认3秒
+	Timeout time.Duration
+	// 每个节点保持的空闲连接数，默认32
+	MaxIdleConnsPerHost int
+	// GetMulti的最大并发请求数，默认16
+	GetConcurrency int
+	TLS            *ccache.TLSOptions
+	Auth           *ccache.HMACAuth
+}
+
+// Client ccache集群客户端，可被多个协程并发使用
+type Client struct {
+	mu     sync.RWMutex // guards
+	peers  ccache.Partitioner
+	client *http.Client
+	opts   Options
+}
+
+// New create a client, peers需与节点调用HTTPPool.Set时使用的地址一致
+func New(peers []string, opts Options) *Client {
+	if opts.Replicas == 0 {
+		opts.Replicas = defaultReplicas
+	}
+	if opts.NewPartitioner == nil {
+		replicas := opts.Replicas
+		opts.NewPartitioner = func() ccache.Partitioner {
+			return consistenthash.NewMap(replicas, nil)
+		}
+	}
+	if opts.Retries == 0 {
+		opts.Retries = defaultRetries
+	} else if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = defaultRetryBackoff
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxIdleConnsPerHost == 0 {
+		opts.MaxIdleConnsPerHost = defaultMaxIdleConns
+	}
+	if opts.GetConcurrency == 0 {
+		opts.GetConcurrency = defaultGetConcurrency
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:        opts.MaxIdleConnsPerHost * (len(peers) + 1),
+		MaxIdleConnsPerHost: opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if opts.TLS != nil {
+		transport.TLSClientConfig = opts.TLS.ClientConfig()
+	}
+	c := &Client{
+		client: &http.Client{Transport: transport, Timeout: opts.Timeout},
+		opts:   opts,
+	}
+	c.SetPeers(peers...)
+	return c
+}
+
+// SetPeers 更新节点列表
+func (c *Client) SetPeers(peers ...string) {
+	p := c.opts.NewPartitioner()
+	p.Add(peers...)
+	c.mu.Lock()
+	c.peers = p
+	c.mu.Unlock()
+}
+
+// Owner 返回key所属的节点
+func (c *Client) Owner(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.peers.Get(key)
+}
+
+// Get 从所属节点获取key，未缓存时由所属节点从数据源加载
+func (c *Client) Get(ctx context.Context, group, key string) ([]byte, error) {
+	res, err := c.do(ctx, http.MethodGet, group, key, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	response := &ccachepb.Response{}
+	if err = proto.Unmarshal(res, response); err != nil {
+		return nil, fmt.Errorf("unmarshal to proto error: %v", err)
+	}
+	if response.GetNotFound() {
+		return nil, ccache.ErrNotFound
+	}
+	return response.GetValue(), nil
+}
+
+// GetMulti 并发获取多个key，返回成功获取的结果及遇到的第一个错误
+func (c *Client) GetMulti(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		values   = make(map[string][]byte, len(keys))
+		sem      = make(chan struct{}, c.opts.GetConcurrency)
+	)
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			value, err := c.Get(ctx, group, key)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("get %s: %v", key, err)
+				}
+				return
+			}
+			values[key] = value
+		}(key)
+	}
+	wg.Wait()
+	return values, firstErr
+}
+
+// Set 无条件写入所属节点的缓存，返回新版本
+func (c *Client) Set(ctx context.Context, group, key string, value []byte) (uint64, error) {
+	return c.set(ctx, &ccachepb.SetRequest{Group: group, Key: key, Value: value, Unconditional: true}, true)
+}
+
+// CompareAndSet 所属节点缓存中的版本等于expected时写入，版本不一致时返回ccache.ErrVersionMismatch
+func (c *Client) CompareAndSet(ctx context.Context, group, key string, expected uint64, value []byte) (uint64, error) {
+	return c.set(ctx, &ccachepb.SetRequest{Group: group, Key: key, Value: value, ExpectedVersion: expected}, false)
+}
+
+// Delete 从所属节点的缓存中删除key
+func (c *Client) Delete(ctx context.Context, group, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, group, key, nil, true)
+	return err
+}
+
+// Close 关闭空闲连接
+func (c *Client) Close() {
+	c.client.CloseIdleConnections()
+}
+
+func (c *Client) set(ctx context.Context, req *ccachepb.SetRequest, retry bool) (uint64, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("marshal proto msg err: %v", err)
+	}
+	res, err := c.do(ctx, http.MethodPut, req.GetGroup(), req.GetKey(), body, retry)
+	if err != nil {
+		return 0, err
+	}
+	response := &ccachepb.SetResponse{}
+	if err = proto.Unmarshal(res, response); err != nil {
+		return 0, fmt.Errorf("unmarshal to proto error: %v", err)
+	}
+	return response.GetVersion(), nil
+}
+
+// statusError 节点返回的非成功状态
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "server response status:" + e.status
+}
+
+// do 向所属节点发送请求，网络错误与502、503、504响应按配置重试
+func (c *Client) do(ctx context.Context, method, group, key string, body []byte, retry bool) ([]byte, error) {
+	peer := c.Owner(key)
+	if peer == "" {
+		return nil, ErrNoPeers
+	}
+	u := fmt.Sprintf("%v%v%v/%v", peer, defaultBasePath, url.QueryEscape(group), url.QueryEscape(key))
+
+	attempts := 1
+	if retry {
+		attempts += c.opts.Retries
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.opts.RetryBackoff << uint(i-1)):
+			}
+		}
+		var res []byte
+		res, err = c.roundTrip(ctx, method, u, body)
+		if err == nil {
+			return res, nil
+		}
+		if !retryable(err) {
+			break
+		}
+	}
+	return nil, err
+}
+
+// retryable 只重试网络错误与502、503、504
+// 其他5xx（如数据源加载失败）重试会让所属节点再次访问数据源
+func retryable(err error) bool {
+	if err == ccache.ErrVersionMismatch || err == ccache.ErrEntryTooLarge {
+		return false
+	}
+	se, ok := err.(*statusError)
+	if !ok {
+		return true
+	}
+	switch se.code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (c *Client) roundTrip(ctx context.Context, method, u string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-protobuf")
+	}
+	if c.opts.Auth != nil {
+		if err := c.opts.Auth.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading response body:%v", err)
+		}
+		return b, nil
+	case http.StatusPreconditionFailed:
+		return nil, ccache.ErrVersionMismatch
+	case http.StatusRequestEntityTooLarge:
+		return nil, ccache.ErrEntryTooLarge
+	default:
+		return nil, &statusError{code: res.StatusCode, status: res.Status}
+	}
+}
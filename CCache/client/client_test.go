@@ -0,0 +1,142 @@
+package client
+
+import (
+	"ccache"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// node 记录每个节点收到的请求
+type node struct {
+	srv  *httptest.Server
+	mu   sync.Mutex
+	keys []string
+	// 剩余需要返回503的请求数
+	failures int32
+}
+
+func startNodes(t *testing.T, n int) ([]*node, []string) {
+	pool := ccache.NewHTTPPoolWithOpts("", ccache.HTTPPoolOptions{})
+	nodes := make([]*node, n)
+	addrs := make([]string, n)
+	for i := range nodes {
+		nd := &node{}
+		nd.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&nd.failures, -1) >= 0 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			nd.mu.Lock()
+			nd.keys = append(nd.keys, r.URL.Path)
+			nd.mu.Unlock()
+			pool.ServeHTTP(w, r)
+		}))
+		t.Cleanup(nd.srv.Close)
+		nodes[i], addrs[i] = nd, nd.srv.URL
+	}
+	return nodes, addrs
+}
+
+// originFailures client-fail的数据源被调用的次数
+var originFailures int32
+
+func init() {
+	ccache.NewGroup("client", 2<<10, ccache.GetterFunc(func(key string) ([]byte, error) {
+		return []byte("origin " + key), nil
+	}))
+	ccache.NewGroup("client-fail", 2<<10, ccache.GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&originFailures, 1)
+		return nil, errors.New("origin unavailable")
+	}))
+}
+
+func TestClientRouting(t *testing.T) {
+	nodes, addrs := startNodes(t, 3)
+	c := New(addrs, Options{})
+	defer c.Close()
+
+	keys := make([]string, 30)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	values, err := c.GetMulti(context.Background(), "client", keys)
+	assert.Nil(t, err)
+	assert.Len(t, values, len(keys))
+	assert.Equal(t, "origin key3", string(values["key3"]))
+
+	// 每个key只发往所属节点
+	for i, nd := range nodes {
+		for _, path := range nd.keys {
+			key := path[len("/ccache/client/"):]
+			assert.Equal(t, addrs[i], c.Owner(key))
+		}
+	}
+}
+
+func TestClientSetDelete(t *testing.T) {
+	_, addrs := startNodes(t, 2)
+	c := New(addrs, Options{})
+	ctx := context.Background()
+
+	version, err := c.Set(ctx, "client", "session", []byte("v1"))
+	assert.Nil(t, err)
+	value, err := c.Get(ctx, "client", "session")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(value))
+
+	_, err = c.CompareAndSet(ctx, "client", "session", version+1, []byte("v2"))
+	assert.Equal(t, ccache.ErrVersionMismatch, err)
+	_, err = c.CompareAndSet(ctx, "client", "session", version, []byte("v2"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, c.Delete(ctx, "client", "session"))
+	value, err = c.Get(ctx, "client", "session")
+	assert.Nil(t, err)
+	assert.Equal(t, "origin session", string(value))
+}
+
+func TestClientRetry(t *testing.T) {
+	nodes, addrs := startNodes(t, 1)
+	c := New(addrs, Options{Retries: 2})
+	ctx := context.Background()
+
+	atomic.StoreInt32(&nodes[0].failures, 2)
+	value, err := c.Get(ctx, "client", "retry")
+	assert.Nil(t, err)
+	assert.Equal(t, "origin retry", string(value))
+
+	atomic.StoreInt32(&nodes[0].failures, 3)
+	_, err = c.Get(ctx, "client", "retry")
+	assert.NotNil(t, err)
+
+	// Retries小于0时不重试
+	atomic.StoreInt32(&nodes[0].failures, 1)
+	_, err = New(addrs, Options{Retries: -1}).Get(ctx, "client", "retry")
+	assert.NotNil(t, err)
+	value, err = c.Get(ctx, "client", "retry")
+	assert.Nil(t, err)
+	assert.Equal(t, "origin retry", string(value))
+
+	// 数据源失败返回的500不重试，避免所属节点重复访问数据源
+	_, err = c.Get(ctx, "client-fail", "key")
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&originFailures))
+
+	// 4xx不重试
+	atomic.StoreInt32(&nodes[0].failures, 0)
+	before := len(nodes[0].keys)
+	_, err = c.Get(ctx, "missing", "key")
+	assert.NotNil(t, err)
+	assert.Equal(t, before+1, len(nodes[0].keys))
+
+	_, err = New(nil, Options{}).Get(ctx, "client", "key")
+	assert.Equal(t, ErrNoPeers, err)
+}
@@ -0,0 +1,398 @@
+package ccache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+进程内集群测试工具：每个节点运行在独立的httptest.Server上，
+节点间请求经过faultNetwork，可按链路注入延迟、丢包与网络分区
+*/
+
+var errDropped = errors.New("chaos: request dropped")
+
+// link 节点间单向链路的故障配置
+type link struct {
+	latency     time.Duration
+	dropRate    float64
+	partitioned bool
+	// 丢弃接下来的若干个请求
+	dropNext int
+}
+
+// faultNetwork 使用固定种子的随机数决定是否丢包，保证同样的请求序列得到同样的结果
+type faultNetwork struct {
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	links map[[2]string]link
+}
+
+func newFaultNetwork(seed int64) *faultNetwork {
+	return &faultNetwork{rnd: rand.New(rand.NewSource(seed)), links: make(map[[2]string]link)}
+}
+
+func (n *faultNetwork) set(from, to string, l link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.links[[2]string{from, to}] = l
+}
+
+// partition 双向隔离两个节点
+func (n *faultNetwork) partition(a, b string) {
+	n.set(a, b, link{partitioned: true})
+	n.set(b, a, link{partitioned: true})
+}
+
+func (n *faultNetwork) heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.links = make(map[[2]string]link)
+}
+
+// decide 返回本次请求的延迟以及是否失败
+func (n *faultNetwork) decide(from, to string) (time.Duration, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	l := n.links[[2]string{from, to}]
+	if l.dropNext > 0 {
+		l.dropNext--
+		n.links[[2]string{from, to}] = l
+		return l.latency, errDropped
+	}
+	if l.partitioned {
+		return 0, fmt.Errorf("chaos: %s is partitioned from %s", from, to)
+	}
+	if l.dropRate > 0 && n.rnd.Float64() < l.dropRate {
+		return l.latency, errDropped
+	}
+	return l.latency, nil
+}
+
+// faultTransport 某个节点发出请求时使用的Transport
+type faultTransport struct {
+	from string
+	net  *faultNetwork
+}
+
+func (t *faultTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	to := r.URL.Scheme + "://" + r.URL.Host
+	latency, err := t.net.decide(t.from, to)
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// origin 所有节点共享的数据源，记录每个节点的加载次数
+type origin struct {
+	mu     sync.Mutex
+	values map[string]string
+	loads  map[string]map[string]int // node -> key -> count
+	// 每次加载的耗时
+	delay time.Duration
+}
+
+func (o *origin) set(key, value string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.values[key] = value
+}
+
+func (o *origin) getter(node string) Getter {
+	return GetterFunc(func(key string) ([]byte, error) {
+		time.Sleep(o.delay)
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.loads[node] == nil {
+			o.loads[node] = make(map[string]int)
+		}
+		o.loads[node][key]++
+		v, ok := o.values[key]
+		if !ok {
+			return nil, fmt.Errorf("%s not exists", key)
+		}
+		return []byte(v), nil
+	})
+}
+
+func (o *origin) loadCounts() map[string]map[string]int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	counts := make(map[string]map[string]int, len(o.loads))
+	for node, keys := range o.loads {
+		counts[node] = make(map[string]int, len(keys))
+		for k, v := range keys {
+			counts[node][k] = v
+		}
+	}
+	return counts
+}
+
+type clusterNode struct {
+	addr  string
+	pool  *HTTPPool
+	group *Group
+}
+
+type cluster struct {
+	nodes  []*clusterNode
+	net    *faultNetwork
+	origin *origin
+}
+
+const clusterGroup = "chaos"
+
+// newCluster 启动n个节点，每个节点拥有独立的group
+func newCluster(t *testing.T, n int, seed int64, opts GroupOptions) *cluster {
+	c := &cluster{
+		net:    newFaultNetwork(seed),
+		origin: &origin{values: make(map[string]string), loads: make(map[string]map[string]int)},
+	}
+	addrs := make([]string, n)
+	for i := 0; i < n; i++ {
+		nd := &clusterNode{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nd.pool.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		nd.addr = srv.URL
+		addrs[i] = srv.URL
+		c.nodes = append(c.nodes, nd)
+	}
+	for _, nd := range c.nodes {
+		// 每个节点使用独立的Registry
+		registry := NewRegistry()
+		group, err := registry.NewGroup(clusterGroup, 2<<10, c.origin.getter(nd.addr), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nd.group = group
+		nd.pool = NewHTTPPoolWithOpts(nd.addr, HTTPPoolOptions{
+			Transport: &faultTransport{from: nd.addr, net: c.net},
+			Logger:    NewStdLogger(false),
+			Registry:  registry,
+		})
+		nd.pool.Set(addrs...)
+		nd.group.RegisterPeers(nd.pool)
+	}
+	return c
+}
+
+// owner 返回key所属的节点
+func (c *cluster) owner(key string) *clusterNode {
+	addr := c.nodes[0].pool.peers.Get(key)
+	for _, nd := range c.nodes {
+		if nd.addr == addr {
+			return nd
+		}
+	}
+	return nil
+}
+
+// assertLoadedOnce 每个key在每个节点上最多从数据源加载一次，且只在所属节点加载
+func (c *cluster) assertLoadedOnce(t *testing.T) {
+	t.Helper()
+	for node, keys := range c.origin.loadCounts() {
+		for key, n := range keys {
+			assert.LessOrEqual(t, n, 1, "key %s loaded %d times on %s", key, n, node)
+			assert.Equal(t, c.owner(key).addr, node, "key %s loaded on non-owner %s", key, node)
+		}
+	}
+}
+
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func TestClusterLoadOnce(t *testing.T) {
+	c := newCluster(t, 4, 1, GroupOptions{})
+	keys := testKeys(20)
+	for _, key := range keys {
+		c.origin.set(key, "v-"+key)
+	}
+	// 所有链路增加延迟，放大并发请求重叠的窗口
+	for _, from := range c.nodes {
+		for _, to := range c.nodes {
+			c.net.set(from.addr, to.addr, link{latency: 10 * time.Millisecond})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, nd := range c.nodes {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(nd *clusterNode) {
+				defer wg.Done()
+				for _, key := range keys {
+					v, err := nd.group.Get(key)
+					assert.Nil(t, err)
+					assert.Equal(t, "v-"+key, v.String())
+				}
+			}(nd)
+		}
+	}
+	wg.Wait()
+	c.assertLoadedOnce(t)
+}
+
+func TestClusterDropsAndPartitions(t *testing.T) {
+	c := newCluster(t, 3, 42, GroupOptions{})
+	keys := testKeys(30)
+	for _, key := range keys {
+		c.origin.set(key, "v-"+key)
+	}
+
+	a := c.nodes[0]
+	for _, to := range c.nodes[1:] {
+		c.net.set(a.addr, to.addr, link{dropRate: 0.5})
+	}
+	failed := 0
+	for _, key := range keys {
+		v, err := a.group.Get(key)
+		if err != nil {
+			failed++
+			continue
+		}
+		assert.Equal(t, "v-"+key, v.String())
+	}
+	assert.Greater(t, failed, 0, "expected some requests to be dropped")
+	c.assertLoadedOnce(t)
+
+	// 分区期间访问对端拥有的key失败，本节点拥有的key不受影响
+	b := c.nodes[1]
+	c.net.heal()
+	c.net.partition(a.addr, b.addr)
+	for _, key := range keys {
+		_, err := a.group.Get(key)
+		if c.owner(key) == b {
+			assert.NotNil(t, err, "key %s owned by partitioned peer", key)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+
+	c.net.heal()
+	for _, key := range keys {
+		v, err := a.group.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, "v-"+key, v.String())
+	}
+	c.assertLoadedOnce(t)
+}
+
+func TestClusterNoStaleReadAfterInvalidation(t *testing.T) {
+	c := newCluster(t, 3, 7, GroupOptions{})
+	keys := testKeys(10)
+	for _, key := range keys {
+		c.origin.set(key, "old")
+	}
+	for _, nd := range c.nodes {
+		for _, key := range keys {
+			_, err := nd.group.Get(key)
+			assert.Nil(t, err)
+		}
+	}
+
+	// 数据源更新后由任意节点发起失效
+	for i, key := range keys {
+		c.origin.set(key, "new")
+		assert.Nil(t, c.nodes[i%len(c.nodes)].group.Delete(key))
+	}
+	for _, nd := range c.nodes {
+		for _, key := range keys {
+			v, err := nd.group.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, "new", v.String(), "stale read of %s on %s", key, nd.addr)
+		}
+	}
+
+	// 失效后每个key在所属节点上恰好重新加载一次
+	for node, counts := range c.origin.loadCounts() {
+		for key, n := range counts {
+			assert.Equal(t, 2, n, "key %s on %s", key, node)
+		}
+	}
+}
+
+// totalLoads 统计key在整个集群内从数据源加载的次数
+func (c *cluster) totalLoads(key string) int {
+	total := 0
+	for _, keys := range c.origin.loadCounts() {
+		total += keys[key]
+	}
+	return total
+}
+
+func TestClusterLeaseCoalescing(t *testing.T) {
+	c := newCluster(t, 3, 3, GroupOptions{PeerFallback: true})
+	c.origin.delay = 50 * time.Millisecond
+	keys := testKeys(5)
+	for _, key := range keys {
+		c.origin.set(key, "v-"+key)
+	}
+
+	for _, key := range keys {
+		owner := c.owner(key)
+		// 非所属节点第一次获取失败，回退到租约加载
+		for _, nd := range c.nodes {
+			if nd != owner {
+				c.net.set(nd.addr, owner.addr, link{dropNext: 1})
+			}
+		}
+
+		var wg sync.WaitGroup
+		for _, nd := range c.nodes {
+			wg.Add(1)
+			go func(nd *clusterNode) {
+				defer wg.Done()
+				v, err := nd.group.Get(key)
+				assert.Nil(t, err)
+				assert.Equal(t, "v-"+key, v.String())
+			}(nd)
+		}
+		wg.Wait()
+		assert.Equal(t, 1, c.totalLoads(key), "key %s loaded more than once in cluster", key)
+
+		// 租约持有者加载的值由所属节点缓存
+		_, ok := owner.group.mainCache.get(key)
+		assert.True(t, ok)
+	}
+}
+
+func TestClusterFallbackWithoutOwner(t *testing.T) {
+	c := newCluster(t, 2, 5, GroupOptions{PeerFallback: true})
+	c.origin.set("key", "value")
+	owner := c.owner("key")
+	other := c.nodes[0]
+	if other == owner {
+		other = c.nodes[1]
+	}
+
+	// 所属节点不可达时只能在本节点加载
+	c.net.partition(other.addr, owner.addr)
+	v, err := other.group.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", v.String())
+	assert.Equal(t, 1, c.origin.loadCounts()[other.addr]["key"])
+}
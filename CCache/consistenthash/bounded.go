@@ -0,0 +1,111 @@
+package consistenthash
+
+import (
+	"math"
+	"sync"
+)
+
+// Bounded 带负载上限的一致性哈希（Consistent Hashing with Bounded Loads）
+// 在哈希环上顺时针查找第一个负载未超过 ceil(平均负载*(1+epsilon)) 的节点，
+// 负载由调用方通过Inc/Done上报，例如正在进行中的请求数
+// 各节点只统计自己发出的请求，同一个key在不同节点上可能选出不同的节点，需要唯一所属节点时使用Owner
+type Bounded struct {
+	mu      sync.Mutex // guards
+	ring    *Map
+	loads   map[string]int64
+	total   int64
+	epsilon float64
+}
+
+// NewBounded initiate bounded-load consistent hashing
+// epsilon为允许超出平均负载的比例，<=0时使用0.25
+func NewBounded(replicas int, epsilon float64, hash Hash) *Bounded {
+	if epsilon <= 0 {
+		epsilon = 0.25
+	}
+	return &Bounded{
+		ring:    NewMap(replicas, hash),
+		loads:   make(map[string]int64),
+		epsilon: epsilon,
+	}
+}
+
+// Add 添加真实节点
+func (b *Bounded) Add(nodes ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ring.Add(nodes...)
+	for _, node := range nodes {
+		if _, ok := b.loads[node]; !ok {
+			b.loads[node] = 0
+		}
+	}
+}
+
+// Get 获取距离最近且负载未满的节点
+func (b *Bounded) Get(key string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := b.ring
+	if len(m.keys) == 0 {
+		return ""
+	}
+	limit := b.maxLoad()
+	start := m.search(key)
+	first := ""
+	for i := 0; i < len(m.keys); i++ {
+		node := m.hashMap[m.keys[(start+i)%len(m.keys)]]
+		if first == "" {
+			first = node
+		}
+		if b.loads[node]+1 <= limit {
+			return node
+		}
+	}
+	return first
+}
+
+// Owner 不考虑负载时key所属的节点，只取决于节点列表，各节点计算的结果一致
+func (b *Bounded) Owner(key string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ring.Get(key)
+}
+
+// Inc 节点负载加一
+func (b *Bounded) Inc(node string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.loads[node]; !ok {
+		return
+	}
+	b.loads[node]++
+	b.total++
+}
+
+// Done 节点负载减一
+func (b *Bounded) Done(node string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.loads[node] <= 0 {
+		return
+	}
+	b.loads[node]--
+	b.total--
+}
+
+// Load 返回节点当前负载
+func (b *Bounded) Load(node string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.loads[node]
+}
+
+func (b *Bounded) maxLoad() int64 {
+	if len(b.loads) == 0 {
+		return 0
+	}
+	avg := float64(b.total+1) / float64(len(b.loads))
+	return int64(math.Ceil(avg * (1 + b.epsilon)))
+}
@@ -50,12 +50,14 @@ func (m *Map) Get(key string) string {
 	if len(m.keys) == 0 {
 		return ""
 	}
+	// keys是环状结构，使用取模来处理最小节点为0的情况
+	return m.hashMap[m.keys[m.search(key)%len(m.keys)]]
+}
+
+// search 二分查找最小的节点（距离最近的)
+func (m *Map) search(key string) int {
 	hashed := int(m.hash([]byte(key)))
-	// 二分查找最小的节点（距离最近的)
-	idx := sort.Search(len(m.keys), func(i int) bool {
+	return sort.Search(len(m.keys), func(i int) bool {
 		return m.keys[i] >= hashed
 	})
-
-	// keys是环状结构，使用取模来处理最小节点为0的情况
-	return m.hashMap[m.keys[idx%len(m.keys)]]
 }
@@ -0,0 +1,50 @@
+package consistenthash
+
+import "sync"
+
+// Jump Google jump consistent hash，只需O(1)内存，分布几乎完全均匀
+// 节点只能按添加顺序追加，因此调用Add时需保证各实例间节点顺序一致
+type Jump struct {
+	mu    sync.RWMutex
+	hash  Hash64
+	nodes []string
+}
+
+// NewJump initiate jump hash, hash为nil时使用fnv-1a
+func NewJump(hash Hash64) *Jump {
+	j := &Jump{hash: hash}
+	if j.hash == nil {
+		j.hash = fnv64a
+	}
+	return j
+}
+
+// Add 追加真实节点
+func (j *Jump) Add(nodes ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.nodes = append(j.nodes, nodes...)
+}
+
+// Get 获取key所在的节点
+func (j *Jump) Get(key string) string {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if len(j.nodes) == 0 {
+		return ""
+	}
+	return j.nodes[JumpHash(j.hash([]byte(key)), len(j.nodes))]
+}
+
+// JumpHash 将key映射到[0, buckets)中的一个桶
+// 参考 https://arxiv.org/abs/1406.2294
+func JumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
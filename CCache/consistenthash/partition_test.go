@@ -0,0 +1,174 @@
+package consistenthash
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+)
+
+type partitioner interface {
+	Add(nodes ...string)
+	Get(key string) string
+}
+
+var partitioners = map[string]func() partitioner{
+	"crc32-ring-3":   func() partitioner { return NewMap(3, nil) },
+	"crc32-ring-160": func() partitioner { return NewMap(160, nil) },
+	"rendezvous":     func() partitioner { return NewRendezvous(nil) },
+	"jump":           func() partitioner { return NewJump(nil) },
+	"bounded":        func() partitioner { return NewBounded(160, 0.25, nil) },
+}
+
+func nodeNames(n int) []string {
+	nodes := make([]string, n)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("http://10.0.0.%d:8080", i+1)
+	}
+	return nodes
+}
+
+// distribution 统计key在各节点上的分布，返回相对标准差（标准差/平均值）
+func distribution(p partitioner, nodes []string, keys int) float64 {
+	counts := make(map[string]int, len(nodes))
+	for i := 0; i < keys; i++ {
+		counts[p.Get("key-"+strconv.Itoa(i))]++
+	}
+	mean := float64(keys) / float64(len(nodes))
+	var variance float64
+	for _, node := range nodes {
+		d := float64(counts[node]) - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance/float64(len(nodes))) / mean
+}
+
+// movement 统计新增一个节点后迁移的key比例，理想值为1/(n+1)
+func movement(newP func() partitioner, nodes []string, keys int) float64 {
+	before, after := newP(), newP()
+	before.Add(nodes...)
+	after.Add(nodes...)
+	after.Add("http://10.0.1.1:8080")
+
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if before.Get(key) != after.Get(key) {
+			moved++
+		}
+	}
+	return float64(moved) / float64(keys)
+}
+
+func TestPartitionersEmpty(t *testing.T) {
+	for name, newP := range partitioners {
+		if got := newP().Get("key"); got != "" {
+			t.Errorf("%s: got %q from empty partitioner, want empty", name, got)
+		}
+	}
+}
+
+func TestPartitionersStable(t *testing.T) {
+	nodes := nodeNames(5)
+	for name, newP := range partitioners {
+		a, b := newP(), newP()
+		a.Add(nodes...)
+		b.Add(nodes...)
+		for i := 0; i < 1000; i++ {
+			key := strconv.Itoa(i)
+			if a.Get(key) != b.Get(key) {
+				t.Fatalf("%s: key %s mapped to different nodes", name, key)
+			}
+		}
+	}
+}
+
+func TestPartitionersMovement(t *testing.T) {
+	nodes := nodeNames(8)
+	for _, name := range []string{"rendezvous", "jump", "crc32-ring-160"} {
+		moved := movement(partitioners[name], nodes, 20000)
+		// 理想值约为 1/9 ≈ 0.11
+		if moved > 0.2 {
+			t.Errorf("%s: moved %.3f of keys after adding one node", name, moved)
+		}
+	}
+}
+
+func TestPartitionersDistribution(t *testing.T) {
+	nodes := nodeNames(8)
+	for _, name := range []string{"rendezvous", "jump"} {
+		p := partitioners[name]()
+		p.Add(nodes...)
+		if rsd := distribution(p, nodes, 50000); rsd > 0.05 {
+			t.Errorf("%s: relative stddev %.3f, want <= 0.05", name, rsd)
+		}
+	}
+}
+
+func TestJumpHash(t *testing.T) {
+	for _, buckets := range []int{1, 2, 10, 1000} {
+		for key := uint64(0); key < 100; key++ {
+			if b := JumpHash(key, buckets); b < 0 || b >= buckets {
+				t.Fatalf("JumpHash(%d, %d) = %d out of range", key, buckets, b)
+			}
+		}
+	}
+	// 增加桶时，key只会迁移到新桶
+	for key := uint64(0); key < 1000; key++ {
+		before, after := JumpHash(key, 10), JumpHash(key, 11)
+		if before != after && after != 10 {
+			t.Fatalf("key %d moved from %d to %d", key, before, after)
+		}
+	}
+}
+
+func TestBoundedLoad(t *testing.T) {
+	b := NewBounded(3, 0.25, func(key []byte) uint32 {
+		v, _ := strconv.Atoi(string(key))
+		return uint32(v)
+	})
+	b.Add("1", "3", "5")
+
+	// 无负载时与普通一致性哈希一致
+	assert := func(key, want string) {
+		t.Helper()
+		if got := b.Get(key); got != want {
+			t.Fatalf("Get(%s) = %s, want %s", key, got, want)
+		}
+	}
+	assert("2", "3")
+
+	// 3的负载达到上限后，顺时针顺延到下一个节点
+	b.Inc("3")
+	b.Inc("3")
+	assert("2", "5")
+	// 所属节点不受负载影响
+	if owner := b.Owner("2"); owner != "3" {
+		t.Fatalf("Owner(2) = %s, want 3", owner)
+	}
+	if b.Load("3") != 2 {
+		t.Fatalf("load of 3 = %d, want 2", b.Load("3"))
+	}
+
+	b.Done("3")
+	b.Done("3")
+	assert("2", "3")
+}
+
+// BenchmarkPartitioners 输出各算法的分布（相对标准差）与新增节点时的迁移比例
+// go test -bench Partitioners ./consistenthash
+func BenchmarkPartitioners(b *testing.B) {
+	nodes := nodeNames(8)
+	for name, newP := range partitioners {
+		b.Run(name, func(b *testing.B) {
+			p := newP()
+			p.Add(nodes...)
+			for i := 0; i < b.N; i++ {
+				p.Get("key-" + strconv.Itoa(i))
+			}
+			b.StopTimer()
+			b.ReportMetric(distribution(p, nodes, 100000), "rsd")
+			b.ReportMetric(movement(newP, nodes, 100000), "moved")
+		})
+	}
+}
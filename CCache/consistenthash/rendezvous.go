@@ -0,0 +1,67 @@
+package consistenthash
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// Hash64 64位哈希函数，用于rendezvous和jump hash
+type Hash64 func([]byte) uint64
+
+// Rendezvous 最高随机权重哈希（HRW），对每个节点计算hash(node+key)，取得分最高的节点
+// 无需虚拟节点即可分布均匀，增删节点时只有归属于该节点的key会迁移，代价是Get为O(n)
+type Rendezvous struct {
+	mu    sync.RWMutex
+	hash  Hash64
+	nodes []string
+}
+
+// NewRendezvous initiate rendezvous hashing, hash为nil时使用fnv-1a
+func NewRendezvous(hash Hash64) *Rendezvous {
+	r := &Rendezvous{hash: hash}
+	if r.hash == nil {
+		r.hash = fnv64a
+	}
+	return r
+}
+
+// Add 添加真实节点
+func (r *Rendezvous) Add(nodes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes = append(r.nodes, nodes...)
+}
+
+// Get 获取得分最高的节点
+func (r *Rendezvous) Get(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		best      string
+		bestScore uint64
+	)
+	for _, node := range r.nodes {
+		score := mix64(r.hash([]byte(node + key)))
+		if best == "" || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+func fnv64a(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// mix64 murmur3 finalizer，打散fnv在相似输入下的高位聚集
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,120 @@
+/*
+Package gateway 面向终端用户的HTTP入口
+按路由前缀将请求映射到Group，根据缓存值的版本与加载时间设置ETag、Cache-Control与Age，
+支持If-None-Match条件请求与Range请求，数据源中不存在的key返回404
+*/
+package gateway
+
+import (
+	"ccache"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultContentType = "application/octet-stream"
+
+// Route 路由，请求路径去掉Prefix后的部分作为key
+type Route struct {
+	// 路径前缀，如 /scores/
+	Prefix string
+	Group  *ccache.Group
+	// 客户端与中间缓存可直接使用响应的时长，为0时要求每次通过ETag重新验证
+	MaxAge time.Duration
+	// 不存在的key的404响应可缓存的时长，为0时要求每次重新验证
+	NotFoundMaxAge time.Duration
+	// 默认application/octet-stream
+	ContentType string
+}
+
+// Gateway 将HTTP请求路由到Group的Handler
+type Gateway struct {
+	// 按前缀长度从长到短排序，优先匹配最长的前缀
+	routes []Route
+}
+
+// New create a gateway
+func New(routes ...Route) *Gateway {
+	gw := &Gateway{}
+	for _, route := range routes {
+		if route.Group == nil {
+			panic("gateway: nil group for route " + route.Prefix)
+		}
+		if route.ContentType == "" {
+			route.ContentType = defaultContentType
+		}
+		gw.routes = append(gw.routes, route)
+	}
+	sort.SliceStable(gw.routes, func(i, j int) bool {
+		return len(gw.routes[i].Prefix) > len(gw.routes[j].Prefix)
+	})
+	return gw
+}
+
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	route, key, ok := gw.match(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	value, err := route.Group.GetContext(r.Context(), key)
+	switch {
+	case errors.Is(err, ccache.ErrNotFound):
+		w.Header().Set("Cache-Control", cacheControl(route.NotFoundMaxAge))
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, ccache.ErrOriginRateLimited):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", route.ContentType)
+	if value.Version() == 0 {
+		// 数据源提示不缓存的值没有版本，也不允许客户端缓存
+		header.Set("Cache-Control", "no-store")
+	} else {
+		header.Set("ETag", value.ETag())
+		header.Set("Cache-Control", cacheControl(route.MaxAge))
+	}
+	if loadedAt := value.LoadedAt(); !loadedAt.IsZero() {
+		age := time.Since(loadedAt) / time.Second
+		if age < 0 {
+			age = 0
+		}
+		header.Set("Age", strconv.FormatInt(int64(age), 10))
+	}
+
+	// ServeContent处理If-None-Match、Range与HEAD请求
+	http.ServeContent(w, r, "", value.LoadedAt(), value.Reader())
+}
+
+// match 返回最长前缀匹配的路由与key，key为空时不匹配
+func (gw *Gateway) match(path string) (Route, string, bool) {
+	for _, route := range gw.routes {
+		if strings.HasPrefix(path, route.Prefix) && len(path) > len(route.Prefix) {
+			return route, path[len(route.Prefix):], true
+		}
+	}
+	return Route{}, "", false
+}
+
+func cacheControl(maxAge time.Duration) string {
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+}
@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"bytes"
+	"ccache"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newGateway(t *testing.T) (*Gateway, *ccache.Group, *int32) {
+	var loads int32
+	registry := ccache.NewRegistry()
+	group, err := registry.NewGroup("files", 2<<10, ccache.HintGetterFunc(func(key string) ([]byte, ccache.Hint, error) {
+		atomic.AddInt32(&loads, 1)
+		switch key {
+		case "missing":
+			return nil, ccache.Hint{}, fmt.Errorf("load %s: %w", key, ccache.ErrNotFound)
+		case "volatile":
+			return []byte("now"), ccache.Hint{NoCache: true}, nil
+		case "broken":
+			return nil, ccache.Hint{}, fmt.Errorf("origin unavailable")
+		}
+		return []byte("0123456789"), ccache.Hint{}, nil
+	}), ccache.GroupOptions{NegativeTTL: time.Minute})
+	assert.Nil(t, err)
+
+	gw := New(
+		Route{Prefix: "/files/", Group: group, MaxAge: time.Minute, NotFoundMaxAge: 10 * time.Second, ContentType: "text/plain"},
+		Route{Prefix: "/files/raw/", Group: group},
+	)
+	return gw, group, &loads
+}
+
+func serve(gw *Gateway, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGatewayHeaders(t *testing.T) {
+	gw, group, _ := newGateway(t)
+
+	rec := serve(gw, http.MethodGet, "/files/a.txt", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0123456789", rec.Body.String())
+	value, err := group.Get("a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, value.ETag(), rec.Header().Get("ETag"))
+	assert.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "0", rec.Header().Get("Age"))
+
+	// 最长前缀优先
+	rec = serve(gw, http.MethodGet, "/files/raw/a.txt", nil)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+
+	rec = serve(gw, http.MethodHead, "/files/a.txt", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, "10", rec.Header().Get("Content-Length"))
+
+	rec = serve(gw, http.MethodGet, "/files/volatile", nil)
+	assert.Equal(t, "now", rec.Body.String())
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Empty(t, rec.Header().Get("ETag"))
+}
+
+func TestGatewayConditional(t *testing.T) {
+	gw, group, _ := newGateway(t)
+	rec := serve(gw, http.MethodGet, "/files/a.txt", nil)
+	tag := rec.Header().Get("ETag")
+
+	rec = serve(gw, http.MethodGet, "/files/a.txt", map[string]string{"If-None-Match": tag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// 写入新值后版本变化
+	_, err := group.Set("a.txt", []byte("new"))
+	assert.Nil(t, err)
+	rec = serve(gw, http.MethodGet, "/files/a.txt", map[string]string{"If-None-Match": tag})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "new", rec.Body.String())
+	assert.NotEqual(t, tag, rec.Header().Get("ETag"))
+}
+
+func TestGatewayRange(t *testing.T) {
+	gw, _, _ := newGateway(t)
+	rec := serve(gw, http.MethodGet, "/files/a.txt", map[string]string{"Range": "bytes=2-5"})
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "2345", rec.Body.String())
+	assert.Equal(t, "bytes 2-5/10", rec.Header().Get("Content-Range"))
+
+	rec = serve(gw, http.MethodGet, "/files/a.txt", map[string]string{"Range": "bytes=-3"})
+	assert.Equal(t, "789", rec.Body.String())
+
+	rec = serve(gw, http.MethodGet, "/files/a.txt", map[string]string{"Range": "bytes=20-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+}
+
+func TestGatewayNotFound(t *testing.T) {
+	gw, group, loads := newGateway(t)
+	for i := 0; i < 3; i++ {
+		rec := serve(gw, http.MethodGet, "/files/missing", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "public, max-age=10", rec.Header().Get("Cache-Control"))
+	}
+	// 之后的请求命中负缓存，不再访问数据源
+	assert.Equal(t, int32(1), atomic.LoadInt32(loads))
+	assert.Equal(t, int64(2), group.Stats().NegativeHits)
+
+	assert.Equal(t, http.StatusNotFound, serve(gw, http.MethodGet, "/other/a.txt", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(gw, http.MethodGet, "/files/", nil).Code)
+	assert.Equal(t, http.StatusBadGateway, serve(gw, http.MethodGet, "/files/broken", nil).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(gw, http.MethodPost, "/files/a.txt", nil).Code)
+}
+
+func TestGatewayServer(t *testing.T) {
+	gw, _, _ := newGateway(t)
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/files/b.txt")
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	assert.Equal(t, "0123456789", string(body))
+}
+
+func BenchmarkGateway(b *testing.B) {
+	value := bytes.Repeat([]byte("x"), 64<<10)
+	registry := ccache.NewRegistry()
+	group, _ := registry.NewGroup("bench", 1<<20, ccache.GetterFunc(func(key string) ([]byte, error) {
+		return value, nil
+	}), ccache.GroupOptions{})
+	gw := New(Route{Prefix: "/bench/", Group: group})
+	req := httptest.NewRequest(http.MethodGet, "/bench/key", nil)
+	w := &discardWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(value)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gw.ServeHTTP(w, req)
+	}
+}
+
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
@@ -4,16 +4,23 @@
 package ccache
 
 import (
+	"bytes"
 	"ccache/ccachepb"
 	"ccache/consistenthash"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -23,14 +30,19 @@ type HTTPPool struct {
 	// 前缀路径
 	basePath    string
 	mu          sync.Mutex             // guards
-	peers       *consistenthash.Map    //节点列表
+	peers       Partitioner            //节点列表
 	httpGetters map[string]*httpGetter //映射节点和路径关系（baseURL前缀）
 	opts        HTTPPoolOptions
+	client      *http.Client
+	// 查找本节点的group，默认DefaultRegistry
+	registry *Registry
 }
 
 // HTTP客户端
 type httpGetter struct {
 	baseURL string // e.g http://localhost:8080
+	client  *http.Client
+	auth    *HMACAuth
 }
 
 const (
@@ -39,7 +51,22 @@ const (
 )
 
 type HTTPPoolOptions struct {
-	replicas int
+	// 一致性哈希虚拟节点倍数，默认为3
+	Replicas int
+	// 每次调用Set时用于创建新的Partitioner，默认使用consistenthash.Map
+	NewPartitioner func() Partitioner
+	// 接收EventServe事件
+	Observer Observer
+	// 默认使用标准库log
+	Logger Logger
+	// 开启后节点地址需使用https://，服务端需使用ServerTLSConfig启动
+	TLS *TLSOptions
+	// 开启后对发往其他节点的请求签名，并拒绝未签名或签名无效的请求
+	Auth *HMACAuth
+	// 访问其他节点使用的Transport，设置后忽略TLS中的客户端配置
+	Transport http.RoundTripper
+	// 处理节点请求时查找Group的注册表，默认DefaultRegistry
+	Registry *Registry
 }
 
 func NewHTTPPoolWithOpts(self string, opts HTTPPoolOptions) *HTTPPool {
@@ -47,9 +74,29 @@ func NewHTTPPoolWithOpts(self string, opts HTTPPoolOptions) *HTTPPool {
 		self:        self,
 		basePath:    defaultBasePath,
 		httpGetters: make(map[string]*httpGetter),
+		opts:        opts,
+		registry:    opts.Registry,
 	}
-	if opts.replicas == 0 {
-		hp.opts.replicas = defaultReplicas
+	if hp.registry == nil {
+		hp.registry = DefaultRegistry
+	}
+	if hp.opts.Replicas == 0 {
+		hp.opts.Replicas = defaultReplicas
+	}
+	if hp.opts.NewPartitioner == nil {
+		replicas := hp.opts.Replicas
+		hp.opts.NewPartitioner = func() Partitioner {
+			return consistenthash.NewMap(replicas, nil)
+		}
+	}
+	if hp.opts.Logger == nil {
+		hp.opts.Logger = defaultLogger
+	}
+	hp.client = http.DefaultClient
+	if hp.opts.Transport != nil {
+		hp.client = &http.Client{Transport: hp.opts.Transport}
+	} else if hp.opts.TLS != nil {
+		hp.client = &http.Client{Transport: &http.Transport{TLSClientConfig: hp.opts.TLS.ClientConfig()}}
 	}
 
 	return hp
@@ -67,88 +114,472 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := p.authenticate(r); err != nil {
+		p.opts.Logger.Error("reject peer request", "server", p.self, "remote", r.RemoteAddr, "err", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	groupname := parts[0]
 	key := parts[1]
 
-	group := GetGroup(groupname)
+	group := p.registry.GetGroup(groupname)
 	if group == nil {
 		http.Error(w, "No such group", http.StatusNotFound)
 		return
 	}
 
-	value, err := group.Get(key)
+	// 延续调用方的链路
+	ctx := r.Context()
+	if tc, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok {
+		ctx = ContextWithTrace(ctx, tc)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		p.serveGet(ctx, w, r, group, key)
+	case http.MethodPut:
+		p.serveSet(w, r, group, key)
+	case http.MethodPost:
+		p.serveLease(ctx, w, r, group, key)
+	case http.MethodDelete:
+		group.mainCache.remove(key)
+		group.negative.remove(key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (p *HTTPPool) serveGet(ctx context.Context, w http.ResponseWriter, r *http.Request, group *Group, key string) {
+	start := time.Now()
+	value, err := group.GetContext(ctx, key)
+	notify(p.opts.Observer, ctx, Event{Type: EventServe, Group: group.name, Key: key, Duration: time.Since(start), Err: err})
+	if errors.Is(err, ErrNotFound) {
+		p.writeResponse(w, &ccachepb.Response{NotFound: true})
+		return
+	}
 	if err != nil {
+		p.opts.Logger.Error("serve peer request failed", "server", p.self, "group", group.name, "key", key, "err", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// 请求方持有的版本未变化时只返回304，未缓存的值没有版本
+	if value.Version() != 0 {
+		w.Header().Set("ETag", value.ETag())
+		if r.Header.Get("If-None-Match") == value.ETag() {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 	}
 
-	response, err := proto.Marshal(&ccachepb.Response{Value: value.ByteSlice()})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Length", strconv.Itoa(responseSize(value)))
+	writeValue(w, value)
+}
+
+func (p *HTTPPool) writeResponse(w http.ResponseWriter, res *ccachepb.Response) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	response, err := proto.MarshalOptions{}.MarshalAppend(*buf, res)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	*buf = response
 
 	w.Header().Set("Content-Type", "application/x-protobuf")
 	w.Write(response)
+}
 
+// Response中各字段的编号，writeValue按相同的编码直接写出
+const (
+	responseValueField    = 1
+	responseVersionField  = 2
+	responseLoadedAtField = 4
+)
+
+// writeValue 按ccachepb.Response的编码写出value，value直接写入w而不经过序列化拷贝
+func writeValue(w io.Writer, value ByteView) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	b := *buf
+	if value.Len() > 0 {
+		b = protowire.AppendTag(b, responseValueField, protowire.BytesType)
+		b = protowire.AppendVarint(b, uint64(value.Len()))
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		if _, err := value.WriteTo(w); err != nil {
+			return err
+		}
+		b = b[:0]
+	}
+	b = appendValueMeta(b, value)
+	*buf = b
+	_, err := w.Write(b)
+	return err
+}
+
+// appendValueMeta 编码版本与加载时间字段
+func appendValueMeta(b []byte, value ByteView) []byte {
+	if value.version != 0 {
+		b = protowire.AppendTag(b, responseVersionField, protowire.VarintType)
+		b = protowire.AppendVarint(b, value.version)
+	}
+	if !value.loadedAt.IsZero() {
+		b = protowire.AppendTag(b, responseLoadedAtField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(value.loadedAt.UnixNano()))
+	}
+	return b
+}
+
+// responseSize writeValue写出的字节数
+func responseSize(value ByteView) int {
+	n := 0
+	if value.Len() > 0 {
+		n += protowire.SizeTag(responseValueField) + protowire.SizeBytes(value.Len())
+	}
+	if value.version != 0 {
+		n += protowire.SizeTag(responseVersionField) + protowire.SizeVarint(value.version)
+	}
+	if !value.loadedAt.IsZero() {
+		n += protowire.SizeTag(responseLoadedAtField) + protowire.SizeVarint(uint64(value.loadedAt.UnixNano()))
+	}
+	return n
+}
+
+// serveLease 处理其他节点的租约申请与释放，申请时可能阻塞到租约持有者释放
+func (p *HTTPPool) serveLease(ctx context.Context, w http.ResponseWriter, r *http.Request, group *Group, key string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &ccachepb.LeaseRequest{}
+	if err = proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Key = key
+
+	response, err := proto.Marshal(group.serveLease(ctx, req))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(response)
+}
+
+// serveSet 处理其他节点转发的写入，请求方已确认本节点是key的所属节点
+func (p *HTTPPool) serveSet(w http.ResponseWriter, r *http.Request, group *Group, key string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &ccachepb.SetRequest{}
+	if err = proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.Key = key
+	version, err := group.setLocally(req)
+	if err == ErrVersionMismatch {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if err == ErrEntryTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := proto.Marshal(&ccachepb.SetResponse{Version: version})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("ETag", etag(version))
+	w.Write(response)
+}
+
+// ServerTLSConfig 用于启动节点服务端的TLS配置，未开启TLS时返回nil
+func (p *HTTPPool) ServerTLSConfig() *tls.Config {
+	if p.opts.TLS == nil {
+		return nil
+	}
+	return p.opts.TLS.ServerConfig()
 }
 
 func (p *HTTPPool) Log(format string, v ...interface{}) {
-	log.Printf("[Server %s] %s", p.self, fmt.Sprintf(format, v...))
+	p.opts.Logger.Info(fmt.Sprintf(format, v...), "server", p.self)
 }
 
-func (h *httpGetter) Get(req *ccachepb.Request) (response *ccachepb.Response, err error) {
+func (h *httpGetter) Name() string {
+	return h.baseURL
+}
+
+func (h *httpGetter) Get(ctx context.Context, req *ccachepb.Request) (response *ccachepb.Response, err error) {
 	url := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(req.GetGroup()), url.QueryEscape(req.GetKey()))
-	res, err := http.Get(url)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetIfNoneMatch() != 0 {
+		httpReq.Header.Set("If-None-Match", etag(req.GetIfNoneMatch()))
+	}
+	res, err := h.do(ctx, httpReq)
 	if err != nil {
 		return nil, err
 	}
 
 	defer res.Body.Close()
 
+	if res.StatusCode == http.StatusNotModified {
+		return &ccachepb.Response{Version: req.GetIfNoneMatch(), NotModified: true}, nil
+	}
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("server response status:%v", res.Status)
 	}
 
-	bytes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
+	// 响应读入复用的缓冲区，Unmarshal会拷贝value，返回后缓冲区即可归还
+	buf := getBuffer()
+	defer putBuffer(buf)
+	body := bytes.NewBuffer(*buf)
+	if res.ContentLength > 0 {
+		body.Grow(int(res.ContentLength))
+	}
+	if _, err = body.ReadFrom(res.Body); err != nil {
 		return nil, fmt.Errorf("reading response body:%v", err)
 	}
+	*buf = body.Bytes()
 
-	err = proto.Unmarshal(bytes, response)
+	response = &ccachepb.Response{}
+	err = proto.Unmarshal(*buf, response)
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal to proto error: %v", err)
 	}
 	return
 }
 
+// CompareAndSet 将CompareAndSet请求转发到所属节点
+func (h *httpGetter) CompareAndSet(ctx context.Context, req *ccachepb.SetRequest) (*ccachepb.SetResponse, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal proto msg err: %v", err)
+	}
+	url := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(req.GetGroup()), url.QueryEscape(req.GetKey()))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	res, err := h.do(ctx, httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusPreconditionFailed {
+		return nil, ErrVersionMismatch
+	}
+	if res.StatusCode == http.StatusRequestEntityTooLarge {
+		return nil, ErrEntryTooLarge
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server response status:%v", res.Status)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body:%v", err)
+	}
+	response := &ccachepb.SetResponse{}
+	if err = proto.Unmarshal(b, response); err != nil {
+		return nil, fmt.Errorf("unmarshal to proto error: %v", err)
+	}
+	return response, nil
+}
+
+// Lease 向所属节点申请或释放加载租约
+func (h *httpGetter) Lease(ctx context.Context, req *ccachepb.LeaseRequest) (*ccachepb.LeaseResponse, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal proto msg err: %v", err)
+	}
+	url := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(req.GetGroup()), url.QueryEscape(req.GetKey()))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	res, err := h.do(ctx, httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server response status:%v", res.Status)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body:%v", err)
+	}
+	response := &ccachepb.LeaseResponse{}
+	if err = proto.Unmarshal(b, response); err != nil {
+		return nil, fmt.Errorf("unmarshal to proto error: %v", err)
+	}
+	return response, nil
+}
+
+// Delete 从所属节点的缓存中删除key
+func (h *httpGetter) Delete(ctx context.Context, req *ccachepb.Request) error {
+	url := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(req.GetGroup()), url.QueryEscape(req.GetKey()))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := h.do(ctx, httpReq)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("server response status:%v", res.Status)
+	}
+	return nil
+}
+
+// do 添加链路与签名请求头后发送请求
+func (h *httpGetter) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if tc, ok := TraceFromContext(ctx); ok {
+		req.Header.Set(traceparentHeader, tc.child().traceparent())
+	}
+	if h.auth != nil {
+		if err := h.auth.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+	return h.client.Do(req)
+}
+
 var _ PeerGetter = (*httpGetter)(nil)
+var _ PeerSetter = (*httpGetter)(nil)
+var _ PeerLeaser = (*httpGetter)(nil)
 
 // Set 更新远程节点
 func (p *HTTPPool) Set(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.peers = consistenthash.NewMap(p.opts.replicas, nil)
+	p.peers = p.opts.NewPartitioner()
 	// 映射节点和getter关系
 	p.peers.Add(peers...)
 	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath}
+		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath, client: p.client, auth: p.opts.Auth}
 	}
 }
 
 // PickPeer pick a peer
 func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
+	peer, ok, _ := p.pick(key, false)
+	return peer, ok
+}
+
+// pickPeerOrSelf 同PickPeer，key属于本节点时与远程节点一样记录负载，避免Bounded认为本节点始终空闲
+func (p *HTTPPool) pickPeerOrSelf(key string) (PeerGetter, bool, func()) {
+	return p.pick(key, true)
+}
+
+// pickOwner 返回key唯一的所属节点，不按负载选择也不记录负载，用于租约、写入与删除
+func (p *HTTPPool) pickOwner(key string) (PeerGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.peers == nil {
 		return nil, false
 	}
-	if peer := p.peers.Get(key); peer != "" && peer != p.self {
+	var peer string
+	if op, ok := p.peers.(ownerPartitioner); ok {
+		peer = op.Owner(key)
+	} else {
+		peer = p.peers.Get(key)
+	}
+	if peer != "" && peer != p.self {
 		return p.httpGetters[peer], true
 	}
-
 	return nil, false
 }
 
+func (p *HTTPPool) pick(key string, trackSelf bool) (PeerGetter, bool, func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	done := func() {}
+	if p.peers == nil {
+		return nil, false, done
+	}
+	peer := p.peers.Get(key)
+	lr, tracked := p.peers.(loadReporter)
+	if peer != "" && peer != p.self {
+		if tracked {
+			lr.Inc(peer)
+			return &loadTrackingGetter{httpGetter: p.httpGetters[peer], peer: peer, lr: lr}, true, done
+		}
+		return p.httpGetters[peer], true, done
+	}
+	if peer == p.self && tracked && trackSelf {
+		lr.Inc(peer)
+		var once sync.Once
+		done = func() { once.Do(func() { lr.Done(peer) }) }
+	}
+	return nil, false, done
+}
+
+// loadTrackingGetter 请求结束后向Partitioner上报节点负载减少
+// 同一次PickPeer可能发出多个请求（如回退加载时申请与释放租约），只在第一个请求结束时上报
+type loadTrackingGetter struct {
+	*httpGetter
+	peer string
+	lr   loadReporter
+	once sync.Once
+}
+
+func (g *loadTrackingGetter) done() {
+	g.once.Do(func() { g.lr.Done(g.peer) })
+}
+
+func (g *loadTrackingGetter) Get(ctx context.Context, req *ccachepb.Request) (*ccachepb.Response, error) {
+	defer g.done()
+	return g.httpGetter.Get(ctx, req)
+}
+
+func (g *loadTrackingGetter) CompareAndSet(ctx context.Context, req *ccachepb.SetRequest) (*ccachepb.SetResponse, error) {
+	defer g.done()
+	return g.httpGetter.CompareAndSet(ctx, req)
+}
+
+func (g *loadTrackingGetter) Lease(ctx context.Context, req *ccachepb.LeaseRequest) (*ccachepb.LeaseResponse, error) {
+	defer g.done()
+	return g.httpGetter.Lease(ctx, req)
+}
+
+func (g *loadTrackingGetter) Delete(ctx context.Context, req *ccachepb.Request) error {
+	defer g.done()
+	return g.httpGetter.Delete(ctx, req)
+}
+
 var _ PeerPicker = (*HTTPPool)(nil)
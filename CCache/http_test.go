@@ -0,0 +1,74 @@
+package ccache
+
+import (
+	"ccache/consistenthash"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickPeerPartitioner(t *testing.T) {
+	self := "http://localhost:8081"
+	other := "http://localhost:8082"
+	bounded := consistenthash.NewBounded(3, 0.25, nil)
+	pool := NewHTTPPoolWithOpts(self, HTTPPoolOptions{
+		NewPartitioner: func() Partitioner { return bounded },
+	})
+	pool.Set(self, other)
+
+	var picked PeerGetter
+	for i := 0; i < 100 && picked == nil; i++ {
+		if peer, ok := pool.PickPeer(string(rune('a' + i))); ok {
+			picked = peer
+		}
+	}
+	if picked == nil {
+		t.Fatal("no key mapped to remote peer")
+	}
+	assert.Equal(t, int64(1), bounded.Load(other))
+	assert.IsType(t, &loadTrackingGetter{}, picked)
+
+	// 属于本节点的key在本地加载期间同样计入负载
+	found := false
+	for i := 0; i < 100 && !found; i++ {
+		key := string(rune('a' + i))
+		if bounded.Get(key) != self {
+			continue
+		}
+		found = true
+		_, ok, done := pool.pickPeerOrSelf(key)
+		assert.False(t, ok)
+		assert.Equal(t, int64(1), bounded.Load(self))
+		done()
+		done()
+		assert.Equal(t, int64(0), bounded.Load(self))
+		// PickPeer的调用方不会上报本地加载结束，不计入负载
+		_, ok = pool.PickPeer(key)
+		assert.False(t, ok)
+		assert.Equal(t, int64(0), bounded.Load(self))
+	}
+	assert.True(t, found)
+
+	// 租约与写入按不考虑负载的所属节点选择，不随本节点统计的负载变化
+	for i := 0; i < 100; i++ {
+		key := string(rune('a' + i))
+		bounded.Inc(other)
+		bounded.Inc(other)
+		owner, ok := pool.pickOwner(key)
+		if bounded.Owner(key) == other {
+			assert.True(t, ok)
+			assert.IsType(t, &httpGetter{}, owner)
+		} else {
+			assert.False(t, ok)
+		}
+		bounded.Done(other)
+		bounded.Done(other)
+	}
+
+	pool = NewHTTPPoolWithOpts(self, HTTPPoolOptions{
+		NewPartitioner: func() Partitioner { return consistenthash.NewJump(nil) },
+	})
+	pool.Set(self)
+	_, ok := pool.PickPeer("key")
+	assert.False(t, ok)
+}
@@ -0,0 +1,302 @@
+/*
+所属节点上的加载租约，保证同一个key在整个集群内只从数据源加载一次
+所属节点自身的加载与其他节点的回退加载都需要先获取租约，未获取到租约的一方等待持有者的结果
+*/
+package ccache
+
+import (
+	"ccache/ccachepb"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const defaultLeaseTTL = 2 * time.Second
+
+// errLeaseRejected 释放时租约已过期或已被他人获取
+var errLeaseRejected = errors.New("lease expired or held by another loader")
+
+// lease 某个key的加载租约
+type lease struct {
+	key     string
+	token   uint64
+	expires time.Time
+	done    chan struct{} // 持有者释放租约后关闭
+	value   ByteView
+	err     error
+}
+
+type leaseTable struct {
+	mu     sync.Mutex // guards
+	leases map[string]*lease
+	ttl    time.Duration
+	swept  time.Time
+}
+
+func newLeaseTable(ttl time.Duration) *leaseTable {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	return &leaseTable{
+		leases: make(map[string]*lease),
+		ttl:    ttl,
+	}
+}
+
+// acquire 获取租约，granted为false时返回当前持有的租约
+func (t *leaseTable) acquire(key string) (l *lease, granted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.sweep(now)
+	if l, ok := t.leases[key]; ok && now.Before(l.expires) {
+		return l, false
+	}
+	l = &lease{
+		key:     key,
+		token:   newLeaseToken(),
+		expires: now.Add(t.ttl),
+		done:    make(chan struct{}),
+	}
+	t.leases[key] = l
+	return l, true
+}
+
+// sweep 删除所有已过期的租约，每个ttl最多执行一次
+func (t *leaseTable) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.ttl {
+		return
+	}
+	for key, l := range t.leases {
+		if !now.Before(l.expires) {
+			delete(t.leases, key)
+		}
+	}
+	t.swept = now
+}
+
+// claim 校验token，租约仍有效时返回租约，持有者随后填充缓存并调用finish
+func (t *leaseTable) claim(key string, token uint64) (*lease, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.leases[key]
+	if !ok || l.token != token || !time.Now().Before(l.expires) {
+		return nil, false
+	}
+	return l, true
+}
+
+// finish 移除租约并唤醒等待者
+func (t *leaseTable) finish(l *lease, value ByteView, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.leases[l.key] == l {
+		delete(t.leases, l.key)
+	}
+	l.value, l.err = value, err
+	close(l.done)
+}
+
+// release 释放租约并唤醒等待者，租约已过期或token不匹配时返回false
+func (t *leaseTable) release(key string, token uint64, value ByteView, err error) bool {
+	l, ok := t.claim(key, token)
+	if !ok {
+		return false
+	}
+	t.finish(l, value, err)
+	return true
+}
+
+// newLeaseToken 随机生成的token，其他节点无法猜测未授予自己的租约
+func newLeaseToken() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("ccache: read random lease token: " + err.Error())
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// wait 等待租约释放，租约过期时expired为true，调用方应重新获取租约
+func (t *leaseTable) wait(ctx context.Context, l *lease) (value ByteView, err error, expired bool) {
+	timer := time.NewTimer(time.Until(l.expires))
+	defer timer.Stop()
+	select {
+	case <-l.done:
+		return l.value, l.err, false
+	case <-timer.C:
+		return ByteView{}, nil, true
+	case <-ctx.Done():
+		return ByteView{}, ctx.Err(), false
+	}
+}
+
+// getLocally 持有租约后从数据源加载，其他加载方（包括其他节点）等待结果
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	for {
+		l, granted := g.leases.acquire(key)
+		if !granted {
+			value, err, expired := g.leases.wait(ctx, l)
+			if expired {
+				continue
+			}
+			return value, err
+		}
+
+		value, hint, err := g.loadFromOrigin(ctx, key)
+		if _, ok := g.leases.claim(key, l.token); !ok {
+			// 加载超过了租约有效期，结果只返回给调用方，由新的持有者写入缓存
+			return value, err
+		}
+		if err == nil && !hint.NoCache {
+			// write cache
+			value = g.populateCache(key, value)
+		}
+		if errors.Is(err, ErrNotFound) {
+			g.negative.add(key)
+		}
+		g.leases.finish(l, value, err)
+		return value, err
+	}
+}
+
+// loadFromOrigin 从数据源加载，数据源提示不缓存的值没有版本
+func (g *Group) loadFromOrigin(ctx context.Context, key string) (ByteView, Hint, error) {
+	if err := g.checkOriginQuota(key); err != nil {
+		return ByteView{}, Hint{}, err
+	}
+	start := time.Now()
+	var (
+		b    []byte
+		hint Hint
+		err  error
+		// 序列化proto得到的切片只属于缓存，无需拷贝
+		owned bool
+	)
+	switch getter := g.getter.(type) {
+	case ProtoGetter:
+		var m proto.Message
+		if m, err = getter.GetProto(key); err == nil {
+			b, err = proto.Marshal(m)
+			owned = true
+		}
+	case HintGetter:
+		b, hint, err = getter.GetWithHint(key)
+	default:
+		b, err = g.getter.Get(key)
+	}
+	notify(g.observer, ctx, Event{Type: EventLoad, Group: g.name, Key: key, Duration: time.Since(start), Err: err})
+	if err != nil {
+		return ByteView{}, hint, err
+	}
+	if !owned {
+		b = cloneBytes(b)
+	}
+	if hint.NoCache {
+		atomic.AddInt64(&g.stats.NotCached, 1)
+		return ByteView{b: b, loadedAt: time.Now()}, hint, nil
+	}
+	return ByteView{b: b, version: g.nextVersion(), loadedAt: time.Now()}, hint, nil
+}
+
+// serveLease 处理其他节点的租约请求：已缓存时直接返回值，租约被占用时等待持有者的结果
+func (g *Group) serveLease(ctx context.Context, req *ccachepb.LeaseRequest) *ccachepb.LeaseResponse {
+	key := req.GetKey()
+	if req.GetRelease() {
+		// 先校验token，过期或伪造的释放不能写入缓存
+		l, ok := g.leases.claim(key, req.GetToken())
+		if !ok {
+			return &ccachepb.LeaseResponse{Error: errLeaseRejected.Error()}
+		}
+		var (
+			value ByteView
+			err   error
+		)
+		if req.GetError() != "" {
+			err = remoteError(req.GetError(), req.GetNotFound(), req.GetRateLimited())
+			if errors.Is(err, ErrNotFound) {
+				g.negative.add(key)
+			}
+		} else if req.GetNoCache() {
+			value = ByteView{b: cloneBytes(req.GetValue()), loadedAt: time.Now()}
+		} else {
+			value = g.populateCache(key, ByteView{b: cloneBytes(req.GetValue()), version: g.nextVersion(), loadedAt: time.Now()})
+		}
+		g.leases.finish(l, value, err)
+		return &ccachepb.LeaseResponse{Value: value.b, Version: value.version}
+	}
+
+	for {
+		if v, ok := g.mainCache.get(key); ok {
+			return &ccachepb.LeaseResponse{Value: v.b, Version: v.version}
+		}
+		if g.negative.contains(key) {
+			return &ccachepb.LeaseResponse{Error: ErrNotFound.Error(), NotFound: true}
+		}
+		l, granted := g.leases.acquire(key)
+		if granted {
+			return &ccachepb.LeaseResponse{Granted: true, Token: l.token}
+		}
+		value, err, expired := g.leases.wait(ctx, l)
+		if expired {
+			continue
+		}
+		if err != nil {
+			notFound, rateLimited := errorCodes(err)
+			return &ccachepb.LeaseResponse{Error: err.Error(), NotFound: notFound, RateLimited: rateLimited}
+		}
+		return &ccachepb.LeaseResponse{Value: value.b, Version: value.version}
+	}
+}
+
+// loadWithPeerLease 从所属节点获取失败后的回退加载：先向所属节点申请租约，
+// 获得租约后从数据源加载并交给所属节点缓存，否则等待租约持有者的结果
+// 所属节点不可达时只能直接从数据源加载
+func (g *Group) loadWithPeerLease(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
+	leaser, ok := peer.(PeerLeaser)
+	if !ok {
+		value, _, err := g.loadFromOrigin(ctx, key)
+		return value, err
+	}
+	res, err := leaser.Lease(ctx, &ccachepb.LeaseRequest{Group: g.name, Key: key})
+	if err != nil {
+		g.logger.Error("acquire lease from peer failed", "group", g.name, "key", key, "peer", peerName(peer), "err", err)
+		value, _, err := g.loadFromOrigin(ctx, key)
+		return value, err
+	}
+	if !res.GetGranted() {
+		if res.GetError() != "" {
+			err = remoteError(res.GetError(), res.GetNotFound(), res.GetRateLimited())
+			if errors.Is(err, ErrNotFound) {
+				g.negative.add(key)
+			}
+			return ByteView{}, err
+		}
+		return ByteView{b: res.GetValue(), version: res.GetVersion()}, nil
+	}
+
+	value, hint, err := g.loadFromOrigin(ctx, key)
+	release := &ccachepb.LeaseRequest{Group: g.name, Key: key, Token: res.GetToken(), Release: true, NoCache: hint.NoCache}
+	if err != nil {
+		release.Error = err.Error()
+		release.NotFound, release.RateLimited = errorCodes(err)
+	} else {
+		release.Value = value.b
+	}
+	if errors.Is(err, ErrNotFound) {
+		g.negative.add(key)
+	}
+	if res, rerr := leaser.Lease(ctx, release); rerr != nil {
+		g.logger.Error("release lease to peer failed", "group", g.name, "key", key, "peer", peerName(peer), "err", rerr)
+	} else if res.GetError() != "" {
+		g.logger.Error("release lease to peer rejected", "group", g.name, "key", key, "peer", peerName(peer), "err", res.GetError())
+	} else if err == nil {
+		value.version = res.GetVersion()
+	}
+	return value, err
+}
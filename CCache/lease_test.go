@@ -0,0 +1,142 @@
+package ccache
+
+import (
+	"ccache/ccachepb"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseTable(t *testing.T) {
+	table := newLeaseTable(50 * time.Millisecond)
+	l, granted := table.acquire("key")
+	assert.True(t, granted)
+
+	held, granted := table.acquire("key")
+	assert.False(t, granted)
+	assert.Equal(t, l.token, held.token)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		table.release("key", l.token, ByteView{b: []byte("value")}, nil)
+	}()
+	value, err, expired := table.wait(context.Background(), held)
+	assert.False(t, expired)
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value.String())
+
+	// 租约释放后可以重新获取
+	l, granted = table.acquire("key")
+	assert.True(t, granted)
+	assert.False(t, table.release("key", l.token+1, ByteView{}, nil))
+	assert.True(t, table.release("key", l.token, ByteView{}, errors.New("origin down")))
+}
+
+func TestLeaseExpired(t *testing.T) {
+	table := newLeaseTable(20 * time.Millisecond)
+	stuck, _ := table.acquire("key")
+
+	held, granted := table.acquire("key")
+	assert.False(t, granted)
+	_, _, expired := table.wait(context.Background(), held)
+	assert.True(t, expired)
+
+	// 持有者未在有效期内释放，其他加载方接管租约，旧持有者的释放被忽略
+	l, granted := table.acquire("key")
+	assert.True(t, granted)
+	assert.False(t, table.release("key", stuck.token, ByteView{}, nil))
+	assert.True(t, table.release("key", l.token, ByteView{}, nil))
+}
+
+func TestGetLocallyWaitsForLease(t *testing.T) {
+	loads := 0
+	group := NewGroup("lease", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("origin"), nil
+	}))
+
+	// 其他节点持有租约时，本节点等待其结果而不是访问数据源
+	l, _ := group.leases.acquire("key")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		group.serveLease(context.Background(), &ccachepb.LeaseRequest{
+			Key:     "key",
+			Token:   l.token,
+			Release: true,
+			Value:   []byte("remote"),
+		})
+	}()
+	value, err := group.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "remote", value.String())
+	assert.Equal(t, 0, loads)
+}
+
+func TestServeLeaseRejectsStaleRelease(t *testing.T) {
+	group := NewGroup("lease-stale", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("origin"), nil
+	}))
+	l, _ := group.leases.acquire("key")
+
+	// token不匹配的释放被拒绝，且不写入缓存
+	res := group.serveLease(context.Background(), &ccachepb.LeaseRequest{
+		Key: "key", Token: l.token + 1, Release: true, Value: []byte("forged"),
+	})
+	assert.Equal(t, errLeaseRejected.Error(), res.GetError())
+	_, ok := group.mainCache.get("key")
+	assert.False(t, ok)
+
+	res = group.serveLease(context.Background(), &ccachepb.LeaseRequest{
+		Key: "key", Token: l.token, Release: true, Value: []byte("remote"),
+	})
+	assert.Empty(t, res.GetError())
+	v, ok := group.mainCache.get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "remote", v.String())
+}
+
+func TestLeaseSweep(t *testing.T) {
+	table := newLeaseTable(10 * time.Millisecond)
+	l, _ := table.acquire("a")
+	time.Sleep(20 * time.Millisecond)
+
+	// 获取其他key时清理已过期的租约
+	table.acquire("b")
+	table.mu.Lock()
+	_, ok := table.leases["a"]
+	table.mu.Unlock()
+	assert.False(t, ok)
+	assert.False(t, table.release("a", l.token, ByteView{}, nil))
+}
+
+func TestLeaseNotFoundCode(t *testing.T) {
+	group := NewGroupWithOpts("lease-not-found", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("origin"), nil
+	}), GroupOptions{NegativeTTL: time.Minute})
+	l, _ := group.leases.acquire("key")
+
+	// 包装后的错误信息与哨兵错误不同，按标记还原
+	wrapped := fmt.Errorf("%s: %w", "key", ErrNotFound)
+	notFound, rateLimited := errorCodes(wrapped)
+	res := group.serveLease(context.Background(), &ccachepb.LeaseRequest{
+		Key: "key", Token: l.token, Release: true,
+		Error: wrapped.Error(), NotFound: notFound, RateLimited: rateLimited,
+	})
+	assert.Empty(t, res.GetError())
+	assert.True(t, group.negative.contains("key"))
+
+	res = group.serveLease(context.Background(), &ccachepb.LeaseRequest{Key: "key"})
+	err := remoteError(res.GetError(), res.GetNotFound(), res.GetRateLimited())
+	assert.Equal(t, ErrNotFound, err)
+
+	err = remoteError(wrapped.Error(), true, false)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, "key: ccache: not found", err.Error())
+	err = remoteError("origin rate limited", false, true)
+	assert.True(t, errors.Is(err, ErrOriginRateLimited))
+	assert.False(t, errors.Is(remoteError(ErrNotFound.Error(), false, false), ErrNotFound))
+}
@@ -0,0 +1,56 @@
+/*
+结构化日志接口
+*/
+package ccache
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger 结构化日志接口，kv为交替出现的键值对，如 "group", "scores", "key", "Tom"
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// stdLogger 基于标准库log的默认实现，输出形如 [INFO] msg k1=v1 k2=v2
+type stdLogger struct {
+	debug bool
+}
+
+// NewStdLogger create a logger writing to the standard log package, debug为false时忽略Debug日志
+func NewStdLogger(debug bool) Logger {
+	return stdLogger{debug: debug}
+}
+
+var defaultLogger = NewStdLogger(false)
+
+func (l stdLogger) Debug(msg string, kv ...interface{}) {
+	if l.debug {
+		l.output("DEBUG", msg, kv)
+	}
+}
+
+func (l stdLogger) Info(msg string, kv ...interface{}) {
+	l.output("INFO", msg, kv)
+}
+
+func (l stdLogger) Error(msg string, kv ...interface{}) {
+	l.output("ERROR", msg, kv)
+}
+
+func (l stdLogger) output(level, msg string, kv []interface{}) {
+	var b strings.Builder
+	b.WriteString("[" + level + "] " + msg)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fmt.Fprintf(&b, " %v=%v", kv[i], kv[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v=", kv[i])
+		}
+	}
+	log.Println(b.String())
+}
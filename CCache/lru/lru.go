@@ -53,24 +53,91 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 	return nil, false
 }
 
+// Peek 获取元素但不改变其访问顺序
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	if ele, ok := c.cache[key]; ok {
+		return ele.Value.(*entry).value, true
+	}
+	return nil, false
+}
+
 // RemoveOldest 删除最近最久未被使用的队首
 func (c *Cache) RemoveOldest() {
 	ele := c.linkedList.Back()
 	if ele != nil {
-		// 从底层双向链表中移除对应节点
-		c.linkedList.Remove(ele)
+		c.removeElement(ele)
+	}
+}
+
+// Oldest 返回最久未使用的key，即容量不足时下一个被淘汰的节点
+func (c *Cache) Oldest() (key string, ok bool) {
+	ele := c.linkedList.Back()
+	if ele == nil {
+		return "", false
+	}
+	return ele.Value.(*entry).key, true
+}
+
+// Victims 写入size字节的新条目时将被淘汰的key，按最久未使用到最近访问排序，不修改Cache
+func (c *Cache) Victims(size int64) []string {
+	var keys []string
+	used := c.usedBytes + size
+	for ele := c.linkedList.Back(); ele != nil && c.maxBytes != 0 && used > c.maxBytes; ele = ele.Prev() {
+		kv := ele.Value.(*entry)
+		keys = append(keys, kv.key)
+		used -= int64(len(kv.key)) + int64(kv.value.Len())
+	}
+	return keys
+}
+
+// Remove 删除指定key
+func (c *Cache) Remove(key string) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele)
+		return true
+	}
+	return false
+}
+
+func (c *Cache) removeElement(ele *list.Element) {
+	// 从底层双向链表中移除对应节点
+	c.linkedList.Remove(ele)
+	kv := ele.Value.(*entry)
+	// 从cache中删除对应key
+	delete(c.cache, kv.key)
+	c.usedBytes -= int64(len(kv.key)) + int64(kv.value.Len())
+	if c.onEvicted != nil {
+		c.onEvicted(kv.key, kv.value)
+	}
+}
+
+// Range 按最近访问到最久未访问的顺序遍历，fn返回false时停止
+// 遍历过程中不能修改Cache
+func (c *Cache) Range(fn func(key string, value Value) bool) {
+	for ele := c.linkedList.Front(); ele != nil; ele = ele.Next() {
 		kv := ele.Value.(*entry)
-		// 从cache中删除对应key
-		delete(c.cache, kv.key)
-		c.usedBytes -= int64(len(kv.key)) + int64(kv.value.Len())
-		if c.onEvicted != nil {
-			c.onEvicted(kv.key, kv.value)
+		if !fn(kv.key, kv.value) {
+			return
 		}
 	}
 }
 
+// Keys 返回所有key的快照，按最近访问到最久未访问排序
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.linkedList.Len())
+	for ele := c.linkedList.Front(); ele != nil; ele = ele.Next() {
+		keys = append(keys, ele.Value.(*entry).key)
+	}
+	return keys
+}
+
 // Add add entry
 func (c *Cache) Add(key string, value Value) {
+	// 单个条目超过最大容量时无法写入，否则会淘汰所有节点后再淘汰自身
+	if c.maxBytes != 0 && int64(len(key))+int64(value.Len()) > c.maxBytes {
+		c.Remove(key)
+		return
+	}
 	// 不存在记录则添加至队尾，存在则更新
 	if ele, ok := c.cache[key]; !ok {
 		ele := c.linkedList.PushFront(&entry{key: key, value: value})
@@ -92,6 +159,24 @@ func (c *Cache) Add(key string, value Value) {
 	}
 }
 
+// SetMaxBytes 调整最大内存，超出时立即淘汰最久未使用的节点，为0时表示不设限
+func (c *Cache) SetMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+	for c.maxBytes != 0 && c.usedBytes > c.maxBytes {
+		c.RemoveOldest()
+	}
+}
+
+// MaxBytes return max bytes
+func (c *Cache) MaxBytes() int64 {
+	return c.maxBytes
+}
+
+// UsedBytes return used bytes
+func (c *Cache) UsedBytes() int64 {
+	return c.usedBytes
+}
+
 // Len return length of linkedList
 func (c *Cache) Len() int {
 	return c.linkedList.Len()
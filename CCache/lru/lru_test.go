@@ -67,3 +67,90 @@ func TestUpdate(t *testing.T) {
 	assert.Equal(t, v, newValue)
 
 }
+
+func TestRangeAndKeys(t *testing.T) {
+	cache := New(0, nil)
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+	cache.Add("k3", String("v3"))
+	cache.Get("k1")
+
+	assert.Equal(t, []string{"k1", "k3", "k2"}, cache.Keys())
+
+	visited := make([]string, 0)
+	cache.Range(func(key string, value Value) bool {
+		visited = append(visited, key)
+		return len(visited) < 2
+	})
+	assert.Equal(t, []string{"k1", "k3"}, visited)
+}
+
+func TestPeekAndRemove(t *testing.T) {
+	evicted := make([]string, 0)
+	cache := New(0, func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+
+	v, ok := cache.Peek("k1")
+	assert.True(t, ok)
+	assert.Equal(t, String("v1"), v)
+	// Peek不改变访问顺序
+	assert.Equal(t, []string{"k2", "k1"}, cache.Keys())
+
+	assert.True(t, cache.Remove("k1"))
+	assert.False(t, cache.Remove("k1"))
+	assert.Equal(t, 1, cache.Len())
+	assert.Equal(t, []string{"k1"}, evicted)
+}
+
+func TestSetMaxBytes(t *testing.T) {
+	evicted := make([]string, 0)
+	cache := New(0, func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+	cache.Add("k3", String("v3"))
+	assert.Equal(t, int64(12), cache.UsedBytes())
+
+	cache.SetMaxBytes(8)
+	assert.Equal(t, []string{"k1"}, evicted)
+	assert.Equal(t, int64(8), cache.MaxBytes())
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestAddTooLarge(t *testing.T) {
+	cache := New(10, nil)
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+
+	// 超过最大容量的条目不写入，也不会淘汰已有节点
+	cache.Add("big", String("0123456789"))
+	_, ok := cache.Get("big")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Len())
+
+	oldest, ok := cache.Oldest()
+	assert.True(t, ok)
+	assert.Equal(t, "k1", oldest)
+
+	// 更新为超大值时删除旧值，避免读到过期数据
+	cache.Add("k1", String("0123456789"))
+	_, ok = cache.Get("k1")
+	assert.False(t, ok)
+	assert.Equal(t, int64(4), cache.UsedBytes())
+}
+
+func TestVictims(t *testing.T) {
+	cache := New(12, nil)
+	cache.Add("k1", String("v1"))
+	cache.Add("k2", String("v2"))
+	cache.Add("k3", String("v3"))
+
+	assert.Empty(t, cache.Victims(0))
+	assert.Equal(t, []string{"k1"}, cache.Victims(4))
+	assert.Equal(t, []string{"k1", "k2"}, cache.Victims(5))
+	assert.Equal(t, 3, cache.Len())
+}
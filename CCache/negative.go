@@ -0,0 +1,109 @@
+/*
+负缓存：记录数据源中不存在的key，有效期内直接返回ErrNotFound而不再访问数据源
+*/
+package ccache
+
+import (
+	"ccache/lru"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 数据源中不存在该key，Getter返回该错误（可包装）时结果会进入负缓存
+var ErrNotFound = errors.New("ccache: not found")
+
+type negativeEntry struct {
+	expires time.Time
+}
+
+func (e negativeEntry) Len() int {
+	return 8
+}
+
+type negativeCache struct {
+	mu  sync.Mutex // guards
+	lru *lru.Cache
+	ttl time.Duration
+}
+
+// newNegativeCache ttl不大于0时返回nil，即不开启负缓存
+func newNegativeCache(ttl time.Duration, maxBytes int64) *negativeCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &negativeCache{lru: lru.New(maxBytes, nil), ttl: ttl}
+}
+
+func (c *negativeCache) add(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Add(key, negativeEntry{expires: time.Now().Add(c.ttl)})
+}
+
+// contains key在负缓存中且未过期，过期的记录会被删除
+func (c *negativeCache) contains(key string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.lru.Get(key)
+	if !ok {
+		return false
+	}
+	if time.Now().After(v.(negativeEntry).expires) {
+		c.lru.Remove(key)
+		return false
+	}
+	return true
+}
+
+func (c *negativeCache) remove(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Remove(key)
+}
+
+// remoteError 还原其他节点传递的错误，notFound与rateLimited标记原错误是否为（或包装了）对应的哨兵错误，
+// 使errors.Is对包装后的错误仍然有效
+func remoteError(msg string, notFound, rateLimited bool) error {
+	var sentinel error
+	switch {
+	case notFound:
+		sentinel = ErrNotFound
+	case rateLimited:
+		sentinel = ErrOriginRateLimited
+	default:
+		return errors.New(msg)
+	}
+	if msg == sentinel.Error() {
+		return sentinel
+	}
+	return &remoteSentinelError{msg: msg, err: sentinel}
+}
+
+// errorCodes 返回remoteError需要的标记
+func errorCodes(err error) (notFound, rateLimited bool) {
+	return errors.Is(err, ErrNotFound), errors.Is(err, ErrOriginRateLimited)
+}
+
+// remoteSentinelError 保留原错误信息并包装哨兵错误
+type remoteSentinelError struct {
+	msg string
+	err error
+}
+
+func (e *remoteSentinelError) Error() string {
+	return e.msg
+}
+
+func (e *remoteSentinelError) Unwrap() error {
+	return e.err
+}
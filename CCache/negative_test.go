@@ -0,0 +1,82 @@
+package ccache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegativeCache(t *testing.T) {
+	var loads int32
+	group := NewGroupWithOpts("negative", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}), GroupOptions{NegativeTTL: 50 * time.Millisecond})
+
+	_, err := group.Get("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = group.Get("missing")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	assert.Equal(t, int64(1), group.Stats().NegativeHits)
+
+	// 过期后重新加载
+	time.Sleep(60 * time.Millisecond)
+	group.Get("missing")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+
+	// 写入后不再返回不存在
+	_, err = group.Set("missing", []byte("v"))
+	assert.Nil(t, err)
+	value, err := group.Get("missing")
+	assert.Nil(t, err)
+	assert.Equal(t, "v", value.String())
+}
+
+func TestNegativeCacheRemote(t *testing.T) {
+	var loads, created int32
+	registry := NewRegistry()
+	_, err := registry.NewGroup("negative-remote", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		if key == "missing" && atomic.LoadInt32(&created) == 0 {
+			return nil, ErrNotFound
+		}
+		return []byte(key), nil
+	}), GroupOptions{NegativeTTL: time.Minute})
+	assert.Nil(t, err)
+	srv := httptest.NewServer(NewHTTPPoolWithOpts("owner", HTTPPoolOptions{Registry: registry}))
+	defer srv.Close()
+
+	group := NewGroupWithOpts("negative-remote", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		t.Fatal("should load from owner")
+		return nil, nil
+	}), GroupOptions{NegativeTTL: time.Minute})
+	group.RegisterPeers(&fixedPicker{peer: &httpGetter{baseURL: srv.URL + defaultBasePath, client: http.DefaultClient}})
+
+	// 所属节点返回不存在，本节点同样记入负缓存
+	for i := 0; i < 2; i++ {
+		_, err = group.Get("missing")
+		assert.Equal(t, ErrNotFound, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	assert.Equal(t, int64(1), group.Stats().NegativeHits)
+
+	// 非所属节点的Delete同样清除所属节点的负缓存
+	atomic.StoreInt32(&created, 1)
+	assert.Nil(t, group.Delete("missing"))
+	value, err := group.Get("missing")
+	assert.Nil(t, err)
+	assert.Equal(t, "missing", value.String())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+
+	// 远程值带有所属节点的加载时间
+	value, err = group.Get("present")
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), value.LoadedAt(), time.Second)
+}
@@ -0,0 +1,77 @@
+/*
+缓存事件观察者，用于统计、监控与链路追踪
+*/
+package ccache
+
+import (
+	"context"
+	"time"
+)
+
+// EventType 事件类型
+type EventType int
+
+const (
+	// EventHit 本地缓存命中
+	EventHit EventType = iota
+	// EventMiss 本地缓存未命中
+	EventMiss
+	// EventWait 等待其他并发请求的加载结果（singleflight）
+	EventWait
+	// EventPeerFetch 从远程节点获取
+	EventPeerFetch
+	// EventLoad 调用Getter从数据源加载
+	EventLoad
+	// EventEviction 本地缓存淘汰
+	EventEviction
+	// EventServe HTTPPool处理来自其他节点的请求
+	EventServe
+)
+
+var eventNames = [...]string{"hit", "miss", "wait", "peer_fetch", "load", "eviction", "serve"}
+
+func (t EventType) String() string {
+	if int(t) < len(eventNames) {
+		return eventNames[t]
+	}
+	return "unknown"
+}
+
+// Event 缓存事件
+type Event struct {
+	Type  EventType
+	Group string
+	Key   string
+	// 远程节点，仅EventPeerFetch有效
+	Peer string
+	// 事件耗时，EventEviction为0
+	Duration time.Duration
+	Err      error
+	// 请求所属的链路，见TraceFromContext
+	TraceID string
+	SpanID  string
+}
+
+// Observer 事件观察者，OnEvent在请求路径上同步调用，实现应尽量轻量
+// EventEviction在持有缓存锁时触发，不能在其中访问同一个Group
+type Observer interface {
+	OnEvent(ctx context.Context, e Event)
+}
+
+// ObserverFunc callback func
+type ObserverFunc func(ctx context.Context, e Event)
+
+// OnEvent callback
+func (f ObserverFunc) OnEvent(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+func notify(o Observer, ctx context.Context, e Event) {
+	if o == nil {
+		return
+	}
+	if tc, ok := TraceFromContext(ctx); ok {
+		e.TraceID, e.SpanID = tc.TraceID, tc.SpanID
+	}
+	o.OnEvent(ctx, e)
+}
@@ -0,0 +1,111 @@
+package ccache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) OnEvent(ctx context.Context, e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) types() []EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]EventType, 0, len(r.events))
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+type fixedPicker struct {
+	peer PeerGetter
+}
+
+func (p fixedPicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.peer, true
+}
+
+func TestObserverEvents(t *testing.T) {
+	rec := &recorder{}
+	group := NewGroupWithOpts("observer", 6, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("vv"), nil
+	}), GroupOptions{Observer: rec})
+
+	_, _ = group.Get("k1")
+	_, _ = group.Get("k1")
+	// k1 + vv 与 k2 + vv 超过6字节，k1被淘汰
+	_, _ = group.Get("k2")
+
+	assert.Equal(t, []EventType{
+		EventMiss, EventLoad,
+		EventHit,
+		EventMiss, EventLoad, EventEviction,
+	}, rec.types())
+	for _, e := range rec.events {
+		assert.Equal(t, "observer", e.Group)
+		if e.Type != EventEviction {
+			assert.Len(t, e.TraceID, 32)
+		}
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	clientRec, serverRec, poolRec := &recorder{}, &recorder{}, &recorder{}
+	client := NewGroupWithOpts("trace", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		t.Fatal("client should fetch from peer")
+		return nil, nil
+	}), GroupOptions{Observer: clientRec})
+	// 服务端的同名group注册在独立的Registry中
+	registry := NewRegistry()
+	_, err := registry.NewGroup("trace", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value of " + key), nil
+	}), GroupOptions{Observer: serverRec})
+	assert.Nil(t, err)
+
+	pool := NewHTTPPoolWithOpts("server", HTTPPoolOptions{Observer: poolRec, Registry: registry})
+	srv := httptest.NewServer(pool)
+	defer srv.Close()
+	client.RegisterPeers(fixedPicker{peer: &httpGetter{baseURL: srv.URL + defaultBasePath, client: http.DefaultClient}})
+
+	tc := TraceContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331"}
+	value, err := client.GetContext(ContextWithTrace(context.Background(), tc), "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value of key", value.String())
+
+	assert.Equal(t, []EventType{EventMiss, EventPeerFetch}, clientRec.types())
+	assert.Equal(t, srv.URL+defaultBasePath, clientRec.events[1].Peer)
+	assert.Equal(t, []EventType{EventMiss, EventLoad}, serverRec.types())
+	assert.Equal(t, []EventType{EventServe}, poolRec.types())
+
+	// 服务端延续同一链路，但使用新的span
+	for _, e := range append(serverRec.events, poolRec.events...) {
+		assert.Equal(t, tc.TraceID, e.TraceID)
+		assert.NotEqual(t, tc.SpanID, e.SpanID)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tc, ok := parseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	assert.True(t, ok)
+	assert.Equal(t, "b7ad6b7169203331", tc.SpanID)
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", tc.traceparent())
+
+	for _, h := range []string{"", "00-abc-def-01", "00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01"} {
+		_, ok := parseTraceparent(h)
+		assert.False(t, ok, h)
+	}
+}
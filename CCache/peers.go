@@ -2,14 +2,64 @@ package ccache
 
 import (
 	"ccache/ccachepb"
+	"context"
 )
 
 // PeerGetter ...
 type PeerGetter interface {
-	Get(*ccachepb.Request) (*ccachepb.Response, error)
+	Get(context.Context, *ccachepb.Request) (*ccachepb.Response, error)
+}
+
+// PeerSetter 支持写入和删除的远程节点
+type PeerSetter interface {
+	CompareAndSet(context.Context, *ccachepb.SetRequest) (*ccachepb.SetResponse, error)
+	Delete(context.Context, *ccachepb.Request) error
+}
+
+// PeerLeaser 支持加载租约的远程节点
+type PeerLeaser interface {
+	Lease(context.Context, *ccachepb.LeaseRequest) (*ccachepb.LeaseResponse, error)
 }
 
 // PeerPicker ...
 type PeerPicker interface {
 	PickPeer(key string) (PeerGetter, bool)
 }
+
+// Partitioner 将key映射到节点，HTTPPool.PickPeer通过它选择远程节点
+// consistenthash包中的Map、Rendezvous、Jump、Bounded均实现了该接口
+type Partitioner interface {
+	Add(peers ...string)
+	Get(key string) string
+}
+
+// loadReporter 需要感知节点负载的Partitioner（如consistenthash.Bounded）额外实现的接口
+type loadReporter interface {
+	Inc(peer string)
+	Done(peer string)
+}
+
+// ownerPartitioner 按负载选择节点的Partitioner（如consistenthash.Bounded）额外实现的接口
+// Owner返回不考虑负载时key所属的节点，租约与写入需要各节点一致的所属节点
+type ownerPartitioner interface {
+	Owner(key string) string
+}
+
+// ownerPicker 按key的唯一所属节点选择远程节点的PeerPicker，key属于本节点时ok为false
+type ownerPicker interface {
+	pickOwner(key string) (peer PeerGetter, ok bool)
+}
+
+// selfPicker 选择节点时同时记录本节点负载的PeerPicker，如使用consistenthash.Bounded的HTTPPool
+// key属于本节点时ok为false，本节点负载加一，本地加载结束后调用done
+type selfPicker interface {
+	pickPeerOrSelf(key string) (peer PeerGetter, ok bool, done func())
+}
+
+// peerName 返回远程节点地址，用于日志和事件
+func peerName(peer PeerGetter) string {
+	if n, ok := peer.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return ""
+}
@@ -0,0 +1,234 @@
+/*
+Group注册表，不同Registry之间的Group相互隔离
+Group可以归属于某个租户，同一租户下的Group共享缓存容量与数据源加载速率配额
+*/
+package ccache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrGroupExists 同一个Registry中已存在同名的Group
+	ErrGroupExists = errors.New("ccache: group already exists")
+	// ErrQuotaExceeded 创建Group时租户剩余的容量配额不足
+	ErrQuotaExceeded = errors.New("ccache: tenant quota exceeded")
+	// ErrOriginRateLimited 租户从数据源加载的速率超过配额
+	ErrOriginRateLimited = errors.New("ccache: origin rate limited")
+)
+
+// DefaultRegistry 包级函数NewGroup、GetGroup以及未指定Registry的HTTPPool使用的注册表
+var DefaultRegistry = NewRegistry()
+
+// Registry 管理一组Group
+type Registry struct {
+	mu      sync.RWMutex // guards
+	groups  map[string]*Group
+	tenants map[string]*tenant
+}
+
+// TenantQuota 租户配额，为0的项不限制
+type TenantQuota struct {
+	// 租户下所有Group缓存容量之和的上限，设置后Group必须指定容量
+	MaxBytes int64
+	// 租户下所有Group每秒从数据源加载的次数上限
+	OriginQPS float64
+	// 允许突发加载的次数，默认等于OriginQPS且至少为1
+	OriginBurst int
+}
+
+// NewRegistry create a registry
+func NewRegistry() *Registry {
+	return &Registry{
+		groups:  make(map[string]*Group),
+		tenants: make(map[string]*tenant),
+	}
+}
+
+// NewGroup 创建Group，名称重复或超出租户容量配额时返回错误
+func (r *Registry) NewGroup(name string, cacheBytes int64, getter Getter, opts GroupOptions) (*Group, error) {
+	if getter == nil {
+		return nil, errors.New("ccache: nil getter")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.groups[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
+
+	g := newGroup(name, cacheBytes, getter, opts)
+	if opts.Tenant != "" {
+		t := r.tenant(opts.Tenant)
+		if err := t.reserve(g, cacheBytes); err != nil {
+			return nil, err
+		}
+		g.tenant = t
+	}
+	r.groups[name] = g
+	return g, nil
+}
+
+// GetGroup get a group
+func (r *Registry) GetGroup(name string) *Group {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.groups[name]
+}
+
+// RemoveGroup 移除Group并归还其占用的租户配额，移除后的Group不再接收节点请求
+func (r *Registry) RemoveGroup(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[name]
+	if !ok {
+		return
+	}
+	delete(r.groups, name)
+	if g.tenant != nil {
+		g.tenant.mu.Lock()
+		delete(g.tenant.groups, g)
+		g.tenant.mu.Unlock()
+	}
+}
+
+// Groups 返回所有Group的名称，按字典序排序
+func (r *Registry) Groups() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.groups))
+	for name := range r.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTenantQuota 设置租户配额，容量配额只约束之后创建的Group与容量调整
+func (r *Registry) SetTenantQuota(name string, quota TenantQuota) {
+	r.mu.Lock()
+	t := r.tenant(name)
+	r.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quota = quota
+	t.limiter = newTokenBucket(quota.OriginQPS, quota.OriginBurst)
+}
+
+// tenant 获取或创建租户，调用方需持有r.mu
+func (r *Registry) tenant(name string) *tenant {
+	t, ok := r.tenants[name]
+	if !ok {
+		t = &tenant{name: name, groups: make(map[*Group]struct{})}
+		r.tenants[name] = t
+	}
+	return t
+}
+
+// tenant 租户下Group共享的配额
+type tenant struct {
+	name    string
+	mu      sync.Mutex // guards
+	quota   TenantQuota
+	groups  map[*Group]struct{}
+	limiter *tokenBucket
+}
+
+// reserve 为新Group预留容量
+func (t *tenant) reserve(g *Group, cacheBytes int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.quota.MaxBytes > 0 {
+		if cacheBytes <= 0 || t.usedBytes(g)+cacheBytes > t.quota.MaxBytes {
+			return fmt.Errorf("%w: tenant %s", ErrQuotaExceeded, t.name)
+		}
+	}
+	t.groups[g] = struct{}{}
+	return nil
+}
+
+// clampCapacity 将g的新容量限制在租户剩余配额内，调用方需持有t.mu
+// 剩余配额不足时容量为1字节，即不再缓存新的值
+func (t *tenant) clampCapacity(g *Group, cacheBytes int64) int64 {
+	if t.quota.MaxBytes <= 0 {
+		return cacheBytes
+	}
+	available := t.quota.MaxBytes - t.usedBytes(g)
+	if available < 1 {
+		available = 1
+	}
+	if cacheBytes <= 0 || cacheBytes > available {
+		return available
+	}
+	return cacheBytes
+}
+
+// usedBytes 除exclude外其他Group的容量之和，调用方需持有t.mu
+func (t *tenant) usedBytes(exclude *Group) int64 {
+	var used int64
+	for g := range t.groups {
+		if g != exclude {
+			used += g.Capacity()
+		}
+	}
+	return used
+}
+
+func (t *tenant) allowLoad() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limiter.allow(time.Now())
+}
+
+// tokenBucket 令牌桶限速，为nil时不限制
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(burst)
+	if burst <= 0 {
+		b = rate
+		if b < 1 {
+			b = 1
+		}
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// checkOriginQuota 从数据源加载前检查租户的加载速率配额
+func (g *Group) checkOriginQuota(key string) error {
+	if g.tenant == nil || g.tenant.allowLoad() {
+		return nil
+	}
+	atomic.AddInt64(&g.stats.RateLimited, 1)
+	g.logger.Debug("origin load rate limited", "group", g.name, "key", key, "tenant", g.tenant.name)
+	return ErrOriginRateLimited
+}
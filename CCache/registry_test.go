@@ -0,0 +1,102 @@
+package ccache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var echoGetter = GetterFunc(func(key string) ([]byte, error) {
+	return []byte(key), nil
+})
+
+func TestRegistryIsolation(t *testing.T) {
+	r1, r2 := NewRegistry(), NewRegistry()
+	g1, err := r1.NewGroup("scores", 2<<10, echoGetter, GroupOptions{})
+	assert.Nil(t, err)
+	g2, err := r2.NewGroup("scores", 2<<10, echoGetter, GroupOptions{})
+	assert.Nil(t, err)
+
+	assert.Same(t, g1, r1.GetGroup("scores"))
+	assert.Same(t, g2, r2.GetGroup("scores"))
+	assert.Nil(t, GetGroup("scores"))
+
+	// 同一个Registry中拒绝重复的名称
+	_, err = r1.NewGroup("scores", 2<<10, echoGetter, GroupOptions{})
+	assert.True(t, errors.Is(err, ErrGroupExists))
+	assert.Same(t, g1, r1.GetGroup("scores"))
+
+	r1.RemoveGroup("scores")
+	assert.Nil(t, r1.GetGroup("scores"))
+	assert.Empty(t, r1.Groups())
+	assert.Equal(t, []string{"scores"}, r2.Groups())
+
+	NewGroup("registry-default", 2<<10, echoGetter)
+	assert.Panics(t, func() { NewGroup("registry-default", 2<<10, echoGetter) })
+}
+
+func TestTenantBytesQuota(t *testing.T) {
+	r := NewRegistry()
+	r.SetTenantQuota("acme", TenantQuota{MaxBytes: 1000})
+
+	a, err := r.NewGroup("a", 600, echoGetter, GroupOptions{Tenant: "acme"})
+	assert.Nil(t, err)
+	_, err = r.NewGroup("b", 600, echoGetter, GroupOptions{Tenant: "acme"})
+	assert.True(t, errors.Is(err, ErrQuotaExceeded))
+	// 有容量配额时不允许不设限的Group
+	_, err = r.NewGroup("b", 0, echoGetter, GroupOptions{Tenant: "acme"})
+	assert.True(t, errors.Is(err, ErrQuotaExceeded))
+	b, err := r.NewGroup("b", 400, echoGetter, GroupOptions{Tenant: "acme"})
+	assert.Nil(t, err)
+
+	// 调整容量不超过租户剩余配额
+	a.SetCapacity(800)
+	assert.Equal(t, int64(600), a.Capacity())
+	b.SetCapacity(100)
+	a.SetCapacity(0)
+	assert.Equal(t, int64(900), a.Capacity())
+
+	// 其他租户与未指定租户的Group不受影响
+	_, err = r.NewGroup("c", 0, echoGetter, GroupOptions{Tenant: "other"})
+	assert.Nil(t, err)
+
+	// 移除Group后归还配额
+	r.RemoveGroup("a")
+	_, err = r.NewGroup("d", 900, echoGetter, GroupOptions{Tenant: "acme"})
+	assert.Nil(t, err)
+}
+
+func TestTenantOriginQPS(t *testing.T) {
+	r := NewRegistry()
+	r.SetTenantQuota("acme", TenantQuota{OriginQPS: 0.001, OriginBurst: 2})
+	a, err := r.NewGroup("a", 2<<10, echoGetter, GroupOptions{Tenant: "acme"})
+	assert.Nil(t, err)
+	b, err := r.NewGroup("b", 2<<10, echoGetter, GroupOptions{Tenant: "acme"})
+	assert.Nil(t, err)
+
+	_, err = a.Get("k1")
+	assert.Nil(t, err)
+	_, err = b.Get("k1")
+	assert.Nil(t, err)
+	// 同一租户的Group共享加载速率
+	_, err = a.Get("k2")
+	assert.Equal(t, ErrOriginRateLimited, err)
+	assert.Equal(t, int64(1), a.Stats().RateLimited)
+
+	// 已缓存的key不受影响
+	v, err := b.Get("k1")
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", v.String())
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10, 1)
+	now := b.last
+	assert.True(t, b.allow(now))
+	assert.False(t, b.allow(now))
+	assert.True(t, b.allow(now.Add(100*time.Millisecond)))
+	assert.Nil(t, newTokenBucket(0, 0))
+	assert.True(t, (*tokenBucket)(nil).allow(now))
+}
@@ -4,13 +4,42 @@ package singleflight
 Package singleflight
 保证重复请求只执行一次，防止缓存击穿
 */
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit fn中调用了runtime.Goexit
+var errGoexit = errors.New("runtime.Goexit was called")
+
+// PanicError fn发生panic时，所有等待者都会收到该错误，Do的调用方会以它重新panic
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.Value, p.Stack)
+}
 
 // call 表示正在进行的或已经完成的Do请求
 type call struct {
 	wg  sync.WaitGroup // 保证并发
 	val interface{}
 	err error
+	// 共享该结果的其他调用方数量
+	dups  int
+	chans []chan<- Result
+}
+
+// Result DoChan返回的结果
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool
 }
 
 // Group 用于保证请求只执行一次
@@ -20,15 +49,18 @@ type Group struct {
 }
 
 // Do 确保重复发起的Do请求（fn函数）只执行一次
-func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+// shared表示结果是否被多个调用方共享
+func (g *Group) Do(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
 	if c, ok := g.m[key]; ok { // 是否有正在进行的请求
+		c.dups++
 		g.mu.Unlock()
-		c.wg.Wait()         // 如果请求正在进行中，则等待
-		return c.val, c.err // 请求结束，返回结果
+		c.wg.Wait() // 如果请求正在进行中，则等待
+		rethrow(c.err)
+		return c.val, c.err, true // 请求结束，返回结果
 	}
 
 	c := new(call)
@@ -36,12 +68,75 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	g.m[key] = c
 	g.mu.Unlock()
 
-	c.val, c.err = fn()
-	c.wg.Done() // 请求结束
+	g.doCall(c, key, fn)
+	rethrow(c.err)
+	return c.val, c.err, c.dups > 0
+}
 
+// DoChan 同Do，但不阻塞，结果通过返回的channel传递
+// fn发生panic时，channel收到的Err为*PanicError
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	ch := make(chan Result, 1)
 	g.mu.Lock()
-	delete(g.m, key) // 更新g.m
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		c.dups++
+		c.chans = append(c.chans, ch)
+		g.mu.Unlock()
+		return ch
+	}
+
+	c := &call{chans: []chan<- Result{ch}}
+	c.wg.Add(1)
+	g.m[key] = c
 	g.mu.Unlock()
 
-	return c.val, c.err
+	go g.doCall(c, key, fn)
+	return ch
+}
+
+// Forget 忘记正在进行的请求，之后对该key的调用会重新执行fn，而不再等待之前的结果
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
+
+// doCall 执行fn，无论正常返回、panic还是Goexit，都会唤醒所有等待者
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
+	normalReturn := false
+	defer func() {
+		if !normalReturn {
+			if r := recover(); r != nil {
+				c.err = &PanicError{Value: r, Stack: debug.Stack()}
+			} else {
+				c.err = errGoexit
+			}
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		c.wg.Done() // 请求结束
+		if g.m[key] == c {
+			delete(g.m, key) // 更新g.m，Forget后key可能已对应新的请求
+		}
+		for _, ch := range c.chans {
+			ch <- Result{Val: c.val, Err: c.err, Shared: c.dups > 0}
+		}
+	}()
+
+	c.val, c.err = fn()
+	normalReturn = true
+}
+
+// rethrow 将fn中的panic和Goexit传递给Do的调用方
+func rethrow(err error) {
+	if err == errGoexit {
+		runtime.Goexit()
+	}
+	if p, ok := err.(*PanicError); ok {
+		panic(p)
+	}
 }
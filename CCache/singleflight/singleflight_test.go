@@ -10,7 +10,7 @@ import (
 
 func TestDo(t *testing.T) {
 	var g Group
-	v, err := g.Do("test", func() (interface{}, error) {
+	v, err, _ := g.Do("test", func() (interface{}, error) {
 		return "test", nil
 	})
 
@@ -35,7 +35,7 @@ func TestDoOnce(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		wg.Add(1)
 		go func() {
-			v, err := g.Do("test", fn)
+			v, err, _ := g.Do("test", fn)
 			if err != nil {
 				t.Errorf("err:%v", err)
 			}
@@ -56,3 +56,140 @@ func TestDoOnce(t *testing.T) {
 		t.Errorf("got: %d, want: 1", got)
 	}
 }
+
+func TestDoShared(t *testing.T) {
+	var g Group
+	_, _, shared := g.Do("test", func() (interface{}, error) {
+		return "foo", nil
+	})
+	if shared {
+		t.Errorf("single call should not be shared")
+	}
+
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	var sharedCount int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, shared := g.Do("shared", func() (interface{}, error) {
+				return <-ch, nil
+			})
+			if shared {
+				atomic.AddInt32(&sharedCount, 1)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	ch <- "foo"
+	wg.Wait()
+	if got := atomic.LoadInt32(&sharedCount); got != 3 {
+		t.Errorf("got %d shared results, want 3", got)
+	}
+}
+
+func TestDoChan(t *testing.T) {
+	var g Group
+	ch := make(chan string)
+	fn := func() (interface{}, error) {
+		return <-ch, nil
+	}
+	c1 := g.DoChan("test", fn)
+	c2 := g.DoChan("test", fn)
+	ch <- "foo"
+
+	for _, c := range []<-chan Result{c1, c2} {
+		select {
+		case res := <-c:
+			if res.Val != "foo" || res.Err != nil || !res.Shared {
+				t.Errorf("got %+v, want shared foo", res)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("DoChan did not return")
+		}
+	}
+}
+
+func TestForget(t *testing.T) {
+	var g Group
+	block := make(chan struct{})
+	first := g.DoChan("test", func() (interface{}, error) {
+		<-block
+		return "stuck", nil
+	})
+
+	// 忘记卡住的请求后，新的请求重新执行fn
+	g.Forget("test")
+	v, err, shared := g.Do("test", func() (interface{}, error) {
+		return "fresh", nil
+	})
+	if v != "fresh" || err != nil || shared {
+		t.Errorf("got %v %v %v, want fresh", v, err, shared)
+	}
+
+	close(block)
+	if res := <-first; res.Val != "stuck" {
+		t.Errorf("got %v, want stuck", res.Val)
+	}
+}
+
+func TestDoPanic(t *testing.T) {
+	var g Group
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	var panics int32
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					if p, ok := r.(*PanicError); !ok || p.Value != "boom" {
+						t.Errorf("got panic %v, want *PanicError boom", r)
+					}
+					atomic.AddInt32(&panics, 1)
+				}
+			}()
+			g.Do("test", func() (interface{}, error) {
+				<-start
+				panic("boom")
+			})
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(start)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("waiters blocked after panic")
+	}
+	if got := atomic.LoadInt32(&panics); got != 5 {
+		t.Errorf("got %d panics, want 5", got)
+	}
+
+	// panic之后key可以继续使用
+	v, err, _ := g.Do("test", func() (interface{}, error) {
+		return "ok", nil
+	})
+	if v != "ok" || err != nil {
+		t.Errorf("got %v %v, want ok", v, err)
+	}
+}
+
+func TestDoChanPanic(t *testing.T) {
+	var g Group
+	res := <-g.DoChan("test", func() (interface{}, error) {
+		panic("boom")
+	})
+	if p, ok := res.Err.(*PanicError); !ok || p.Value != "boom" {
+		t.Errorf("got %v, want *PanicError boom", res.Err)
+	}
+}
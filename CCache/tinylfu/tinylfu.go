@@ -0,0 +1,96 @@
+/*
+Package tinylfu TinyLFU准入策略
+使用Count-Min Sketch近似统计key的访问频率，缓存已满时只有比淘汰对象更常被访问的新条目才会被写入，
+防止一次性访问的大量冷数据冲刷掉热点数据
+*/
+package tinylfu
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const depth = 4
+
+// TinyLFU 基于访问频率的准入策略
+type TinyLFU struct {
+	mu       sync.Mutex // guards
+	counters [depth][]uint8
+	mask     uint64
+	// 累计Record次数达到resetAt后所有计数减半，使频率反映近期的访问
+	additions int
+	resetAt   int
+}
+
+// New 创建TinyLFU，counters为预计缓存的条目数，会向上取整为2的幂
+func New(counters int) *TinyLFU {
+	width := 16
+	for width < counters {
+		width <<= 1
+	}
+	t := &TinyLFU{mask: uint64(width - 1), resetAt: width * 10}
+	for i := range t.counters {
+		t.counters[i] = make([]uint8, width)
+	}
+	return t
+}
+
+// Record 记录一次访问
+func (t *TinyLFU) Record(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h1, h2 := hash(key)
+	for i := 0; i < depth; i++ {
+		idx := (h1 + uint64(i)*h2) & t.mask
+		// 4位计数器，最大为15
+		if t.counters[i][idx] < 15 {
+			t.counters[i][idx]++
+		}
+	}
+	t.additions++
+	if t.additions >= t.resetAt {
+		t.reset()
+	}
+}
+
+// Estimate 返回key的近似访问频率
+func (t *TinyLFU) Estimate(key string) uint8 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.estimate(key)
+}
+
+// Admit 缓存已满时，只有key比将被淘汰的victim访问更频繁才写入
+func (t *TinyLFU) Admit(key, victim string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.estimate(key) > t.estimate(victim)
+}
+
+func (t *TinyLFU) estimate(key string) uint8 {
+	h1, h2 := hash(key)
+	min := uint8(15)
+	for i := 0; i < depth; i++ {
+		if c := t.counters[i][(h1+uint64(i)*h2)&t.mask]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+func (t *TinyLFU) reset() {
+	t.additions /= 2
+	for i := range t.counters {
+		for j := range t.counters[i] {
+			t.counters[i][j] >>= 1
+		}
+	}
+}
+
+// hash 使用双重哈希为每一行生成不同的下标
+func hash(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum, (sum >> 32) | 1
+}
@@ -0,0 +1,48 @@
+package tinylfu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimate(t *testing.T) {
+	lfu := New(100)
+	for i := 0; i < 5; i++ {
+		lfu.Record("hot")
+	}
+	lfu.Record("warm")
+
+	assert.Equal(t, uint8(5), lfu.Estimate("hot"))
+	assert.Equal(t, uint8(1), lfu.Estimate("warm"))
+	assert.Equal(t, uint8(0), lfu.Estimate("cold"))
+
+	// 计数器上限为15
+	for i := 0; i < 20; i++ {
+		lfu.Record("hot")
+	}
+	assert.Equal(t, uint8(15), lfu.Estimate("hot"))
+}
+
+func TestAdmit(t *testing.T) {
+	lfu := New(100)
+	lfu.Record("hot")
+	lfu.Record("hot")
+	lfu.Record("cold")
+
+	assert.True(t, lfu.Admit("hot", "cold"))
+	assert.False(t, lfu.Admit("cold", "hot"))
+	assert.False(t, lfu.Admit("cold", "cold"))
+}
+
+func TestReset(t *testing.T) {
+	lfu := New(16)
+	for i := 0; i < 8; i++ {
+		lfu.Record("hot")
+	}
+	// 累计访问次数达到阈值后计数减半
+	lfu.additions = lfu.resetAt - 1
+	lfu.Record("hot")
+	assert.Equal(t, uint8(4), lfu.Estimate("hot"))
+	assert.Equal(t, lfu.resetAt/2, lfu.additions)
+}
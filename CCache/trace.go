@@ -0,0 +1,77 @@
+/*
+跨节点的链路追踪上下文，采用W3C traceparent格式通过HTTP头传递
+*/
+package ccache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+const traceparentHeader = "traceparent"
+
+// TraceContext 链路上下文，TraceID为32位十六进制，SpanID为16位十六进制
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+}
+
+type traceKey struct{}
+
+// ContextWithTrace 将链路上下文写入ctx
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceKey{}, tc)
+}
+
+// TraceFromContext 从ctx中读取链路上下文
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey{}).(TraceContext)
+	return tc, ok
+}
+
+// ensureTrace ctx中没有链路上下文时开启新的链路
+func ensureTrace(ctx context.Context) context.Context {
+	if _, ok := TraceFromContext(ctx); ok {
+		return ctx
+	}
+	return ContextWithTrace(ctx, TraceContext{
+		TraceID: fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64()),
+		SpanID:  newSpanID(),
+	})
+}
+
+// child 同一链路下的新span，用于跨节点调用
+func (tc TraceContext) child() TraceContext {
+	return TraceContext{TraceID: tc.TraceID, SpanID: newSpanID()}
+}
+
+func (tc TraceContext) traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-01"
+}
+
+// parseTraceparent 解析形如 00-<trace-id>-<span-id>-<flags> 的请求头
+func parseTraceparent(h string) (TraceContext, bool) {
+	parts := strings.Split(h, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return TraceContext{}, false
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) {
+		return TraceContext{}, false
+	}
+	return TraceContext{TraceID: parts[1], SpanID: parts[2]}, true
+}
+
+func newSpanID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package ccache
+
+import (
+	"bytes"
+	"ccache/ccachepb"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestByteViewReadViews(t *testing.T) {
+	v := ByteView{b: []byte("hello")}
+	var buf bytes.Buffer
+	n, err := v.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, "hello", buf.String())
+
+	r := v.Reader()
+	r.Seek(1, io.SeekStart)
+	rest, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "ello", string(rest))
+}
+
+func TestWriteValueMatchesProto(t *testing.T) {
+	loadedAt := time.Unix(0, 1650000000123456789)
+	for _, v := range []ByteView{
+		{},
+		{b: []byte("v")},
+		{b: benchValue, version: 42},
+		{b: []byte("v"), version: 1, loadedAt: loadedAt},
+		{version: 7, loadedAt: loadedAt},
+	} {
+		var buf bytes.Buffer
+		assert.Nil(t, writeValue(&buf, v))
+
+		res := &ccachepb.Response{Value: v.b, Version: v.version}
+		if !v.loadedAt.IsZero() {
+			res.LoadedAt = v.loadedAt.UnixNano()
+		}
+		want, err := proto.Marshal(res)
+		assert.Nil(t, err)
+		assert.Equal(t, string(want), buf.String())
+		assert.Equal(t, len(want), responseSize(v))
+	}
+}
+
+func TestProtoGetter(t *testing.T) {
+	group := NewGroup("proto", 2<<10, ProtoGetterFunc(func(key string) (proto.Message, error) {
+		return &ccachepb.Request{Group: "proto", Key: key}, nil
+	}))
+
+	m := &ccachepb.Request{}
+	assert.Nil(t, group.GetProto(context.Background(), "k", m))
+	assert.Equal(t, "k", m.GetKey())
+	assert.Equal(t, "proto", m.GetGroup())
+
+	// 同时可以作为普通Getter使用
+	b, err := ProtoGetterFunc(func(key string) (proto.Message, error) {
+		return &ccachepb.Request{Key: key}, nil
+	}).Get("k")
+	assert.Nil(t, err)
+	assert.Nil(t, proto.Unmarshal(b, m))
+	assert.Equal(t, "k", m.GetKey())
+}
+
+var benchValue = bytes.Repeat([]byte("x"), 64<<10)
+
+func newBenchRegistry(b *testing.B) (*Registry, *Group) {
+	registry := NewRegistry()
+	group, err := registry.NewGroup("bench", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		return benchValue, nil
+	}), GroupOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err = group.Get("key"); err != nil {
+		b.Fatal(err)
+	}
+	return registry, group
+}
+
+// discardWriter 丢弃响应内容，避免ResponseRecorder的缓冲影响分配统计
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// handlerTransport 不经过网络，直接由handler处理请求
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func BenchmarkServeGet(b *testing.B) {
+	registry, _ := newBenchRegistry(b)
+	pool := NewHTTPPoolWithOpts("bench", HTTPPoolOptions{Registry: registry})
+	req := httptest.NewRequest(http.MethodGet, defaultBasePath+"bench/key", nil)
+	w := &discardWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchValue)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkPeerGet(b *testing.B) {
+	registry, _ := newBenchRegistry(b)
+	pool := NewHTTPPoolWithOpts("bench", HTTPPoolOptions{Registry: registry})
+	getter := &httpGetter{baseURL: "http://bench" + defaultBasePath, client: &http.Client{Transport: handlerTransport{pool}}}
+	req := &ccachepb.Request{Group: "bench", Key: "key"}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchValue)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := getter.Get(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkByteView(b *testing.B) {
+	v := ByteView{b: benchValue}
+	b.Run("ByteSlice", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(v.Len()))
+		for i := 0; i < b.N; i++ {
+			ioutil.Discard.Write(v.ByteSlice())
+		}
+	})
+	b.Run("WriteTo", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(v.Len()))
+		for i := 0; i < b.N; i++ {
+			v.WriteTo(ioutil.Discard)
+		}
+	})
+}
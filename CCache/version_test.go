@@ -0,0 +1,92 @@
+package ccache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareAndSetLocally(t *testing.T) {
+	group := NewGroup("cas-local", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("0"), nil
+	}))
+
+	// key不在缓存中时期望版本为0
+	v1, err := group.CompareAndSet("counter", 0, []byte("1"))
+	assert.Nil(t, err)
+	_, err = group.CompareAndSet("counter", 0, []byte("1"))
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	value, err := group.Get("counter")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", value.String())
+	assert.Equal(t, v1, value.Version())
+
+	v2, err := group.CompareAndSet("counter", v1, []byte("2"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, v1, v2)
+	_, err = group.CompareAndSet("counter", v1, []byte("3"))
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	// 从数据源加载的值同样带有版本
+	loaded, err := group.Get("other")
+	assert.Nil(t, err)
+	assert.NotZero(t, loaded.Version())
+	assert.Equal(t, fmt.Sprintf(`"%x"`, loaded.Version()), loaded.ETag())
+}
+
+func TestCompareAndSetRemote(t *testing.T) {
+	var transferred int32
+	client := NewGroup("cas", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		t.Fatal("client should fetch from peer")
+		return nil, nil
+	}))
+	registry := NewRegistry()
+	server, err := registry.NewGroup("cas", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("origin"), nil
+	}), GroupOptions{})
+	assert.Nil(t, err)
+	pool := NewHTTPPoolWithOpts("server", HTTPPoolOptions{Registry: registry})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		pool.ServeHTTP(rec, r)
+		if rec.Code == http.StatusOK && r.Method == http.MethodGet {
+			atomic.AddInt32(&transferred, 1)
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer srv.Close()
+	client.RegisterPeers(fixedPicker{peer: &httpGetter{baseURL: srv.URL + defaultBasePath, client: http.DefaultClient}})
+
+	value, err := client.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "origin", value.String())
+	owned, _ := server.mainCache.get("key")
+	assert.Equal(t, owned.Version(), value.Version())
+
+	// 版本未变化时只返回304，不再传输value
+	value, err = client.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "origin", value.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&transferred))
+
+	// CompareAndSet被转发到所属节点
+	version, err := client.CompareAndSet("key", value.Version(), []byte("updated"))
+	assert.Nil(t, err)
+	_, err = client.CompareAndSet("key", value.Version(), []byte("again"))
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	value, err = client.Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "updated", value.String())
+	assert.Equal(t, version, value.Version())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&transferred))
+}
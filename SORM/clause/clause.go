@@ -15,8 +15,25 @@ const (
 	UPDATE
 	DELETE
 	COUNT
+	RETURNING
+	JOIN
+	GROUPBY
+	HAVING
+	OFFSET
+	ONCONFLICT
+	DEFAULTVALUES
 )
 
+// OnConflict 插入的记录与已有记录冲突（主键或唯一索引重复）时的处理方式
+type OnConflict struct {
+	// Columns 冲突的列，为空时使用主键，mysql按所有唯一索引判断冲突，忽略该项
+	Columns []string
+	// DoUpdate 冲突时更新为插入值的列，为空且DoNothing为false时更新除Columns外插入的所有列
+	DoUpdate []string
+	// DoNothing 冲突时保留已有记录
+	DoNothing bool
+}
+
 type Clause struct {
 	sql     map[Type]string
 	sqlVars map[Type][]interface{}
@@ -33,6 +50,12 @@ func (c *Clause) Set(typ Type, values ...interface{}) {
 	c.sqlVars[typ] = vars
 }
 
+// Has 是否已设置typ对应的子句
+func (c *Clause) Has(typ Type) bool {
+	_, ok := c.sql[typ]
+	return ok
+}
+
 // Build 根据传入顺序的Type构造出完整SQL子句
 func (c *Clause) Build(typs ...Type) (string, []interface{}) {
 	var sqls []string
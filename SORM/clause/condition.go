@@ -0,0 +1,203 @@
+// 可组合的查询条件，生成带占位符的SQL
+package clause
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Expr 查询条件
+// clause.And(clause.Eq("Name", "Tom"), clause.Or(clause.Gt("Age", 18), clause.IsNull("Age")))
+type Expr interface {
+	Build() (string, []interface{})
+}
+
+type expr struct {
+	sql  string
+	vars []interface{}
+	// 单个谓词，组合时不需要加括号
+	simple bool
+	// 谓词左侧的列名，sql为列名之后的部分
+	column string
+	// 组合条件的各部分，QuoteColumns时逐个转换
+	op    string
+	items []Expr
+}
+
+func (e expr) Build() (string, []interface{}) {
+	return e.column + e.sql, e.vars
+}
+
+func predicate(column, sql string, vars ...interface{}) Expr {
+	return expr{column: column, sql: sql, vars: vars, simple: true}
+}
+
+// QuoteColumns 使用quote转换条件构造函数中的列名，原始条件与自定义的Expr保持不变
+// session在Where中调用，将字段名转换为加引号的列名
+func QuoteColumns(e Expr, quote func(string) string) Expr {
+	p, ok := e.(expr)
+	if !ok {
+		return e
+	}
+	switch {
+	case p.column != "":
+		p.column = quote(p.column)
+	case p.op == "NOT":
+		return Not(QuoteColumns(p.items[0], quote))
+	case p.op != "":
+		items := make([]Expr, len(p.items))
+		for i, item := range p.items {
+			items[i] = QuoteColumns(item, quote)
+		}
+		return compound(p.op, items)
+	}
+	return p
+}
+
+// Raw 原始条件，如 Raw("Age > ? AND Age < ?", 18, 30)
+func Raw(sql string, vars ...interface{}) Expr {
+	return expr{sql: sql, vars: vars}
+}
+
+// Eq column = value，value为nil时为 IS NULL
+func Eq(column string, value interface{}) Expr {
+	if value == nil {
+		return IsNull(column)
+	}
+	return predicate(column, " = ?", value)
+}
+
+// Neq column <> value，value为nil时为 IS NOT NULL
+func Neq(column string, value interface{}) Expr {
+	if value == nil {
+		return IsNotNull(column)
+	}
+	return predicate(column, " <> ?", value)
+}
+
+// Gt column > value
+func Gt(column string, value interface{}) Expr {
+	return predicate(column, " > ?", value)
+}
+
+// Gte column >= value
+func Gte(column string, value interface{}) Expr {
+	return predicate(column, " >= ?", value)
+}
+
+// Lt column < value
+func Lt(column string, value interface{}) Expr {
+	return predicate(column, " < ?", value)
+}
+
+// Lte column <= value
+func Lte(column string, value interface{}) Expr {
+	return predicate(column, " <= ?", value)
+}
+
+// In column IN (values...)，只传入一个切片时展开切片，values为空时条件恒为假
+func In(column string, values ...interface{}) Expr {
+	values = flatten(values)
+	if len(values) == 0 {
+		return predicate("", "1 = 0")
+	}
+	return predicate(column, " IN ("+genBindVars(len(values))+")", values...)
+}
+
+// Between column BETWEEN low AND high
+func Between(column string, low, high interface{}) Expr {
+	return predicate(column, " BETWEEN ? AND ?", low, high)
+}
+
+// Like column LIKE pattern
+func Like(column string, pattern string) Expr {
+	return predicate(column, " LIKE ?", pattern)
+}
+
+// IsNull column IS NULL
+func IsNull(column string) Expr {
+	return predicate(column, " IS NULL")
+}
+
+// IsNotNull column IS NOT NULL
+func IsNotNull(column string) Expr {
+	return predicate(column, " IS NOT NULL")
+}
+
+// And 以AND连接条件，忽略nil，没有条件时返回nil
+func And(exprs ...Expr) Expr {
+	return compound("AND", exprs)
+}
+
+// Or 以OR连接条件，忽略nil，没有条件时返回nil
+func Or(exprs ...Expr) Expr {
+	return compound("OR", exprs)
+}
+
+// Not NOT (e)
+func Not(e Expr) Expr {
+	sql, vars := e.Build()
+	return expr{sql: "NOT (" + sql + ")", vars: vars, op: "NOT", items: []Expr{e}}
+}
+
+// Map 以AND连接每个列的Eq条件，按列名排序
+func Map(m map[string]interface{}) Expr {
+	columns := make([]string, 0, len(m))
+	for column := range m {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	exprs := make([]Expr, 0, len(columns))
+	for _, column := range columns {
+		exprs = append(exprs, Eq(column, m[column]))
+	}
+	return And(exprs...)
+}
+
+func compound(op string, exprs []Expr) Expr {
+	var items []Expr
+	for _, e := range exprs {
+		if e != nil {
+			items = append(items, e)
+		}
+	}
+	switch len(items) {
+	case 0:
+		return nil
+	case 1:
+		return items[0]
+	}
+
+	parts := make([]string, 0, len(items))
+	var vars []interface{}
+	for _, e := range items {
+		sql, v := e.Build()
+		// 组合条件与原始条件可能包含优先级更低的运算符，需加括号
+		if p, ok := e.(expr); !ok || !p.simple {
+			sql = "(" + sql + ")"
+		}
+		parts = append(parts, sql)
+		vars = append(vars, v...)
+	}
+	return expr{sql: strings.Join(parts, " "+op+" "), vars: vars, op: op, items: items}
+}
+
+// flatten 只有一个切片参数时展开为多个参数，[]byte视为单个值
+func flatten(values []interface{}) []interface{} {
+	if len(values) != 1 {
+		return values
+	}
+	v := reflect.ValueOf(values[0])
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return values
+	}
+	if _, ok := values[0].([]byte); ok {
+		return values
+	}
+	flat := make([]interface{}, v.Len())
+	for i := range flat {
+		flat[i] = v.Index(i).Interface()
+	}
+	return flat
+}
@@ -0,0 +1,79 @@
+package clause
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert"
+)
+
+func TestConditionPredicates(t *testing.T) {
+	sql, vars := Eq("Name", "Tom").Build()
+	assert.Equal(t, "Name = ?", sql)
+	assert.Equal(t, []interface{}{"Tom"}, vars)
+
+	sql, _ = Eq("Name", nil).Build()
+	assert.Equal(t, "Name IS NULL", sql)
+
+	sql, _ = Neq("Name", nil).Build()
+	assert.Equal(t, "Name IS NOT NULL", sql)
+
+	sql, vars = Between("Age", 10, 20).Build()
+	assert.Equal(t, "Age BETWEEN ? AND ?", sql)
+	assert.Equal(t, []interface{}{10, 20}, vars)
+}
+
+func TestConditionIn(t *testing.T) {
+	sql, vars := In("Name", "Tom", "Sam").Build()
+	assert.Equal(t, "Name IN (?, ?)", sql)
+	assert.Equal(t, []interface{}{"Tom", "Sam"}, vars)
+
+	// 单个切片参数被展开
+	sql, vars = In("Age", []int{1, 2, 3}).Build()
+	assert.Equal(t, "Age IN (?, ?, ?)", sql)
+	assert.Equal(t, []interface{}{1, 2, 3}, vars)
+
+	sql, vars = In("Age", []int{}).Build()
+	assert.Equal(t, "1 = 0", sql)
+	assert.Equal(t, 0, len(vars))
+}
+
+func TestConditionCompound(t *testing.T) {
+	e := And(
+		Eq("Name", "Tom"),
+		Or(Gt("Age", 18), IsNull("Age")),
+		Raw("Age < ? OR Age > ?", 30, 40),
+		nil,
+	)
+	sql, vars := e.Build()
+	assert.Equal(t, "Name = ? AND (Age > ? OR Age IS NULL) AND (Age < ? OR Age > ?)", sql)
+	assert.Equal(t, []interface{}{"Tom", 18, 30, 40}, vars)
+
+	sql, vars = Not(Like("Name", "T%")).Build()
+	assert.Equal(t, "NOT (Name LIKE ?)", sql)
+	assert.Equal(t, []interface{}{"T%"}, vars)
+
+	assert.Equal(t, nil, And())
+	assert.Equal(t, nil, Or(nil, nil))
+}
+
+func TestConditionMap(t *testing.T) {
+	sql, vars := Map(map[string]interface{}{"Name": "Tom", "Age": 18}).Build()
+	assert.Equal(t, "Age = ? AND Name = ?", sql)
+	assert.Equal(t, []interface{}{18, "Tom"}, vars)
+}
+
+func TestQuoteColumns(t *testing.T) {
+	quote := func(column string) string { return `"` + column + `"` }
+	e := And(
+		Eq("Name", "Tom"),
+		Not(Or(In("Age", 1, 2), IsNull("Age"))),
+		Raw("Age < ?", 30),
+	)
+	sql, vars := QuoteColumns(e, quote).Build()
+	assert.Equal(t, `"Name" = ? AND (NOT ("Age" IN (?, ?) OR "Age" IS NULL)) AND (Age < ?)`, sql)
+	assert.Equal(t, []interface{}{"Tom", 1, 2, 30}, vars)
+
+	// 原条件不变
+	sql, _ = e.Build()
+	assert.Equal(t, "Name = ? AND (NOT (Age IN (?, ?) OR Age IS NULL)) AND (Age < ?)", sql)
+}
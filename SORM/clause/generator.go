@@ -4,6 +4,7 @@ package clause
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -22,6 +23,13 @@ func init() {
 	generators[UPDATE] = _update
 	generators[DELETE] = _delete
 	generators[COUNT] = _count
+	generators[RETURNING] = _returning
+	generators[JOIN] = _join
+	generators[GROUPBY] = _groupBy
+	generators[HAVING] = _having
+	generators[OFFSET] = _offset
+	generators[ONCONFLICT] = _onConflict
+	generators[DEFAULTVALUES] = _defaultValues
 }
 
 func genBindVars(num int) string {
@@ -32,19 +40,30 @@ func genBindVars(num int) string {
 	return strings.Join(vars, ", ")
 }
 func _select(values ...interface{}) (string, []interface{}) {
-	// SELECT $fields FROM $tableName
+	// SELECT [DISTINCT] $fields FROM $tableName
 	tableName := values[0]
 	fields := strings.Join(values[1].([]string), ",")
+	if len(values) > 2 && values[2].(bool) {
+		fields = "DISTINCT " + fields
+	}
 	return fmt.Sprintf("SELECT %v FROM %s", fields, tableName), []interface{}{}
 }
 
 func _insert(values ...interface{}) (string, []interface{}) {
-	// INSERT INTO $tableName $fields
+	// INSERT INTO $tableName $fields，没有列时由DEFAULTVALUES补全
 	tableName := values[0]
+	if len(values[1].([]string)) == 0 {
+		return fmt.Sprintf("INSERT INTO %s", tableName), []interface{}{}
+	}
 	fields := strings.Join(values[1].([]string), ",")
 	return fmt.Sprintf("INSERT INTO %s (%v)", tableName, fields), []interface{}{}
 }
 
+func _defaultValues(values ...interface{}) (string, []interface{}) {
+	// 所有列使用默认值，语法由dialect生成，如 DEFAULT VALUES
+	return values[0].(string), []interface{}{}
+}
+
 func _values(values ...interface{}) (string, []interface{}) {
 	// 拼接SQL语句VALUES
 	// VALUES ($v1,$v2...), ($v3,$v4...), ...
@@ -92,13 +111,19 @@ func _update(values ...interface{}) (string, []interface{}) {
 	sql.WriteString(fmt.Sprintf("UPDATE %s ", values[0]))
 	sql.WriteString("SET ")
 	items := values[1].(map[string]interface{})
+	// 按列名排序，保证生成的SQL稳定
 	var keys []string
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sets []string
 	var vars []interface{}
-	for k, v := range items {
-		keys = append(keys, k+" = ?")
-		vars = append(vars, v)
+	for _, k := range keys {
+		sets = append(sets, k+" = ?")
+		vars = append(vars, items[k])
 	}
-	sql.WriteString(strings.Join(keys, ", "))
+	sql.WriteString(strings.Join(sets, ", "))
 
 	return sql.String(), vars
 
@@ -114,3 +139,42 @@ func _count(values ...interface{}) (string, []interface{}) {
 	// 复用_select
 	return _select(values[0], []string{"COUNT(*)"})
 }
+
+func _returning(values ...interface{}) (string, []interface{}) {
+	// RETURNING $fields
+	return fmt.Sprintf("RETURNING %s", strings.Join(values[0].([]string), ", ")), []interface{}{}
+}
+
+func _join(values ...interface{}) (string, []interface{}) {
+	// INNER JOIN $table ON $cond LEFT JOIN ...
+	// 每个value是一个Expr，按添加顺序拼接
+	var sqls []string
+	var vars []interface{}
+	for _, value := range values {
+		sql, v := value.(Expr).Build()
+		sqls = append(sqls, sql)
+		vars = append(vars, v...)
+	}
+	return strings.Join(sqls, " "), vars
+}
+
+func _groupBy(values ...interface{}) (string, []interface{}) {
+	// GROUP BY $columns
+	return fmt.Sprintf("GROUP BY %s", strings.Join(values[0].([]string), ", ")), []interface{}{}
+}
+
+func _having(values ...interface{}) (string, []interface{}) {
+	// HAVING desc
+	desc, vars := values[0], values[1:]
+	return fmt.Sprintf("HAVING %s", desc), vars
+}
+
+func _offset(values ...interface{}) (string, []interface{}) {
+	// OFFSET ?
+	return fmt.Sprintf("OFFSET %d", values[0]), []interface{}{}
+}
+
+func _onConflict(values ...interface{}) (string, []interface{}) {
+	// ON CONFLICT ... / ON DUPLICATE KEY UPDATE ...，由dialect生成
+	return values[0].(string), []interface{}{}
+}
@@ -11,3 +11,35 @@ func TestSelect(t *testing.T) {
 	assert.Equal(t, "SELECT age,name FROM User", sql)
 	assert.Equal(t, 0, len(vars))
 }
+
+func TestUpdate(t *testing.T) {
+	sql, vars := _update("User", map[string]interface{}{"name": "Tom", "age": 18})
+	assert.Equal(t, "UPDATE User SET age = ?, name = ?", sql)
+	assert.Equal(t, []interface{}{18, "Tom"}, vars)
+}
+
+func TestReturning(t *testing.T) {
+	sql, vars := _returning([]string{"id", "name"})
+	assert.Equal(t, "RETURNING id, name", sql)
+	assert.Equal(t, 0, len(vars))
+}
+
+func TestSelectDistinct(t *testing.T) {
+	sql, _ := _select("User", []string{"Name"}, true)
+	assert.Equal(t, "SELECT DISTINCT Name FROM User", sql)
+}
+
+func TestGroupByHaving(t *testing.T) {
+	var c Clause
+	c.Set(SELECT, "User", []string{"Age", "COUNT(*)"})
+	c.Set(JOIN, Raw("INNER JOIN Account ON Account.UserName = User.Name AND Account.Balance > ?", 10))
+	c.Set(GROUPBY, []string{"Age"})
+	c.Set(HAVING, "COUNT(*) > ?", 1)
+	c.Set(LIMIT, 5)
+	c.Set(OFFSET, 10)
+	assert.Equal(t, true, c.Has(OFFSET))
+	sql, vars := c.Build(SELECT, JOIN, WHERE, GROUPBY, HAVING, LIMIT, OFFSET)
+	assert.Equal(t, "SELECT Age,COUNT(*) FROM User INNER JOIN Account ON Account.UserName = User.Name AND Account.Balance > ? "+
+		"GROUP BY Age HAVING COUNT(*) > ? LIMIT 5 OFFSET 10", sql)
+	assert.Equal(t, []interface{}{10, 1}, vars)
+}
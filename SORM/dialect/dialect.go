@@ -1,13 +1,41 @@
 package dialect
 
-import "reflect"
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+)
 
 // Dialector 支持不同数据库的差异
 type Dialector interface {
-	//ConvertTypeTo 用于将Go的类型转换为对应数据库的数据类型
+	//ConvertTypeTo 用于将Go的类型转换为对应数据库的数据类型，指针、sql.Null*与driver.Valuer按其值的类型转换
 	ConvertTypeTo(typ reflect.Value) string
 	// TableExistsSQL 判断表是否存在的SQL语句
 	TableExistsSQL(tableName string) (string, []interface{})
+	// Quote 为表名、列名等标识符加上引号
+	Quote(name string) string
+	// BindVar 第n个（从1开始）参数的占位符
+	BindVar(n int) string
+	// SupportsReturning 是否支持 INSERT ... RETURNING
+	SupportsReturning() bool
+	// ColumnsSQL 查询表中各列的SQL语句，结果为 (name, type)，按列的顺序排列
+	ColumnsSQL(tableName string) (string, []interface{})
+	// IndexesSQL 查询表中索引的SQL语句，结果为 (name, unique, column)，按索引名与索引内列的顺序排列，不包含主键
+	IndexesSQL(tableName string) (string, []interface{})
+	// ModifyColumnSQL 修改列类型的SQL语句，不支持时返回空字符串，由调用方重建表
+	ModifyColumnSQL(tableName, column, typ string) string
+	// DropIndexSQL 删除索引的SQL语句
+	DropIndexSQL(tableName, index string) string
+	// AutoIncrementSQL 类型为typ的自增主键在列定义中的类型部分
+	AutoIncrementSQL(typ string) string
+	// MaxBindVars 一条语句中参数个数的上限
+	MaxBindVars() int
+	// OnConflictSQL INSERT语句中冲突时的处理子句，columns为冲突的列，updates为空时保留已有记录，列名均已加引号
+	// 无法表示时返回空字符串
+	OnConflictSQL(columns, updates []string) string
+	// DefaultValuesSQL 不指定任何列插入一条记录时，INSERT INTO table之后的部分
+	DefaultValuesSQL() string
 }
 
 // key 是数据库类型
@@ -15,6 +43,8 @@ var dialectsMap = map[string]Dialector{}
 
 func init() {
 	RegisterDialect("sqlite3", &sqlite3{})
+	RegisterDialect("mysql", &mysql{})
+	RegisterDialect("postgres", &postgres{})
 }
 
 // RegisterDialect 注册dialect
@@ -29,3 +59,88 @@ func GetDialect(name string) (Dialector, bool) {
 	dialect, ok := dialectsMap[name]
 	return dialect, ok
 }
+
+// Rebind 将SQL中的 ? 占位符替换为dialect的占位符，引号内的 ? 保持不变
+// session生成的SQL与用户传入的条件统一使用 ? ，执行前再转换
+func Rebind(d Dialector, query string) string {
+	if d.BindVar(1) == "?" || !strings.Contains(query, "?") {
+		return query
+	}
+	var (
+		b     strings.Builder
+		n     int
+		quote rune
+	)
+	b.Grow(len(query) + 8)
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			// 引号内的内容原样输出
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString(d.BindVar(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// columnValue 返回决定列类型的值，各dialect的ConvertTypeTo先调用它
+// 指针取指向的类型；sql.NullString等只有值字段与Valid字段的结构体取值字段；
+// 其他driver.Valuer取零值Value()的结果，结果为nil时按string处理
+func columnValue(typ reflect.Value) reflect.Value {
+	t := typ.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{}) && t.NumField() == 2 {
+		for i := 0; i < 2; i++ {
+			if valid := t.Field(1 - i); valid.Name == "Valid" && valid.Type.Kind() == reflect.Bool {
+				return columnValue(reflect.New(t.Field(i).Type).Elem())
+			}
+		}
+	}
+	if reflect.PtrTo(t).Implements(valuerType) {
+		if v, err := reflect.New(t).Interface().(driver.Valuer).Value(); err == nil && v != nil {
+			return reflect.ValueOf(v)
+		}
+		return reflect.ValueOf("")
+	}
+	return reflect.New(t).Elem()
+}
+
+// onConflict sqlite与postgres的 ON CONFLICT 子句，excluded为冲突时待插入的记录
+func onConflict(columns, updates []string) string {
+	target := ""
+	if len(columns) > 0 {
+		target = " (" + strings.Join(columns, ", ") + ")"
+	}
+	if len(updates) == 0 {
+		return "ON CONFLICT" + target + " DO NOTHING"
+	}
+	sets := make([]string, len(updates))
+	for i, column := range updates {
+		sets[i] = column + " = excluded." + column
+	}
+	return "ON CONFLICT" + target + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// quoteWith 使用q包裹标识符，标识符中的q转义为两个q，a.b形式的标识符分别加引号
+func quoteWith(name string, q string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part == "*" {
+			continue
+		}
+		parts[i] = q + strings.ReplaceAll(part, q, q+q) + q
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,93 @@
+package dialect
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	pg, _ := GetDialect("postgres")
+	cases := map[string]string{
+		"SELECT * FROM t WHERE a = ? AND b = ?":    "SELECT * FROM t WHERE a = $1 AND b = $2",
+		"SELECT * FROM t WHERE a = '?' AND b = ?":  "SELECT * FROM t WHERE a = '?' AND b = $1",
+		`SELECT "?" FROM t WHERE a = 'it''s' OR ?`: `SELECT "?" FROM t WHERE a = 'it''s' OR $1`,
+		"INSERT INTO t VALUES(?, ?), (?, ?)":       "INSERT INTO t VALUES($1, $2), ($3, $4)",
+		"SELECT 1":                                 "SELECT 1",
+	}
+	for query, want := range cases {
+		assert.Equal(t, want, Rebind(pg, query))
+	}
+
+	// 使用 ? 占位符的dialect不做转换
+	my, _ := GetDialect("mysql")
+	assert.Equal(t, "SELECT ? FROM t", Rebind(my, "SELECT ? FROM t"))
+}
+
+func TestQuote(t *testing.T) {
+	sqlite, _ := GetDialect("sqlite3")
+	my, _ := GetDialect("mysql")
+	pg, _ := GetDialect("postgres")
+
+	assert.Equal(t, `"User"`, sqlite.Quote("User"))
+	assert.Equal(t, "`User`.`Name`", my.Quote("User.Name"))
+	assert.Equal(t, "`a``b`", my.Quote("a`b"))
+	// postgres中加引号的标识符统一为小写
+	assert.Equal(t, `"user".*`, pg.Quote("User.*"))
+	assert.Equal(t, `"a""b"`, pg.Quote(`a"b`))
+}
+
+// celsius 底层类型为string，Value()返回float64
+type celsius string
+
+func (c celsius) Value() (driver.Value, error) {
+	return 36.5, nil
+}
+
+func TestConvertTypeTo(t *testing.T) {
+	my, _ := GetDialect("mysql")
+	pg, _ := GetDialect("postgres")
+	cases := []struct {
+		value    interface{}
+		mysql    string
+		postgres string
+	}{
+		{true, "boolean", "boolean"},
+		{int8(0), "tinyint", "smallint"},
+		{0, "int", "integer"},
+		{uint32(0), "int unsigned", "bigint"},
+		{int64(0), "bigint", "bigint"},
+		{float64(0), "double", "double precision"},
+		{"", "varchar(255)", "text"},
+		{[]byte{}, "longblob", "bytea"},
+		{time.Time{}, "datetime(3)", "timestamptz"},
+		// 可为NULL的类型按其值的类型转换
+		{new(int64), "bigint", "bigint"},
+		{sql.NullString{}, "varchar(255)", "text"},
+		{sql.NullTime{}, "datetime(3)", "timestamptz"},
+		{&sql.NullFloat64{}, "double", "double precision"},
+		{celsius(""), "double", "double precision"},
+	}
+	for _, c := range cases {
+		v := reflect.ValueOf(c.value)
+		assert.Equal(t, c.mysql, my.ConvertTypeTo(v))
+		assert.Equal(t, c.postgres, pg.ConvertTypeTo(v))
+	}
+	assert.Panics(t, func() { pg.ConvertTypeTo(reflect.ValueOf(struct{}{})) })
+}
+
+func TestOnConflictSQL(t *testing.T) {
+	sqlite, _ := GetDialect("sqlite3")
+	my, _ := GetDialect("mysql")
+	pg, _ := GetDialect("postgres")
+
+	assert.Equal(t, `ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name"`, pg.OnConflictSQL([]string{`"id"`}, []string{`"name"`}))
+	assert.Equal(t, `ON CONFLICT DO NOTHING`, sqlite.OnConflictSQL(nil, nil))
+	assert.Equal(t, "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `age` = VALUES(`age`)",
+		my.OnConflictSQL([]string{"`id`"}, []string{"`name`", "`age`"}))
+	assert.Equal(t, "ON DUPLICATE KEY UPDATE `id` = `id`", my.OnConflictSQL([]string{"`id`"}, nil))
+}
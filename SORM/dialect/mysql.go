@@ -0,0 +1,128 @@
+package dialect
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type mysql struct {
+}
+
+// ConvertTypeTo convert type to type of database
+func (m *mysql) ConvertTypeTo(typ reflect.Value) string {
+	typ = columnValue(typ)
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8:
+		return "tinyint"
+	case reflect.Int16:
+		return "smallint"
+	case reflect.Int, reflect.Int32:
+		return "int"
+	case reflect.Uint8:
+		return "tinyint unsigned"
+	case reflect.Uint16:
+		return "smallint unsigned"
+	case reflect.Uint, reflect.Uint32:
+		return "int unsigned"
+	case reflect.Int64:
+		return "bigint"
+	case reflect.Uint64, reflect.Uintptr:
+		return "bigint unsigned"
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "varchar(255)"
+	case reflect.Array, reflect.Slice:
+		return "longblob"
+	case reflect.Struct:
+		if _, ok := typ.Interface().(time.Time); ok {
+			return "datetime(3)"
+		}
+	}
+	panic(fmt.Sprintf("invalid sql type %s (%s)", typ.Type().Name(), typ.Kind()))
+}
+
+// TableExistsSQL 在当前数据库中查找table
+func (m *mysql) TableExistsSQL(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	sql := "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?;"
+
+	return sql, args
+}
+
+// Quote 标识符使用反引号
+func (m *mysql) Quote(name string) string {
+	return quoteWith(name, "`")
+}
+
+// BindVar mysql使用 ? 占位符
+func (m *mysql) BindVar(n int) string {
+	return "?"
+}
+
+// SupportsReturning mysql不支持 RETURNING，自增id通过LastInsertId获取
+func (m *mysql) SupportsReturning() bool {
+	return false
+}
+
+// ColumnsSQL COLUMN_TYPE包含长度，如 varchar(255)
+func (m *mysql) ColumnsSQL(tableName string) (string, []interface{}) {
+	sql := "SELECT column_name, column_type FROM information_schema.columns " +
+		"WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position;"
+	return sql, []interface{}{tableName}
+}
+
+// IndexesSQL 查询information_schema.statistics
+func (m *mysql) IndexesSQL(tableName string) (string, []interface{}) {
+	sql := "SELECT index_name, non_unique = 0, column_name FROM information_schema.statistics " +
+		"WHERE table_schema = DATABASE() AND table_name = ? AND index_name <> 'PRIMARY' ORDER BY index_name, seq_in_index;"
+	return sql, []interface{}{tableName}
+}
+
+// ModifyColumnSQL ALTER TABLE ... MODIFY COLUMN
+func (m *mysql) ModifyColumnSQL(tableName, column, typ string) string {
+	return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s;", m.Quote(tableName), m.Quote(column), typ)
+}
+
+// DropIndexSQL mysql的索引属于表
+func (m *mysql) DropIndexSQL(tableName, index string) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s;", m.Quote(index), m.Quote(tableName))
+}
+
+// AutoIncrementSQL AUTO_INCREMENT
+func (m *mysql) AutoIncrementSQL(typ string) string {
+	return typ + " AUTO_INCREMENT"
+}
+
+// OnConflictSQL mysql按所有唯一索引判断冲突，不能指定冲突的列
+// 保留已有记录时将第一列更新为自身，避免INSERT IGNORE忽略其他错误
+// 没有冲突的列时无法表示保留已有记录，返回空字符串
+func (m *mysql) OnConflictSQL(columns, updates []string) string {
+	var sets []string
+	for _, column := range updates {
+		sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", column, column))
+	}
+	if len(sets) == 0 && len(columns) > 0 {
+		sets = append(sets, fmt.Sprintf("%s = %s", columns[0], columns[0]))
+	}
+	if len(sets) == 0 {
+		return ""
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// MaxBindVars 预处理语句的参数个数以16位整数表示
+func (m *mysql) MaxBindVars() int {
+	return 65535
+}
+
+// DefaultValuesSQL mysql不支持DEFAULT VALUES，使用空的列与值列表
+func (m *mysql) DefaultValuesSQL() string {
+	return "() VALUES ()"
+}
@@ -0,0 +1,120 @@
+package dialect
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type postgres struct {
+}
+
+// ConvertTypeTo convert type to type of database
+func (p *postgres) ConvertTypeTo(typ reflect.Value) string {
+	typ = columnValue(typ)
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "smallint"
+	case reflect.Int, reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double precision"
+	case reflect.String:
+		return "text"
+	case reflect.Array, reflect.Slice:
+		return "bytea"
+	case reflect.Struct:
+		if _, ok := typ.Interface().(time.Time); ok {
+			return "timestamptz"
+		}
+	}
+	panic(fmt.Sprintf("invalid sql type %s (%s)", typ.Type().Name(), typ.Kind()))
+}
+
+// TableExistsSQL 在当前schema中查找table
+func (p *postgres) TableExistsSQL(tableName string) (string, []interface{}) {
+	args := []interface{}{strings.ToLower(tableName)}
+	sql := "SELECT tablename FROM pg_tables WHERE schemaname = CURRENT_SCHEMA() AND tablename = ?;"
+
+	return sql, args
+}
+
+// Quote 标识符使用双引号并转换为小写
+// 加引号的标识符区分大小写，未加引号的会被折叠为小写，统一为小写后条件中未加引号的 Name 与 "name" 是同一列
+func (p *postgres) Quote(name string) string {
+	return quoteWith(strings.ToLower(name), `"`)
+}
+
+// BindVar postgres使用 $1, $2 ... 占位符
+func (p *postgres) BindVar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// SupportsReturning postgres支持 RETURNING
+func (p *postgres) SupportsReturning() bool {
+	return true
+}
+
+// ColumnsSQL format_type返回完整类型，如 timestamp with time zone
+func (p *postgres) ColumnsSQL(tableName string) (string, []interface{}) {
+	sql := "SELECT a.attname, format_type(a.atttypid, a.atttypmod) FROM pg_attribute a " +
+		"JOIN pg_class c ON c.oid = a.attrelid JOIN pg_namespace n ON n.oid = c.relnamespace " +
+		"WHERE n.nspname = CURRENT_SCHEMA() AND c.relname = ? AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum;"
+	return sql, []interface{}{strings.ToLower(tableName)}
+}
+
+// IndexesSQL 查询pg_index，不包含主键
+func (p *postgres) IndexesSQL(tableName string) (string, []interface{}) {
+	sql := "SELECT i.relname, ix.indisunique, a.attname FROM pg_index ix " +
+		"JOIN pg_class t ON t.oid = ix.indrelid JOIN pg_class i ON i.oid = ix.indexrelid " +
+		"JOIN pg_namespace n ON n.oid = t.relnamespace " +
+		"JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey) " +
+		"WHERE n.nspname = CURRENT_SCHEMA() AND t.relname = ? AND NOT ix.indisprimary " +
+		"ORDER BY i.relname, array_position(ix.indkey::int2[], a.attnum);"
+	return sql, []interface{}{strings.ToLower(tableName)}
+}
+
+// ModifyColumnSQL ALTER TABLE ... ALTER COLUMN ... TYPE，已有数据按USING转换
+func (p *postgres) ModifyColumnSQL(tableName, column, typ string) string {
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;",
+		p.Quote(tableName), p.Quote(column), typ, p.Quote(column), typ)
+}
+
+// DropIndexSQL 索引名在schema内唯一
+func (p *postgres) DropIndexSQL(tableName, index string) string {
+	return fmt.Sprintf("DROP INDEX %s;", p.Quote(index))
+}
+
+// AutoIncrementSQL 使用serial与bigserial，列的类型分别为integer与bigint
+func (p *postgres) AutoIncrementSQL(typ string) string {
+	switch typ {
+	case "bigint":
+		return "bigserial"
+	case "smallint":
+		return "smallserial"
+	}
+	return "serial"
+}
+
+// OnConflictSQL ON CONFLICT (...) DO UPDATE SET ...
+func (p *postgres) OnConflictSQL(columns, updates []string) string {
+	return onConflict(columns, updates)
+}
+
+// MaxBindVars 协议中参数个数以16位整数表示
+func (p *postgres) MaxBindVars() int {
+	return 65535
+}
+
+// DefaultValuesSQL INSERT INTO t DEFAULT VALUES
+func (p *postgres) DefaultValuesSQL() string {
+	return "DEFAULT VALUES"
+}
@@ -12,6 +12,7 @@ type sqlite3 struct {
 
 // ConvertTypeTo convert type to type of database
 func (s *sqlite3) ConvertTypeTo(typ reflect.Value) string {
+	typ = columnValue(typ)
 	switch typ.Kind() {
 	case reflect.Bool:
 		return "bool"
@@ -40,3 +41,60 @@ func (s *sqlite3) TableExistsSQL(tableName string) (string, []interface{}) {
 
 	return sql, args
 }
+
+// Quote 标识符使用双引号
+func (s *sqlite3) Quote(name string) string {
+	return quoteWith(name, `"`)
+}
+
+// BindVar sqlite3使用 ? 占位符
+func (s *sqlite3) BindVar(n int) string {
+	return "?"
+}
+
+// SupportsReturning sqlite 3.35 起支持 RETURNING
+func (s *sqlite3) SupportsReturning() bool {
+	return true
+}
+
+// ColumnsSQL 使用表值形式的 pragma_table_info
+func (s *sqlite3) ColumnsSQL(tableName string) (string, []interface{}) {
+	return "SELECT name, type FROM pragma_table_info(?) ORDER BY cid;", []interface{}{tableName}
+}
+
+// IndexesSQL 只查询CREATE INDEX创建的索引，不包含主键与UNIQUE约束生成的索引
+func (s *sqlite3) IndexesSQL(tableName string) (string, []interface{}) {
+	sql := "SELECT il.name, il.\"unique\", ii.name FROM pragma_index_list(?) AS il, pragma_index_info(il.name) AS ii " +
+		"WHERE il.origin = 'c' ORDER BY il.name, ii.seqno;"
+	return sql, []interface{}{tableName}
+}
+
+// ModifyColumnSQL sqlite不支持修改列类型
+func (s *sqlite3) ModifyColumnSQL(tableName, column, typ string) string {
+	return ""
+}
+
+// DropIndexSQL 索引名在数据库内唯一
+func (s *sqlite3) DropIndexSQL(tableName, index string) string {
+	return fmt.Sprintf("DROP INDEX %s;", s.Quote(index))
+}
+
+// AutoIncrementSQL 类型为integer的主键即为rowid，插入时未指定则自动生成
+func (s *sqlite3) AutoIncrementSQL(typ string) string {
+	return "integer"
+}
+
+// OnConflictSQL ON CONFLICT (...) DO UPDATE SET ... ，sqlite 3.24 起支持
+func (s *sqlite3) OnConflictSQL(columns, updates []string) string {
+	return onConflict(columns, updates)
+}
+
+// MaxBindVars sqlite 3.32 起SQLITE_MAX_VARIABLE_NUMBER默认为32766
+func (s *sqlite3) MaxBindVars() int {
+	return 32766
+}
+
+// DefaultValuesSQL INSERT INTO t DEFAULT VALUES
+func (s *sqlite3) DefaultValuesSQL() string {
+	return "DEFAULT VALUES"
+}
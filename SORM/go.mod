@@ -4,6 +4,7 @@ go 1.17
 
 require (
 	github.com/go-playground/assert v1.2.1
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.14
 	github.com/stretchr/testify v1.8.0
 )
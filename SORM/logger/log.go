@@ -16,9 +16,11 @@ var (
 	mu      sync.Mutex
 )
 
+// Error与Errorf只输出日志，不再调用log.Fatal退出进程
+// 执行SQL失败时错误同时返回给调用方，由调用方决定是否回滚（如迁移失败时回滚事务）
 var (
-	Error  = errlog.Fatal
-	Errorf = errlog.Fatalf
+	Error  = errlog.Println
+	Errorf = errlog.Printf
 	Info   = infolog.Println
 	Infof  = infolog.Printf
 )
@@ -0,0 +1,240 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"sorm/schema"
+	"sorm/session"
+	"strings"
+)
+
+// column 数据库中已有的列
+type column struct {
+	name string
+	typ  string
+}
+
+// Diff 比较表结构与模型，生成使表结构与模型一致的SQL语句，表结构一致时返回空
+// 包括新增列、删除列、修改列类型、新建或变化的索引与缺少的连接表，数据库中多出的索引保持不变
+// 不支持修改列类型的dialect（如sqlite）在删除列或修改类型时重建表并复制数据
+func (m *Migrator) Diff(value interface{}) ([]string, error) {
+	table := schema.ParseWithNamer(value, m.dialect, m.namer)
+	s := m.newSession()
+	joinTables, err := m.missingJoinTables(s, table)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := m.columns(s, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		sqls := []string{table.CreateTableSQL(m.dialect, "")}
+		for _, idx := range table.Indexes {
+			sqls = append(sqls, idx.CreateSQL(m.dialect, table.Name))
+		}
+		return append(sqls, joinTables...), nil
+	}
+
+	// 列名与索引名按不区分大小写比较，postgres中的标识符均为小写
+	existing := make(map[string]string, len(columns))
+	for _, col := range columns {
+		existing[strings.ToLower(col.name)] = col.typ
+	}
+	var added, changed, kept []*schema.Field
+	for _, field := range table.Fields {
+		typ, ok := existing[strings.ToLower(field.DBName)]
+		switch {
+		case !ok:
+			added = append(added, field)
+		case !sameType(typ, field.Type) && !(field.AutoIncrement && sameType(typ, m.dialect.AutoIncrementSQL(field.Type))):
+			changed = append(changed, field)
+			kept = append(kept, field)
+		default:
+			kept = append(kept, field)
+		}
+	}
+	var dropped []string
+	for _, col := range columns {
+		if !hasColumn(table, col.name) {
+			dropped = append(dropped, col.name)
+		}
+	}
+
+	if (len(changed) > 0 || len(dropped) > 0) && m.dialect.ModifyColumnSQL(table.Name, "", "") == "" {
+		return append(m.rebuild(table, kept), joinTables...), nil
+	}
+
+	var sqls []string
+	name := m.dialect.Quote(table.Name)
+	for _, field := range added {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", name, field.Definition(m.dialect)))
+	}
+	for _, field := range changed {
+		sqls = append(sqls, m.dialect.ModifyColumnSQL(table.Name, field.DBName, field.Type))
+	}
+	for _, col := range dropped {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", name, m.dialect.Quote(col)))
+	}
+
+	indexes, err := m.indexes(s, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range table.Indexes {
+		current, ok := indexes[strings.ToLower(idx.Name)]
+		if ok && current.Unique == idx.Unique && strings.EqualFold(strings.Join(current.Columns, ","), strings.Join(idx.Columns, ",")) {
+			continue
+		}
+		if ok {
+			sqls = append(sqls, m.dialect.DropIndexSQL(table.Name, idx.Name))
+		}
+		sqls = append(sqls, idx.CreateSQL(m.dialect, table.Name))
+	}
+	return append(sqls, joinTables...), nil
+}
+
+// AutoMigrate 在一个事务中执行各模型Diff生成的语句
+func (m *Migrator) AutoMigrate(values ...interface{}) error {
+	var sqls []string
+	for _, value := range values {
+		diff, err := m.Diff(value)
+		if err != nil {
+			return err
+		}
+		sqls = append(sqls, diff...)
+	}
+	if len(sqls) == 0 {
+		return nil
+	}
+	return m.transaction(func(s *session.Session) error {
+		for _, sql := range sqls {
+			if _, err := s.Raw(sql).Exec(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// rebuild 以模型结构新建临时表，复制kept中的列后替换原表
+// CREATE TABLE tmp (...); INSERT INTO tmp (...) SELECT ... FROM t; DROP TABLE t; ALTER TABLE tmp RENAME TO t;
+func (m *Migrator) rebuild(table *schema.Schema, kept []*schema.Field) []string {
+	name := m.dialect.Quote(table.Name)
+	tmp := "tmp_" + table.Name
+	sqls := []string{table.CreateTableSQL(m.dialect, tmp)}
+	if len(kept) > 0 {
+		columns := make([]string, len(kept))
+		for i, field := range kept {
+			columns[i] = m.dialect.Quote(field.DBName)
+		}
+		fields := strings.Join(columns, ", ")
+		sqls = append(sqls, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s;", m.dialect.Quote(tmp), fields, fields, name))
+	}
+	sqls = append(sqls,
+		fmt.Sprintf("DROP TABLE %s;", name),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", m.dialect.Quote(tmp), name))
+	// 删除原表时索引一并删除
+	for _, idx := range table.Indexes {
+		sqls = append(sqls, idx.CreateSQL(m.dialect, table.Name))
+	}
+	return sqls
+}
+
+func hasColumn(table *schema.Schema, name string) bool {
+	for _, column := range table.DBNames {
+		if strings.EqualFold(column, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// columns 表中已有的列，表不存在时为空
+func (m *Migrator) columns(s *session.Session, table string) ([]column, error) {
+	query, args := m.dialect.ColumnsSQL(table)
+	rows, err := s.Raw(query, args...).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []column
+	for rows.Next() {
+		var col column
+		if err := rows.Scan(&col.name, &col.typ); err != nil {
+			return nil, err
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// indexes 表中已有的索引，以小写的索引名为key，不包含主键
+func (m *Migrator) indexes(s *session.Session, table string) (map[string]*schema.Index, error) {
+	query, args := m.dialect.IndexesSQL(table)
+	rows, err := s.Raw(query, args...).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := make(map[string]*schema.Index)
+	for rows.Next() {
+		var name, column string
+		var unique bool
+		if err := rows.Scan(&name, &unique, &column); err != nil {
+			return nil, err
+		}
+		idx, ok := indexes[strings.ToLower(name)]
+		if !ok {
+			idx = &schema.Index{Name: name, Unique: unique}
+			indexes[strings.ToLower(name)] = idx
+		}
+		idx.Columns = append(idx.Columns, column)
+	}
+	return indexes, rows.Err()
+}
+
+// missingJoinTables 不存在的many-to-many连接表的建表语句
+func (m *Migrator) missingJoinTables(s *session.Session, table *schema.Schema) ([]string, error) {
+	var sqls []string
+	joinSQLs := table.JoinTablesSQL(m.dialect)
+	i := 0
+	for _, rel := range table.Relationships {
+		if rel.Type != schema.ManyToMany {
+			continue
+		}
+		columns, err := m.columns(s, rel.JoinTable)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			sqls = append(sqls, joinSQLs[i])
+		}
+		i++
+	}
+	return sqls, nil
+}
+
+var displayWidth = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+// typeAliases 数据库返回的类型名与dialect生成的类型名不同时的对应关系
+var typeAliases = map[string]string{
+	"tinyint(1)":               "boolean",
+	"bool":                     "boolean",
+	"integer":                  "int",
+	"timestamp with time zone": "timestamptz",
+	"character varying":        "varchar",
+}
+
+// sameType 比较数据库中的列类型与模型生成的列类型
+func sameType(actual, expected string) bool {
+	return normalizeType(actual) == normalizeType(expected)
+}
+
+func normalizeType(typ string) string {
+	typ = strings.ToLower(strings.Join(strings.Fields(typ), " "))
+	if alias, ok := typeAliases[typ]; ok {
+		return alias
+	}
+	return displayWidth.ReplaceAllString(typ, "$1")
+}
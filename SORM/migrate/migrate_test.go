@@ -0,0 +1,266 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sorm/dialect"
+	"sorm/schema"
+	"sorm/session"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sqlite3Dialector, _ = dialect.GetDialect("sqlite3")
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name")
+	require.NoError(t, err)
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	return names
+}
+
+func testMigrations(t *testing.T) []Migration {
+	t.Helper()
+	migrations, err := LoadFS(fstest.MapFS{
+		"1_create_user.up.sql":   {Data: []byte("CREATE TABLE User (Name text PRIMARY KEY); -- users; ok\nINSERT INTO User VALUES ('a;b');")},
+		"1_create_user.down.sql": {Data: []byte("DROP TABLE User;")},
+		"README.md":              {Data: []byte("ignored")},
+	})
+	require.NoError(t, err)
+	return append(migrations, Migration{
+		Version: 2,
+		Name:    "create_order",
+		Up: func(s *session.Session) error {
+			_, err := s.Raw("CREATE TABLE Orders (ID int)").Exec()
+			return err
+		},
+		Down: func(s *session.Session) error {
+			_, err := s.Raw("DROP TABLE Orders").Exec()
+			return err
+		},
+	})
+}
+
+func TestUpDown(t *testing.T) {
+	db := openDB(t)
+	m := New(db, sqlite3Dialector)
+	require.NoError(t, m.Register(testMigrations(t)...))
+	assert.Error(t, m.Register(Migration{Version: 1}))
+
+	require.NoError(t, m.Up(1))
+	assert.Equal(t, []string{"User", TableName}, tables(t, db))
+	var name string
+	require.NoError(t, db.QueryRow("SELECT Name FROM User").Scan(&name))
+	assert.Equal(t, "a;b", name)
+
+	require.NoError(t, m.Up(0))
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(status))
+	assert.True(t, status[0].Applied && status[1].Applied)
+	assert.False(t, status[1].AppliedAt.IsZero())
+
+	assert.True(t, errors.Is(m.Down(-1), ErrInvalidSteps))
+	require.NoError(t, m.Down(1))
+	assert.Equal(t, []string{"User", TableName}, tables(t, db))
+	require.NoError(t, m.Down(5))
+	assert.Equal(t, []string{TableName}, tables(t, db))
+	status, err = m.Status()
+	require.NoError(t, err)
+	assert.False(t, status[0].Applied || status[1].Applied)
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	db := openDB(t)
+	m := New(db, sqlite3Dialector)
+	boom := errors.New("boom")
+	require.NoError(t, m.Register(Migration{
+		Version: 1,
+		Name:    "broken",
+		UpSQL:   "CREATE TABLE User (Name text);",
+		Up:      func(s *session.Session) error { return boom },
+	}))
+	err := m.Up(0)
+	assert.True(t, errors.Is(err, boom))
+	// schema_migrations的创建也一并回滚
+	assert.Equal(t, 0, len(tables(t, db)))
+	status, err := m.Status()
+	require.NoError(t, err)
+	assert.False(t, status[0].Applied)
+
+	// 没有Down的迁移不能回滚
+	m = New(db, sqlite3Dialector)
+	require.NoError(t, m.Register(Migration{Version: 1, Name: "once", UpSQL: "CREATE TABLE User (Name text);"}))
+	require.NoError(t, m.Up(0))
+	assert.True(t, errors.Is(m.Down(1), ErrIrreversible))
+}
+
+func TestDryRun(t *testing.T) {
+	db := openDB(t)
+	m := New(db, sqlite3Dialector).DryRun()
+	require.NoError(t, m.Register(testMigrations(t)[0]))
+	require.NoError(t, m.Up(0))
+	assert.Equal(t, 0, len(tables(t, db)))
+
+	var sqls []string
+	for _, st := range m.Statements() {
+		sqls = append(sqls, st.SQL)
+	}
+	assert.Equal(t, []string{
+		`CREATE TABLE IF NOT EXISTS "schema_migrations" ("version" bigint PRIMARY KEY, "name" text, "applied_at" datetime);`,
+		"CREATE TABLE User (Name text PRIMARY KEY)",
+		"INSERT INTO User VALUES ('a;b')",
+		`INSERT INTO "schema_migrations" ("version", "name", "applied_at") VALUES (?, ?, ?)`,
+	}, sqls)
+}
+
+type Account struct {
+	ID      int `sorm:"PRIMARY KEY"`
+	Owner   string
+	Balance float64
+}
+
+func (Account) TableIndexes() []schema.Index {
+	return []schema.Index{{Columns: []string{"Owner"}, Unique: true}}
+}
+
+func TestDiff(t *testing.T) {
+	db := openDB(t)
+	m := New(db, sqlite3Dialector)
+
+	sqls, err := m.Diff(&Account{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`CREATE TABLE "Account" ("ID" int PRIMARY KEY,"Owner" text,"Balance" real);`,
+		`CREATE UNIQUE INDEX "idx_Account_Owner" ON "Account" ("Owner");`,
+	}, sqls)
+
+	// 删除列、修改类型在sqlite中通过重建表完成，已有数据被保留
+	_, err = db.Exec(`CREATE TABLE Account (ID int PRIMARY KEY, Owner int, Legacy text);
+		INSERT INTO Account VALUES (1, 7, 'x');`)
+	require.NoError(t, err)
+	sqls, err = m.Diff(&Account{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`CREATE TABLE "tmp_Account" ("ID" int PRIMARY KEY,"Owner" text,"Balance" real);`,
+		`INSERT INTO "tmp_Account" ("ID", "Owner") SELECT "ID", "Owner" FROM "Account";`,
+		`DROP TABLE "Account";`,
+		`ALTER TABLE "tmp_Account" RENAME TO "Account";`,
+		`CREATE UNIQUE INDEX "idx_Account_Owner" ON "Account" ("Owner");`,
+	}, sqls)
+	require.NoError(t, m.AutoMigrate(&Account{}))
+	var owner string
+	require.NoError(t, db.QueryRow("SELECT Owner FROM Account WHERE ID = 1").Scan(&owner))
+	assert.Equal(t, "7", owner)
+
+	sqls, err = m.Diff(&Account{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(sqls))
+
+	// 新增列与索引变化不需要重建表
+	_, err = db.Exec(`ALTER TABLE Account DROP COLUMN Balance; DROP INDEX idx_Account_Owner; CREATE INDEX idx_Account_Owner ON Account (Owner);`)
+	require.NoError(t, err)
+	sqls, err = m.Diff(&Account{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`ALTER TABLE "Account" ADD COLUMN "Balance" real;`,
+		`DROP INDEX "idx_Account_Owner";`,
+		`CREATE UNIQUE INDEX "idx_Account_Owner" ON "Account" ("Owner");`,
+	}, sqls)
+}
+
+func TestDiffWithNamer(t *testing.T) {
+	db := openDB(t)
+	m := New(db, sqlite3Dialector).SetNamer(schema.NamingStrategy{SnakeCase: true, PluralTables: true})
+	sqls, err := m.Diff(&Account{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`CREATE TABLE "accounts" ("id" int PRIMARY KEY,"owner" text,"balance" real);`,
+		`CREATE UNIQUE INDEX "idx_accounts_owner" ON "accounts" ("owner");`,
+	}, sqls)
+	require.NoError(t, m.AutoMigrate(&Account{}))
+
+	sqls, err = m.Diff(&Account{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(sqls))
+}
+
+type Event struct {
+	ID   int64 `sorm:"primary key;autoIncrement"`
+	Name string
+}
+
+func TestDiffAutoIncrement(t *testing.T) {
+	db := openDB(t)
+	m := New(db, sqlite3Dialector)
+	require.NoError(t, m.AutoMigrate(&Event{}))
+	sqls, err := m.Diff(&Event{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(sqls))
+}
+
+func TestNormalizeType(t *testing.T) {
+	assert.True(t, sameType("INTEGER", "int"))
+	assert.True(t, sameType("int(11) unsigned", "int unsigned"))
+	assert.True(t, sameType("tinyint(1)", "boolean"))
+	assert.True(t, sameType("timestamp with time zone", "timestamptz"))
+	assert.False(t, sameType("varchar(64)", "varchar(255)"))
+}
+
+func TestSplitStatements(t *testing.T) {
+	assert.Equal(t, []string{
+		"CREATE TABLE t (a text DEFAULT ';')",
+		`INSERT INTO "t;" VALUES ('--')`,
+		"SELECT 1 - 1",
+	}, splitStatements("CREATE TABLE t (a text DEFAULT ';');\n-- comment; here\nINSERT INTO \"t;\" VALUES ('--');SELECT 1 - 1;;"))
+}
+
+func TestSplitStatementsBlocks(t *testing.T) {
+	// 块注释中的分号，mysql的 /*! */ 保留
+	assert.Equal(t, []string{
+		"CREATE TABLE t (a int)",
+		"/*!40101 SET NAMES utf8 */",
+	}, splitStatements("/* header; */ CREATE TABLE t (a int) /* trailing; */;\n/*!40101 SET NAMES utf8 */;\n/* done; */"))
+
+	// postgres函数体
+	fn := "CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN NEW.a := 1; RETURN NEW; END; $$ LANGUAGE plpgsql"
+	tagged := "DO $body$ BEGIN PERFORM 1; END $body$"
+	assert.Equal(t, []string{fn, tagged, "SELECT $1"}, splitStatements(fn+";\n"+tagged+";\nSELECT $1;"))
+
+	// mysql触发器，块中包含IF与CASE
+	trigger := `CREATE TRIGGER t_bi BEFORE INSERT ON t FOR EACH ROW
+BEGIN
+  IF NEW.a < 0 THEN SET NEW.a = 0; END IF;
+  CASE NEW.a WHEN 1 THEN SET NEW.b = 'one'; ELSE SET NEW.b = 'many'; END CASE;
+  SET NEW.c = CASE WHEN NEW.a > 1 THEN 1 ELSE 0 END;
+END`
+	assert.Equal(t, []string{trigger, "INSERT INTO t (a) VALUES (1)"},
+		splitStatements(trigger+";\nINSERT INTO t (a) VALUES (1);"))
+
+	// 事务语句不是语句块
+	assert.Equal(t, []string{"BEGIN", "SELECT 1", "COMMIT"}, splitStatements("BEGIN; SELECT 1; COMMIT;"))
+
+	// 首行声明不拆分时整个文件作为一条语句
+	whole := "-- sorm:nosplit\nCREATE PROCEDURE p() BEGIN SELECT 1; END"
+	assert.Equal(t, []string{whole}, splitStatements(whole+"\n"))
+}
@@ -0,0 +1,198 @@
+// Package migrate 版本化的数据库迁移
+// 迁移按版本号顺序执行，已执行的版本记录在schema_migrations表中
+// mysql中每条DDL语句都会隐式提交，包含DDL的迁移失败时无法回滚已执行的语句
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sorm/session"
+	"strconv"
+	"strings"
+)
+
+// Func Go函数形式的迁移，在迁移所在的事务中执行
+type Func func(s *session.Session) error
+
+// Migration 一个版本的迁移，Up/Down为Go函数，UpSQL/DownSQL为SQL语句，同时设置时先执行SQL
+type Migration struct {
+	// Version 版本号，按数值升序执行，如 20221001120000
+	Version int64
+	Name    string
+	Up      Func
+	Down    Func
+	UpSQL   string
+	DownSQL string
+}
+
+// reversible 是否可以回滚
+func (m *Migration) reversible() bool {
+	return m.Down != nil || m.DownSQL != ""
+}
+
+// migrationFile 文件名格式为 <version>_<name>.up.sql 与 <version>_<name>.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadDir 读取目录中的SQL迁移文件
+func LoadDir(dir string) ([]Migration, error) {
+	return LoadFS(os.DirFS(dir))
+}
+
+// LoadFS 读取fsys根目录中的SQL迁移文件，可配合embed使用
+func LoadFS(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	var migrations []*Migration
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+			migrations = append(migrations, m)
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d has different names %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	result := make([]Migration, len(migrations))
+	for i, m := range migrations {
+		result[i] = *m
+	}
+	return result, nil
+}
+
+// noSplit 首行为该注释的SQL文件不拆分，整个文件作为一条语句执行
+const noSplit = "-- sorm:nosplit"
+
+// dollarQuote postgres的 $$ 或 $tag$ 引号，常用于函数体
+var dollarQuote = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// splitStatements 按分号拆分多条SQL语句，忽略以下内容中的分号：
+// 引号、-- 与 /* */ 注释、postgres的 $$ 引号、mysql触发器与存储过程的 BEGIN ... END 块
+// 注释被去掉，mysql的 /*! */ 与 /*+ */ 保留；无法正确拆分时可在首行写 -- sorm:nosplit
+func splitStatements(sql string) []string {
+	if strings.HasPrefix(strings.TrimSpace(sql), noSplit) {
+		return []string{strings.TrimSpace(sql)}
+	}
+	var (
+		statements []string
+		b          strings.Builder
+		// BEGIN ... END 与 CASE ... END 的嵌套层数
+		depth int
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(b.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		b.Reset()
+	}
+	// until 返回从i开始第一个end之后的位置，没有时返回sql的长度
+	until := func(i int, end string) int {
+		if n := strings.Index(sql[i:], end); n >= 0 {
+			return i + n + len(end)
+		}
+		return len(sql)
+	}
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			n := until(i+1, string(c))
+			b.WriteString(sql[i:n])
+			i = n
+		case strings.HasPrefix(sql[i:], "--"):
+			// 保留换行，避免前后两行连在一起
+			if n := strings.IndexByte(sql[i:], '\n'); n >= 0 {
+				i += n
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			n := until(i+2, "*/")
+			if strings.HasPrefix(sql[i:], "/*!") || strings.HasPrefix(sql[i:], "/*+") {
+				b.WriteString(sql[i:n])
+			}
+			i = n
+		case c == '$' && (i == 0 || !isWordByte(sql[i-1])) && dollarQuote.MatchString(sql[i:]):
+			tag := dollarQuote.FindString(sql[i:])
+			n := until(i+len(tag), tag)
+			b.WriteString(sql[i:n])
+			i = n
+		case isWordByte(c) && (i == 0 || !isWordByte(sql[i-1])):
+			n := i
+			for n < len(sql) && isWordByte(sql[n]) {
+				n++
+			}
+			switch strings.ToUpper(sql[i:n]) {
+			case "BEGIN":
+				// BEGIN; 与 BEGIN TRANSACTION 开始事务，不是语句块
+				switch word, _ := nextWord(sql[n:]); word {
+				case "", "TRANSACTION", "WORK":
+				default:
+					depth++
+				}
+			case "CASE":
+				depth++
+			case "END":
+				// END IF、END LOOP等结束的块没有计入层数，END CASE中的CASE不是新的块
+				switch word, end := nextWord(sql[n:]); word {
+				case "IF", "LOOP", "WHILE", "REPEAT":
+				case "CASE":
+					n += end
+					fallthrough
+				default:
+					if depth > 0 {
+						depth--
+					}
+				}
+			}
+			b.WriteString(sql[i:n])
+			i = n
+		case c == ';' && depth == 0:
+			flush()
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return statements
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// nextWord sql开头跳过空白后的单词，转换为大写，end为单词结束的位置
+func nextWord(sql string) (word string, end int) {
+	start := len(sql) - len(strings.TrimLeft(sql, " \t\r\n"))
+	end = start
+	for end < len(sql) && isWordByte(sql[end]) {
+		end++
+	}
+	return strings.ToUpper(sql[start:end]), end
+}
@@ -0,0 +1,272 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sorm/dialect"
+	"sorm/schema"
+	"sorm/session"
+	"sort"
+	"time"
+)
+
+// TableName 记录已执行版本的表
+const TableName = "schema_migrations"
+
+var (
+	// ErrIrreversible 迁移没有Down或DownSQL，无法回滚
+	ErrIrreversible = errors.New("migrate: migration is irreversible")
+	// ErrUnknownVersion 已执行的版本没有注册对应的迁移
+	ErrUnknownVersion = errors.New("migrate: unknown version")
+	// ErrInvalidSteps 回滚的步数为负数
+	ErrInvalidSteps = errors.New("migrate: invalid steps")
+)
+
+// Migrator 执行迁移
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect.Dialector
+	namer      schema.Namer
+	migrations []Migration
+	dryRun     bool
+	statements []session.Statement
+	// DryRun时schema_migrations的建表语句只记录一次
+	ensured bool
+}
+
+// Status 迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// New create a migrator
+func New(db *sql.DB, d dialect.Dialector) *Migrator {
+	return &Migrator{db: db, dialect: d}
+}
+
+// SetNamer 设置Diff与迁移函数中session使用的命名策略
+func (m *Migrator) SetNamer(namer schema.Namer) *Migrator {
+	m.namer = namer
+	return m
+}
+
+// newSession 使用迁移器的命名策略创建session
+func (m *Migrator) newSession() *session.Session {
+	return session.New(m.db, m.dialect).SetNamer(m.namer)
+}
+
+// Register 注册迁移，版本号重复时返回错误
+func (m *Migrator) Register(migrations ...Migration) error {
+	for _, mig := range migrations {
+		if mig.Version <= 0 {
+			return fmt.Errorf("migrate: invalid version %d", mig.Version)
+		}
+		for _, registered := range m.migrations {
+			if registered.Version == mig.Version {
+				return fmt.Errorf("migrate: duplicate version %d", mig.Version)
+			}
+		}
+		m.migrations = append(m.migrations, mig)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return nil
+}
+
+// DryRun 开启后只记录将要执行的SQL，不修改数据库，通过Statements查看
+// 读取已执行版本与表结构的查询仍会访问数据库，Go函数中的查询返回session.ErrDryRun
+func (m *Migrator) DryRun() *Migrator {
+	m.dryRun = true
+	return m
+}
+
+// Statements 返回DryRun模式下记录的SQL
+func (m *Migrator) Statements() []session.Statement {
+	return m.statements
+}
+
+// Up 依次执行版本号不大于target且未执行的迁移，target为0时执行全部
+func (m *Migrator) Up(target int64) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	for i := range m.migrations {
+		mig := &m.migrations[i]
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if err := m.run(mig, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down 按版本号从大到小回滚最近执行的steps个迁移，steps为0时不回滚
+func (m *Migrator) Down(steps int) error {
+	if steps < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidSteps, steps)
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	for _, version := range versions {
+		mig := m.find(version)
+		if mig == nil {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+		if !mig.reversible() {
+			return fmt.Errorf("%w: %d_%s", ErrIrreversible, mig.Version, mig.Name)
+		}
+		if err := m.run(mig, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status 返回所有已注册迁移的执行状态，按版本号排序
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		status[i] = Status{Version: mig.Version, Name: mig.Name, Applied: ok, AppliedAt: at}
+	}
+	return status, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// applied 已执行的版本与执行时间，schema_migrations不存在时为空
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	applied := make(map[int64]time.Time)
+	s := m.newSession()
+	query, args := m.dialect.TableExistsSQL(TableName)
+	var name string
+	if err := s.Raw(query, args...).QueryRow().Scan(&name); err != nil {
+		if err == sql.ErrNoRows {
+			return applied, nil
+		}
+		return nil, err
+	}
+
+	rows, err := s.Raw(fmt.Sprintf("SELECT %s, %s FROM %s",
+		m.dialect.Quote("version"), m.dialect.Quote("applied_at"), m.dialect.Quote(TableName))).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// run 在一个事务中执行迁移并更新schema_migrations
+// mysql的DDL隐式提交事务，失败时只能回滚最后一条DDL之后的语句
+func (m *Migrator) run(mig *Migration, up bool) error {
+	return m.transaction(func(s *session.Session) error {
+		if !m.dryRun || !m.ensured {
+			if err := m.ensureTable(s); err != nil {
+				return err
+			}
+			m.ensured = true
+		}
+		sqlText, fn := mig.UpSQL, mig.Up
+		if !up {
+			sqlText, fn = mig.DownSQL, mig.Down
+		}
+		for _, stmt := range splitStatements(sqlText) {
+			if _, err := s.Raw(stmt).Exec(); err != nil {
+				return fmt.Errorf("migrate: %d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+		if fn != nil {
+			if err := fn(s); err != nil {
+				return fmt.Errorf("migrate: %d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+
+		table := m.dialect.Quote(TableName)
+		var err error
+		if up {
+			_, err = s.Raw(fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES (?, ?, ?)", table,
+				m.dialect.Quote("version"), m.dialect.Quote("name"), m.dialect.Quote("applied_at")),
+				mig.Version, mig.Name, time.Now().UTC()).Exec()
+		} else {
+			_, err = s.Raw(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", table, m.dialect.Quote("version")), mig.Version).Exec()
+		}
+		return err
+	})
+}
+
+// transaction 在事务中执行fn，出错时回滚；DryRun时不开启事务，只记录SQL
+func (m *Migrator) transaction(fn func(s *session.Session) error) (err error) {
+	s := m.newSession()
+	if m.dryRun {
+		s.DryRun()
+		defer func() { m.statements = append(m.statements, s.Statements()...) }()
+		return fn(s)
+	}
+
+	if _, err = s.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = s.Rollback()
+			panic(p)
+		} else if err != nil {
+			_ = s.Rollback()
+		} else {
+			err = s.Commit()
+		}
+	}()
+	return fn(s)
+}
+
+// ensureTable 创建schema_migrations
+func (m *Migrator) ensureTable(s *session.Session) error {
+	_, err := s.Raw(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s %s PRIMARY KEY, %s %s, %s %s);",
+		m.dialect.Quote(TableName),
+		m.dialect.Quote("version"), m.dialect.ConvertTypeTo(reflect.ValueOf(int64(0))),
+		m.dialect.Quote("name"), m.dialect.ConvertTypeTo(reflect.ValueOf("")),
+		m.dialect.Quote("applied_at"), m.dialect.ConvertTypeTo(reflect.ValueOf(time.Time{})))).Exec()
+	return err
+}
@@ -0,0 +1,130 @@
+// 建表、建索引语句，session.CreateTable与迁移的表结构比较共用
+package schema
+
+import (
+	"fmt"
+	"sorm/dialect"
+	"strings"
+)
+
+// Index 索引
+type Index struct {
+	// Name 为空时为 idx_表名_列名
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// ITableIndexes 模型实现该接口声明表上的索引
+type ITableIndexes interface {
+	TableIndexes() []Index
+}
+
+// Definition 建表语句中的列定义，如 "name" varchar(64) NOT NULL DEFAULT 0
+func (f *Field) Definition(d dialect.Dialector) string {
+	typ := f.Type
+	if f.AutoIncrement {
+		typ = d.AutoIncrementSQL(typ)
+	}
+	parts := []string{d.Quote(f.DBName), typ}
+	// 写在无法识别的tag中的PRIMARY KEY随Constraint输出
+	if f.PrimaryKey && !strings.Contains(strings.ToUpper(f.Constraint), "PRIMARY KEY") {
+		parts = append(parts, "PRIMARY KEY")
+	}
+	if f.NotNull {
+		parts = append(parts, "NOT NULL")
+	}
+	if f.Unique {
+		parts = append(parts, "UNIQUE")
+	}
+	if f.Default != "" {
+		parts = append(parts, "DEFAULT "+f.Default)
+	}
+	if f.Constraint != "" {
+		parts = append(parts, f.Constraint)
+	}
+	return strings.Join(parts, " ")
+}
+
+// CreateTableSQL 以name为表名创建表的语句，name为空时使用模型的表名
+func (s *Schema) CreateTableSQL(d dialect.Dialector, name string) string {
+	if name == "" {
+		name = s.Name
+	}
+	columns := make([]string, len(s.Fields))
+	for i, field := range s.Fields {
+		columns[i] = field.Definition(d)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s);", d.Quote(name), strings.Join(columns, ","))
+}
+
+// JoinTablesSQL 创建many-to-many连接表的语句，连接表已存在时跳过
+func (s *Schema) JoinTablesSQL(d dialect.Dialector) []string {
+	var sqls []string
+	for _, rel := range s.Relationships {
+		if rel.Type != ManyToMany {
+			continue
+		}
+		sqls = append(sqls, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s %s,%s %s,PRIMARY KEY (%s, %s));",
+			d.Quote(rel.JoinTable),
+			d.Quote(rel.JoinForeignKey), s.GetField(rel.References).Type,
+			d.Quote(rel.JoinReferences), rel.FieldSchema.GetField(rel.TargetKey).Type,
+			d.Quote(rel.JoinForeignKey), d.Quote(rel.JoinReferences)))
+	}
+	return sqls
+}
+
+// CreateSQL 在table上创建索引的语句
+func (idx *Index) CreateSQL(d dialect.Dialector, table string) string {
+	unique := ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+	columns := make([]string, len(idx.Columns))
+	for i, column := range idx.Columns {
+		columns[i] = d.Quote(column)
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", unique, d.Quote(idx.Name), d.Quote(table), strings.Join(columns, ", "))
+}
+
+// parseIndexes 合并列tag与ITableIndexes声明的索引并补全索引名
+// tag中同名的索引合并为多列索引，列按字段顺序排列；ITableIndexes中的列可以是字段名或列名
+func (s *Schema) parseIndexes(tagIndexes map[*Field][]fieldIndex) {
+	byName := make(map[string]*Index)
+	for _, field := range s.Fields {
+		for _, fi := range tagIndexes[field] {
+			name := fi.name
+			if name == "" {
+				name = "idx_" + s.Name + "_" + field.DBName
+			}
+			if idx, ok := byName[name]; ok {
+				idx.Columns = append(idx.Columns, field.DBName)
+				idx.Unique = idx.Unique || fi.unique
+				continue
+			}
+			idx := &Index{Name: name, Columns: []string{field.DBName}, Unique: fi.unique}
+			byName[name] = idx
+			s.Indexes = append(s.Indexes, idx)
+		}
+	}
+
+	declared, ok := s.Model.(ITableIndexes)
+	if !ok {
+		return
+	}
+	for _, idx := range declared.TableIndexes() {
+		idx := idx
+		columns := make([]string, len(idx.Columns))
+		for i, column := range idx.Columns {
+			columns[i] = column
+			if field := s.LookUpField(column); field != nil {
+				columns[i] = field.DBName
+			}
+		}
+		idx.Columns = columns
+		if idx.Name == "" {
+			idx.Name = "idx_" + s.Name + "_" + strings.Join(idx.Columns, "_")
+		}
+		s.Indexes = append(s.Indexes, &idx)
+	}
+}
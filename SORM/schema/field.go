@@ -0,0 +1,146 @@
+// 字段的读写，处理嵌入结构体、自定义列类型与JSON列
+package schema
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sorm/dialect"
+)
+
+// IDataType 自定义类型实现该接口指定列类型，优先于dialect的类型映射
+type IDataType interface {
+	DataType() string
+}
+
+var dataTypeType = reflect.TypeOf((*IDataType)(nil)).Elem()
+
+// columnType 字段的列类型：IDataType > JSON列 > dialect的类型映射
+func columnType(d dialect.Dialector, t reflect.Type, serialized bool) string {
+	if elem := indirectType(t); reflect.PtrTo(elem).Implements(dataTypeType) {
+		return reflect.New(elem).Interface().(IDataType).DataType()
+	}
+	if serialized {
+		// 各dialect中[]byte对应的类型没有长度限制
+		return d.ConvertTypeTo(reflect.ValueOf([]byte{}))
+	}
+	return d.ConvertTypeTo(reflect.New(t).Elem())
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// embeddable 匿名字段为结构体或结构体指针时展开，time.Time与自定义列类型除外
+func embeddable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return relationModel(t) != nil
+}
+
+// hasPrefix index是否位于prefixes中的某个字段之下
+func hasPrefix(index []int, prefixes [][]int) bool {
+	for _, prefix := range prefixes {
+		if len(index) <= len(prefix) {
+			continue
+		}
+		matched := true
+		for i := range prefix {
+			if index[i] != prefix[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ValueOf 结构体v中该字段的值，所在的嵌入结构体指针为nil时返回零值
+func (f *Field) ValueOf(v reflect.Value) reflect.Value {
+	v = reflect.Indirect(v)
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Zero(v.Type().Elem().FieldByIndex(f.index[i:]).Type)
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// Settable 结构体v中可赋值的字段，所在的嵌入结构体指针为nil时先初始化，v需要可寻址
+func (f *Field) Settable(v reflect.Value) reflect.Value {
+	v = reflect.Indirect(v)
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// DBValue 写入数据库的值，JSON列在执行时序列化
+func (f *Field) DBValue(v reflect.Value) interface{} {
+	value := f.ValueOf(v).Interface()
+	if f.Serializer == "json" {
+		return jsonValue{value}
+	}
+	return value
+}
+
+// jsonValue 以JSON写入的值，nil指针、切片与map写入NULL
+type jsonValue struct {
+	v interface{}
+}
+
+// Value 实现driver.Valuer接口
+func (j jsonValue) Value() (driver.Value, error) {
+	if rv := reflect.ValueOf(j.v); !rv.IsValid() || (isNilable(rv.Kind()) && rv.IsNil()) {
+		return nil, nil
+	}
+	return json.Marshal(j.v)
+}
+
+// JSONScanner 将JSON列反序列化到Dest，NULL时Dest置为零值
+type JSONScanner struct {
+	Dest reflect.Value
+}
+
+// Scan 实现sql.Scanner接口
+func (j JSONScanner) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		j.Dest.Set(reflect.Zero(j.Dest.Type()))
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("sorm: cannot unmarshal %T into %s", src, j.Dest.Type())
+	}
+	return json.Unmarshal(data, j.Dest.Addr().Interface())
+}
+
+func isNilable(k reflect.Kind) bool {
+	switch k {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+// 命名策略，决定模型名、字段名到表名、列名的映射
+package schema
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Namer 表名与列名的命名策略
+type Namer interface {
+	// TableName 模型的类型名转换为表名
+	TableName(model string) string
+	// ColumnName 字段名转换为列名
+	ColumnName(field string) string
+}
+
+// NamingStrategy 内置的命名策略，零值保持Go中的名称不变
+type NamingStrategy struct {
+	// TablePrefix 表名前缀
+	TablePrefix string
+	// SnakeCase 表名与列名使用snake_case，如 UserID -> user_id
+	SnakeCase bool
+	// PluralTables 表名使用复数形式，如 User -> users
+	PluralTables bool
+}
+
+// DefaultNamer Parse使用的命名策略
+var DefaultNamer Namer = NamingStrategy{}
+
+// TableName 实现Namer接口
+func (ns NamingStrategy) TableName(model string) string {
+	name := model
+	if ns.SnakeCase {
+		name = toSnakeCase(name)
+	}
+	if ns.PluralTables {
+		name = pluralize(name)
+	}
+	return ns.TablePrefix + name
+}
+
+// ColumnName 实现Namer接口
+func (ns NamingStrategy) ColumnName(field string) string {
+	if ns.SnakeCase {
+		return toSnakeCase(field)
+	}
+	return field
+}
+
+// toSnakeCase 连续的大写字母视为一个单词，如 HTTPServerID -> http_server_id
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// pluralize 英文名词复数的常见规则，不处理不规则变化
+func pluralize(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case name == "":
+		return name
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + "ies"
+	default:
+		return name + "s"
+	}
+}
@@ -0,0 +1,196 @@
+// 关联关系，由结构体、结构体指针及其切片类型的字段解析
+// tag格式为 sorm:"foreignKey:UserName;references:Name;many2many:user_languages;cascade:save,delete"
+package schema
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// RelationshipType 关联类型
+type RelationshipType string
+
+const (
+	HasOne     RelationshipType = "has_one"
+	HasMany    RelationshipType = "has_many"
+	BelongsTo  RelationshipType = "belongs_to"
+	ManyToMany RelationshipType = "many_to_many"
+)
+
+// Relationship 模型字段上的关联关系
+type Relationship struct {
+	// Name 字段名
+	Name string
+	Type RelationshipType
+	// FieldSchema 关联模型
+	FieldSchema *Schema
+	// ForeignKey has-one、has-many为关联模型中的外键，belongs-to为当前模型中的外键
+	ForeignKey string
+	// References has-one、has-many、many-to-many为当前模型中被引用的列，belongs-to为关联模型中被引用的列
+	References string
+	// many-to-many的连接表，JoinForeignKey引用当前模型的References，JoinReferences引用关联模型的TargetKey
+	JoinTable      string
+	JoinForeignKey string
+	JoinReferences string
+	TargetKey      string
+	// CascadeSave Insert时保存字段中的关联值，默认开启
+	CascadeSave bool
+	// CascadeDelete 删除记录时删除关联的记录，many-to-many只删除连接表中的记录
+	CascadeDelete bool
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// relationModel 字段是关联关系时返回关联的结构体类型，否则返回nil
+// 支持 T、*T、[]T、[]*T，time.Time以及实现了Valuer、Scanner、IDataType的类型作为列
+func relationModel(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil
+	}
+	if t.Implements(valuerType) || reflect.PtrTo(t).Implements(scannerType) || reflect.PtrTo(t).Implements(dataTypeType) {
+		return nil
+	}
+	return t
+}
+
+// parseTagSettings 解析 key:value;key:value 形式的tag，key不区分大小写
+func parseTagSettings(tag string) map[string]string {
+	settings := make(map[string]string)
+	for _, item := range strings.Split(tag, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, ":", 2)
+		key := strings.ToUpper(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			settings[key] = strings.TrimSpace(kv[1])
+		} else {
+			settings[key] = key
+		}
+	}
+	return settings
+}
+
+// parseRelationship 根据字段类型与tag推断关联类型和外键
+// 外键默认命名：has-one/has-many为 当前模型名+References，belongs-to为 字段名+关联模型主键
+// 外键等均为字段名，只有连接表的列名经过命名策略转换
+func (s *Schema) parseRelationship(modelType reflect.Type, p reflect.StructField, fieldSchema *Schema, namer Namer) (*Relationship, error) {
+	settings := parseTagSettings(p.Tag.Get("sorm"))
+	rel := &Relationship{
+		Name:        p.Name,
+		FieldSchema: fieldSchema,
+		ForeignKey:  settings["FOREIGNKEY"],
+		References:  settings["REFERENCES"],
+		CascadeSave: true,
+	}
+	if cascade, ok := settings["CASCADE"]; ok {
+		rel.CascadeSave = false
+		for _, option := range strings.Split(cascade, ",") {
+			switch strings.ToLower(strings.TrimSpace(option)) {
+			case "save":
+				rel.CascadeSave = true
+			case "delete":
+				rel.CascadeDelete = true
+			}
+		}
+	}
+
+	ownerKey := func() (string, error) {
+		if rel.References != "" {
+			return rel.References, s.requireField(rel.References)
+		}
+		if s.PrimaryField == nil {
+			return "", fmt.Errorf("sorm: %s.%s: %s has no primary key", modelType.Name(), p.Name, s.Name)
+		}
+		return s.PrimaryField.Name, nil
+	}
+	var err error
+
+	if joinTable, ok := settings["MANY2MANY"]; ok {
+		rel.Type = ManyToMany
+		rel.JoinTable = joinTable
+		if rel.References, err = ownerKey(); err != nil {
+			return nil, err
+		}
+		if fieldSchema.PrimaryField == nil {
+			return nil, fmt.Errorf("sorm: %s.%s: %s has no primary key", modelType.Name(), p.Name, fieldSchema.Name)
+		}
+		rel.TargetKey = fieldSchema.PrimaryField.Name
+		rel.JoinForeignKey = settingOr(settings, "JOINFOREIGNKEY", namer.ColumnName(modelType.Name()+rel.References))
+		rel.JoinReferences = settingOr(settings, "JOINREFERENCES", namer.ColumnName(fieldSchema.modelName()+rel.TargetKey))
+		if rel.JoinForeignKey == rel.JoinReferences {
+			return nil, fmt.Errorf("sorm: %s.%s: join columns must differ, set joinForeignKey and joinReferences", modelType.Name(), p.Name)
+		}
+		return rel, nil
+	}
+
+	if p.Type.Kind() == reflect.Slice {
+		rel.Type = HasMany
+		if rel.References, err = ownerKey(); err != nil {
+			return nil, err
+		}
+		if rel.ForeignKey == "" {
+			rel.ForeignKey = modelType.Name() + rel.References
+		}
+		return rel, fieldSchema.requireField(rel.ForeignKey)
+	}
+
+	// 单个结构体：外键在当前模型中为belongs-to，在关联模型中为has-one
+	if rel.ForeignKey == "" && fieldSchema.PrimaryField != nil {
+		if references := settingOr(settings, "REFERENCES", fieldSchema.PrimaryField.Name); s.GetField(p.Name+references) != nil {
+			rel.ForeignKey = p.Name + references
+		}
+	}
+	if rel.ForeignKey != "" && s.GetField(rel.ForeignKey) != nil {
+		rel.Type = BelongsTo
+		if rel.References == "" {
+			if fieldSchema.PrimaryField == nil {
+				return nil, fmt.Errorf("sorm: %s.%s: %s has no primary key", modelType.Name(), p.Name, fieldSchema.Name)
+			}
+			rel.References = fieldSchema.PrimaryField.Name
+		}
+		return rel, fieldSchema.requireField(rel.References)
+	}
+
+	rel.Type = HasOne
+	if rel.References, err = ownerKey(); err != nil {
+		return nil, err
+	}
+	if rel.ForeignKey == "" {
+		rel.ForeignKey = modelType.Name() + rel.References
+	}
+	return rel, fieldSchema.requireField(rel.ForeignKey)
+}
+
+func (s *Schema) requireField(name string) error {
+	if s.GetField(name) == nil {
+		return fmt.Errorf("sorm: %s has no column %s", s.Name, name)
+	}
+	return nil
+}
+
+// modelName 模型的类型名，用于推断外键
+func (s *Schema) modelName() string {
+	return reflect.Indirect(reflect.ValueOf(s.Model)).Type().Name()
+}
+
+func settingOr(settings map[string]string, key, def string) string {
+	if v, ok := settings[key]; ok && v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Customer struct {
+	Name    string `sorm:"PRIMARY KEY"`
+	Profile *Profile
+	Orders  []Order `sorm:"cascade:save,delete"`
+	Tags    []*Tag  `sorm:"many2many:customer_tags"`
+}
+
+type Profile struct {
+	ID           int `sorm:"PRIMARY KEY"`
+	CustomerName string
+}
+
+type Order struct {
+	ID           int
+	CustomerName string
+	Customer     *Customer
+	Buyer        Customer `sorm:"foreignKey:BuyerName;cascade:none"`
+	BuyerName    string
+}
+
+type Tag struct {
+	ID    int
+	Label string
+}
+
+func TestParseRelationships(t *testing.T) {
+	customer := Parse(&Customer{}, sqlite3Dialector)
+	assert.Equal(t, []string{"Name"}, customer.FieldNames)
+	assert.Equal(t, "Name", customer.PrimaryField.Name)
+
+	profile := customer.GetRelationship("Profile")
+	assert.Equal(t, HasOne, profile.Type)
+	assert.Equal(t, "CustomerName", profile.ForeignKey)
+	assert.Equal(t, "Name", profile.References)
+	assert.True(t, profile.CascadeSave)
+	assert.False(t, profile.CascadeDelete)
+
+	orders := customer.GetRelationship("Orders")
+	assert.Equal(t, HasMany, orders.Type)
+	assert.Equal(t, "CustomerName", orders.ForeignKey)
+	assert.True(t, orders.CascadeDelete)
+
+	// 相互引用的模型共用同一个Schema
+	owner := orders.FieldSchema.GetRelationship("Customer")
+	assert.Equal(t, BelongsTo, owner.Type)
+	assert.Equal(t, "CustomerName", owner.ForeignKey)
+	assert.Equal(t, "Name", owner.References)
+	assert.Same(t, customer, owner.FieldSchema)
+
+	buyer := orders.FieldSchema.GetRelationship("Buyer")
+	assert.Equal(t, BelongsTo, buyer.Type)
+	assert.Equal(t, "BuyerName", buyer.ForeignKey)
+	assert.False(t, buyer.CascadeSave)
+
+	tags := customer.GetRelationship("Tags")
+	assert.Equal(t, ManyToMany, tags.Type)
+	assert.Equal(t, "customer_tags", tags.JoinTable)
+	assert.Equal(t, "CustomerName", tags.JoinForeignKey)
+	assert.Equal(t, "TagID", tags.JoinReferences)
+	assert.Equal(t, "ID", tags.TargetKey)
+}
+
+func TestParseRelationshipMissingForeignKey(t *testing.T) {
+	type Item struct {
+		ID int
+	}
+	type Cart struct {
+		ID    int
+		Items []Item
+	}
+	assert.PanicsWithValue(t, "sorm: Item has no column CartID", func() {
+		Parse(&Cart{}, sqlite3Dialector)
+	})
+}
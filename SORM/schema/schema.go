@@ -12,12 +12,29 @@ import (
 // 		Age int
 // }
 type Field struct {
-	// Name name of column
+	// Name 字段名
 	Name string
+	// DBName 列名，由命名策略生成，tag中的column优先
+	DBName string
 	// Type type of column
 	Type string
 	// Tag tag of column
 	Tag string
+	// Size string类型的长度，映射为varchar(size)
+	Size int
+	// Default 列的默认值，原样写入DEFAULT子句
+	Default    string
+	NotNull    bool
+	Unique     bool
+	PrimaryKey bool
+	// AutoIncrement 自增主键，插入零值时由数据库生成并写回
+	AutoIncrement bool
+	// Constraint tag中无法识别的部分，原样拼接到列定义中
+	Constraint string
+	// Serializer 为json时字段以JSON写入与读取
+	Serializer string
+	// index 字段在结构体中的位置，嵌入结构体中的字段包含多级
+	index []int
 }
 
 // Schema 表结构
@@ -27,7 +44,15 @@ type Schema struct {
 	Name       string
 	Fields     []*Field
 	FieldNames []string
-	FieldMap   map[string]*Field
+	// DBNames 与FieldNames一一对应的列名
+	DBNames  []string
+	FieldMap map[string]*Field
+	// PrimaryField 主键列，tag中声明primary key的列，没有时为名为ID的列
+	PrimaryField *Field
+	// Relationships 结构体及结构体切片类型的字段解析为关联关系，不作为列
+	Relationships []*Relationship
+	// Indexes 表上的索引，不包含主键
+	Indexes []*Index
 }
 
 // GetField return field
@@ -35,18 +60,55 @@ func (s *Schema) GetField(name string) *Field {
 	return s.FieldMap[name]
 }
 
+// LookUpField 按字段名或列名查找
+func (s *Schema) LookUpField(name string) *Field {
+	if field := s.FieldMap[name]; field != nil {
+		return field
+	}
+	for _, field := range s.Fields {
+		if field.DBName == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// GetRelationship 按字段名获取关联关系
+func (s *Schema) GetRelationship(name string) *Relationship {
+	for _, rel := range s.Relationships {
+		if rel.Name == name {
+			return rel
+		}
+	}
+	return nil
+}
+
 type ITableName interface {
 	TableName() string
 }
 
-// Parse 将任意对象解析为Schema实例
+// Parse 使用DefaultNamer将任意对象解析为Schema实例
+// 无法推断关联关系的外键或tag不合法时panic，与无法映射的列类型一致
 func Parse(dest interface{}, dialector dialect.Dialector) *Schema {
+	return ParseWithNamer(dest, dialector, DefaultNamer)
+}
+
+// ParseWithNamer 使用指定的命名策略解析，ITableName与tag中的column优先于命名策略
+func ParseWithNamer(dest interface{}, dialector dialect.Dialector, namer Namer) *Schema {
+	if namer == nil {
+		namer = DefaultNamer
+	}
+	return parse(dest, dialector, namer, make(map[reflect.Type]*Schema))
+}
+
+// parse 解析dest，parsed记录解析过的模型，避免关联关系相互引用时无限递归
+func parse(dest interface{}, dialector dialect.Dialector, namer Namer, parsed map[reflect.Type]*Schema) *Schema {
 	// 入参是一个对象的指针，使用reflect.Indirect来获取指针指向的实例
 	modelType := reflect.Indirect(reflect.ValueOf(dest)).Type()
 	var tableName string
 	t, ok := dest.(ITableName)
 	if !ok {
-		tableName = modelType.Name()
+		tableName = namer.TableName(modelType.Name())
 	} else {
 		tableName = t.TableName()
 	}
@@ -55,23 +117,78 @@ func Parse(dest interface{}, dialector dialect.Dialector) *Schema {
 		Name:     tableName,
 		FieldMap: make(map[string]*Field),
 	}
+	parsed[modelType] = schema
 
-	// 获取实例字段的个数
-	for i := 0; i < modelType.NumField(); i++ {
-		p := modelType.Field(i)
-		if !p.Anonymous && ast.IsExported(p.Name) {
-			field := &Field{
-				Name: p.Name,
-				Type: dialector.ConvertTypeTo(reflect.Indirect(reflect.New(p.Type))),
+	// 先解析所有列，再解析关联关系，推断外键时需要双方的列
+	var (
+		relations []reflect.StructField
+		// tag为 "-" 或无法展开的嵌入字段，其下的字段都跳过
+		skipped [][]int
+	)
+	tagIndexes := make(map[*Field][]fieldIndex)
+	// 嵌入结构体的字段按所在位置展开，与外层同名的字段被外层覆盖
+	for _, p := range reflect.VisibleFields(modelType) {
+		tag := p.Tag.Get("sorm")
+		if hasPrefix(p.Index, skipped) {
+			continue
+		}
+		if p.Anonymous {
+			if !embeddable(p.Type) || skipField(tag) {
+				skipped = append(skipped, p.Index)
 			}
-			// 获取tag
-			if v, ok := p.Tag.Lookup("sorm"); ok {
-				field.Tag = v
+			continue
+		}
+		if !ast.IsExported(p.Name) || skipField(tag) {
+			continue
+		}
+		serialized := parseTagSettings(tag)["SERIALIZER"] != ""
+		if !serialized && relationModel(p.Type) != nil {
+			relations = append(relations, p)
+			continue
+		}
+		field := &Field{
+			Name:  p.Name,
+			index: p.Index,
+		}
+		// 获取tag
+		if v, ok := p.Tag.Lookup("sorm"); ok {
+			field.Tag = v
+			indexes, err := parseFieldTag(field, p, v)
+			if err != nil {
+				panic(err.Error())
 			}
-			schema.Fields = append(schema.Fields, field)
-			schema.FieldNames = append(schema.FieldNames, p.Name)
-			schema.FieldMap[p.Name] = field
+			tagIndexes[field] = indexes
+		}
+		if field.Type == "" {
+			field.Type = columnType(dialector, p.Type, serialized)
+		}
+		if field.DBName == "" {
+			field.DBName = namer.ColumnName(p.Name)
+		}
+		if schema.PrimaryField == nil && field.PrimaryKey {
+			schema.PrimaryField = field
+		}
+		schema.Fields = append(schema.Fields, field)
+		schema.FieldNames = append(schema.FieldNames, p.Name)
+		schema.DBNames = append(schema.DBNames, field.DBName)
+		schema.FieldMap[p.Name] = field
+	}
+	if schema.PrimaryField == nil {
+		schema.PrimaryField = schema.FieldMap["ID"]
+	}
+	schema.parseIndexes(tagIndexes)
+
+	for _, p := range relations {
+		target := relationModel(p.Type)
+		fieldSchema, ok := parsed[target]
+		if !ok {
+			fieldSchema = parse(reflect.New(target).Interface(), dialector, namer, parsed)
+		}
+		rel, err := schema.parseRelationship(modelType, p, fieldSchema, namer)
+		if err != nil {
+			panic(err.Error())
 		}
+		schema.Relationships = append(schema.Relationships, rel)
 	}
 	return schema
 }
@@ -82,7 +199,7 @@ func (s *Schema) RecordValues(dest interface{}) []interface{} {
 	destValue := reflect.Indirect(reflect.ValueOf(dest))
 	var fieldValues []interface{}
 	for _, field := range s.Fields {
-		fieldValues = append(fieldValues, destValue.FieldByName(field.Name).Interface())
+		fieldValues = append(fieldValues, field.DBValue(destValue))
 	}
 	return fieldValues
 }
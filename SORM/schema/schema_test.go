@@ -1,8 +1,12 @@
 package schema
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
 	"sorm/dialect"
 	"testing"
+	"time"
 
 	"github.com/go-playground/assert"
 )
@@ -22,3 +26,110 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, schema.GetField("Name").Tag, "name")
 	assert.Equal(t, schema.GetField("Name").Name, "Name")
 }
+
+type Product struct {
+	ID      int    `sorm:"primary key"`
+	Code    string `sorm:"column:sku;size:32;not null;uniqueIndex"`
+	Title   string `sorm:"type:text;default:'';index:idx_title_price"`
+	Price   int    `sorm:"index:idx_title_price"`
+	Stock   int    `sorm:"default:0;check(Stock >= 0)"`
+	Ignored string `sorm:"-"`
+}
+
+func TestParseTags(t *testing.T) {
+	schema := Parse(&Product{}, sqlite3Dialector)
+	assert.Equal(t, schema.FieldNames, []string{"ID", "Code", "Title", "Price", "Stock"})
+	assert.Equal(t, schema.DBNames, []string{"ID", "sku", "Title", "Price", "Stock"})
+	assert.Equal(t, schema.PrimaryField.Name, "ID")
+	assert.Equal(t, schema.LookUpField("sku").Name, "Code")
+
+	definitions := make([]string, len(schema.Fields))
+	for i, field := range schema.Fields {
+		definitions[i] = field.Definition(sqlite3Dialector)
+	}
+	assert.Equal(t, definitions, []string{
+		`"ID" int PRIMARY KEY`,
+		`"sku" varchar(32) NOT NULL`,
+		`"Title" text DEFAULT ''`,
+		`"Price" int`,
+		`"Stock" int DEFAULT 0 check(Stock >= 0)`,
+	})
+	assert.Equal(t, len(schema.Indexes), 2)
+	assert.Equal(t, *schema.Indexes[0], Index{Name: "idx_Product_sku", Columns: []string{"sku"}, Unique: true})
+	assert.Equal(t, *schema.Indexes[1], Index{Name: "idx_title_price", Columns: []string{"Title", "Price"}})
+}
+
+func TestParseWithNamer(t *testing.T) {
+	namer := NamingStrategy{SnakeCase: true, PluralTables: true}
+	schema := ParseWithNamer(&Product{}, sqlite3Dialector, namer)
+	assert.Equal(t, schema.Name, "products")
+	assert.Equal(t, schema.DBNames, []string{"id", "sku", "title", "price", "stock"})
+	assert.Equal(t, schema.Indexes[1].Columns, []string{"title", "price"})
+}
+
+func TestNamingStrategy(t *testing.T) {
+	namer := NamingStrategy{TablePrefix: "t_", SnakeCase: true, PluralTables: true}
+	tables := map[string]string{
+		"User": "t_users", "Address": "t_addresses", "Company": "t_companies",
+		"Day": "t_days", "Box": "t_boxes", "Branch": "t_branches", "HTTPLog": "t_http_logs",
+	}
+	for model, table := range tables {
+		assert.Equal(t, namer.TableName(model), table)
+	}
+	columns := map[string]string{
+		"ID": "id", "UserID": "user_id", "HTTPServer": "http_server", "Address2": "address2", "createdAt": "created_at",
+	}
+	for field, column := range columns {
+		assert.Equal(t, namer.ColumnName(field), column)
+	}
+	assert.Equal(t, NamingStrategy{}.TableName("User"), "User")
+}
+
+type Model struct {
+	ID        int64 `sorm:"primary key"`
+	CreatedAt time.Time
+}
+
+type Point struct {
+	X, Y float64
+}
+
+// DataType 自定义列类型
+func (Point) DataType() string {
+	return "point"
+}
+
+type Member struct {
+	*Model
+	Nickname *string
+	Email    sql.NullString
+	Location Point
+	Tags     []string          `sorm:"serializer:json"`
+	Extra    map[string]string `sorm:"serializer:json;type:json"`
+	Secret   string            `sorm:"-"`
+}
+
+func TestParseFieldTypes(t *testing.T) {
+	schema := Parse(&Member{}, sqlite3Dialector)
+	assert.Equal(t, schema.FieldNames, []string{"ID", "CreatedAt", "Nickname", "Email", "Location", "Tags", "Extra"})
+	types := make([]string, len(schema.Fields))
+	for i, field := range schema.Fields {
+		types[i] = field.Type
+	}
+	assert.Equal(t, types, []string{"bigint", "datetime", "text", "text", "point", "blob", "json"})
+	assert.Equal(t, schema.PrimaryField.Name, "ID")
+	assert.Equal(t, len(schema.Relationships), 0)
+
+	// 嵌入的结构体指针为nil时读取零值，赋值时初始化
+	profile := &Member{Tags: []string{"a"}}
+	assert.Equal(t, schema.GetField("ID").ValueOf(reflect.ValueOf(profile)).Interface(), int64(0))
+	schema.GetField("ID").Settable(reflect.ValueOf(profile)).SetInt(7)
+	assert.Equal(t, profile.Model.ID, int64(7))
+
+	values := schema.RecordValues(profile)
+	tags, err := values[5].(driver.Valuer).Value()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, tags, []byte(`["a"]`))
+	extra, _ := values[6].(driver.Valuer).Value()
+	assert.Equal(t, extra, nil)
+}
@@ -0,0 +1,91 @@
+// 列的tag，格式为 sorm:"column:user_name;type:varchar(64);not null;default:0;index:idx_name"
+// serializer:json 将字段以JSON写入，可用于结构体、切片与map
+// 无法识别的部分原样拼接到列定义中，兼容 sorm:"PRIMARY KEY AUTOINCREMENT" 这类写法
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldIndex 列tag中声明的索引
+type fieldIndex struct {
+	name   string
+	unique bool
+}
+
+// relationKeys 关联关系的tag，不作为列的约束
+var relationKeys = map[string]bool{
+	"FOREIGNKEY": true, "REFERENCES": true, "MANY2MANY": true,
+	"JOINFOREIGNKEY": true, "JOINREFERENCES": true, "CASCADE": true,
+}
+
+// skipField tag为 "-" 时字段不映射为列
+func skipField(tag string) bool {
+	return strings.TrimSpace(tag) == "-"
+}
+
+// parseFieldTag 解析列的tag，写入field并返回声明的索引
+func parseFieldTag(field *Field, p reflect.StructField, tag string) ([]fieldIndex, error) {
+	var (
+		indexes     []fieldIndex
+		constraints []string
+		typed       bool
+	)
+	for _, item := range strings.Split(tag, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, ":", 2)
+		key := strings.ToUpper(strings.Join(strings.Fields(kv[0]), " "))
+		value := ""
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		switch key {
+		case "COLUMN":
+			field.DBName = value
+		case "TYPE":
+			field.Type, typed = value, true
+		case "SIZE":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("sorm: %s: invalid size %q", p.Name, value)
+			}
+			field.Size = size
+		case "DEFAULT":
+			field.Default = value
+		case "NOT NULL", "NOTNULL":
+			field.NotNull = true
+		case "SERIALIZER":
+			if field.Serializer = strings.ToLower(value); field.Serializer != "json" {
+				return nil, fmt.Errorf("sorm: %s: unsupported serializer %q", p.Name, value)
+			}
+		case "UNIQUE":
+			field.Unique = true
+		case "PRIMARY KEY", "PRIMARYKEY":
+			field.PrimaryKey = true
+		case "AUTOINCREMENT", "AUTO_INCREMENT":
+			field.AutoIncrement = true
+		case "INDEX":
+			indexes = append(indexes, fieldIndex{name: value})
+		case "UNIQUEINDEX", "UNIQUE INDEX":
+			indexes = append(indexes, fieldIndex{name: value, unique: true})
+		default:
+			if relationKeys[key] {
+				continue
+			}
+			constraints = append(constraints, item)
+		}
+	}
+	field.Constraint = strings.Join(constraints, " ")
+	if strings.Contains(strings.ToUpper(field.Constraint), "PRIMARY KEY") {
+		field.PrimaryKey = true
+	}
+	if field.Size > 0 && !typed && indirectType(p.Type).Kind() == reflect.String {
+		field.Type = fmt.Sprintf("varchar(%d)", field.Size)
+	}
+	return indexes, nil
+}
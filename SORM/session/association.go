@@ -0,0 +1,420 @@
+// Package session ...
+// 关联关系的预加载与级联保存、删除
+package session
+
+import (
+	"fmt"
+	"reflect"
+	"sorm/clause"
+	"sorm/schema"
+	"strings"
+)
+
+// Preload Find时批量加载关联字段，嵌套关联以.分隔
+// s.Preload("Orders", "Orders.Items").Find(&users)
+func (s *Session) Preload(names ...string) *Session {
+	s.preloads = append(s.preloads, names...)
+	return s
+}
+
+// preload 为owners加载path对应的关联，每层关联只执行一次IN查询
+func (s *Session) preload(owners []reflect.Value, table *schema.Schema, path string) error {
+	name, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		name, rest = path[:i], path[i+1:]
+	}
+	rel := table.GetRelationship(name)
+	if rel == nil {
+		return fmt.Errorf("sorm: %s has no association %s", table.Name, name)
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+
+	// 关联值按owner的关联键分组
+	groups := make(map[string][]reflect.Value)
+	var ownerKey string
+	var loaded []reflect.Value
+	var err error
+	switch rel.Type {
+	case schema.HasOne, schema.HasMany:
+		ownerKey = rel.References
+		if loaded, err = s.loadRelated(rel.FieldSchema, rel.ForeignKey, columnValues(owners, rel.References)); err != nil {
+			return err
+		}
+		for _, v := range loaded {
+			k := keyOf(v.FieldByName(rel.ForeignKey))
+			groups[k] = append(groups[k], v)
+		}
+	case schema.BelongsTo:
+		ownerKey = rel.ForeignKey
+		if loaded, err = s.loadRelated(rel.FieldSchema, rel.References, columnValues(owners, rel.ForeignKey)); err != nil {
+			return err
+		}
+		for _, v := range loaded {
+			k := keyOf(v.FieldByName(rel.References))
+			groups[k] = append(groups[k], v)
+		}
+	case schema.ManyToMany:
+		ownerKey = rel.References
+		pairs, targetKeys, err := s.loadJoinTable(table, rel, columnValues(owners, rel.References))
+		if err != nil {
+			return err
+		}
+		if loaded, err = s.loadRelated(rel.FieldSchema, rel.TargetKey, targetKeys); err != nil {
+			return err
+		}
+		targets := make(map[string]reflect.Value, len(loaded))
+		for _, v := range loaded {
+			targets[keyOf(v.FieldByName(rel.TargetKey))] = v
+		}
+		for k, tks := range pairs {
+			for _, tk := range tks {
+				if v, ok := targets[tk]; ok {
+					groups[k] = append(groups[k], v)
+				}
+			}
+		}
+	}
+
+	// 赋值会复制结构体，嵌套关联需要先加载
+	if rest != "" {
+		if err := s.preload(loaded, rel.FieldSchema, rest); err != nil {
+			return err
+		}
+	}
+	for _, owner := range owners {
+		assign(owner.FieldByName(rel.Name), groups[keyOf(owner.FieldByName(ownerKey))])
+	}
+	return nil
+}
+
+// loadRelated 查询column在keys中的记录，返回可寻址的结构体
+func (s *Session) loadRelated(table *schema.Schema, column string, keys []interface{}) ([]reflect.Value, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	var loaded []reflect.Value
+	for _, chunk := range chunkKeys(keys, s.dialect.MaxBindVars()) {
+		slice := reflect.New(reflect.SliceOf(modelType(table)))
+		if err := s.Model(table.Model).Where(clause.In(s.quote(table.GetField(column).DBName), chunk...)).Find(slice.Interface()); err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, records(slice.Elem())...)
+	}
+	return loaded, nil
+}
+
+// chunkKeys 将keys按dialect的参数个数上限拆分，每组生成一条IN查询
+func chunkKeys(keys []interface{}, size int) [][]interface{} {
+	var chunks [][]interface{}
+	for size > 0 && len(keys) > size {
+		chunks = append(chunks, keys[:size])
+		keys = keys[size:]
+	}
+	return append(chunks, keys)
+}
+
+// loadJoinTable 查询连接表，返回owner关联键到关联模型键的映射
+func (s *Session) loadJoinTable(table *schema.Schema, rel *schema.Relationship, keys []interface{}) (map[string][]string, []interface{}, error) {
+	pairs := make(map[string][]string)
+	if len(keys) == 0 {
+		return pairs, nil, nil
+	}
+	// 按两侧列的Go类型扫描，保证与模型中的值格式一致
+	ownerType := fieldType(table, rel.References)
+	targetType := fieldType(rel.FieldSchema, rel.TargetKey)
+	var targetKeys []interface{}
+	seen := make(map[string]bool)
+	for _, chunk := range chunkKeys(keys, s.dialect.MaxBindVars()) {
+		cond, vars := clause.In(s.quote(rel.JoinForeignKey), chunk...).Build()
+		rows, err := s.Raw(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s",
+			s.quote(rel.JoinForeignKey), s.quote(rel.JoinReferences), s.quote(rel.JoinTable), cond), vars...).Query()
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			owner, target := reflect.New(ownerType), reflect.New(targetType)
+			if err := rows.Scan(owner.Interface(), target.Interface()); err != nil {
+				_ = rows.Close()
+				return nil, nil, err
+			}
+			ok, tk := keyOf(owner.Elem()), keyOf(target.Elem())
+			pairs[ok] = append(pairs[ok], tk)
+			if !seen[tk] {
+				seen[tk] = true
+				targetKeys = append(targetKeys, target.Elem().Interface())
+			}
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return pairs, targetKeys, nil
+}
+
+// saveBelongsTo 插入value前保存其belongs-to关联，并回填外键
+func (s *Session) saveBelongsTo(value interface{}) error {
+	owner, ok := addressable(value)
+	if !ok {
+		return nil
+	}
+	for _, rel := range s.Model(value).GetRefTable().Relationships {
+		if rel.Type != schema.BelongsTo {
+			continue
+		}
+		targets := elems(owner.FieldByName(rel.Name))
+		if len(targets) == 0 {
+			continue
+		}
+		if rel.CascadeSave {
+			if _, err := s.Insert(targets[0].Addr().Interface()); err != nil {
+				return err
+			}
+		}
+		setField(owner.FieldByName(rel.ForeignKey), targets[0].FieldByName(rel.References))
+	}
+	return nil
+}
+
+// saveAssociations 插入value后保存其has-one、has-many与many-to-many关联
+func (s *Session) saveAssociations(value interface{}) error {
+	owner, ok := addressable(value)
+	if !ok {
+		return nil
+	}
+	table := s.Model(value).GetRefTable()
+	for _, rel := range table.Relationships {
+		children := elems(owner.FieldByName(rel.Name))
+		if len(children) == 0 {
+			continue
+		}
+		switch rel.Type {
+		case schema.HasOne, schema.HasMany:
+			if !rel.CascadeSave {
+				continue
+			}
+			for _, child := range children {
+				setField(child.FieldByName(rel.ForeignKey), owner.FieldByName(rel.References))
+			}
+			if _, err := s.Insert(pointers(children)...); err != nil {
+				return err
+			}
+		case schema.ManyToMany:
+			if rel.CascadeSave {
+				if err := s.saveTargets(rel.FieldSchema, children); err != nil {
+					return err
+				}
+			}
+			// 连接表中的记录即关联关系本身，总是写入
+			var rows []interface{}
+			for _, child := range children {
+				rows = append(rows, []interface{}{
+					owner.FieldByName(rel.References).Interface(),
+					child.FieldByName(rel.TargetKey).Interface(),
+				})
+			}
+			s.clause.Set(clause.INSERT, s.quote(rel.JoinTable), s.quoteAll([]string{rel.JoinForeignKey, rel.JoinReferences}))
+			s.clause.Set(clause.VALUES, rows...)
+			sql, vars := s.clause.Build(clause.INSERT, clause.VALUES)
+			if _, err := s.Raw(sql, vars...).Exec(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveTargets 保存many-to-many的关联记录，主键为零值的插入，
+// 已有主键的记录可能已存在（如多个owner共用的标签），冲突时保留已有记录
+func (s *Session) saveTargets(table *schema.Schema, targets []reflect.Value) error {
+	var created, linked []reflect.Value
+	for _, target := range targets {
+		if pk := table.PrimaryField; pk != nil && !pk.ValueOf(target).IsZero() {
+			linked = append(linked, target)
+		} else {
+			created = append(created, target)
+		}
+	}
+	if len(created) > 0 {
+		if _, err := s.Insert(pointers(created)...); err != nil {
+			return err
+		}
+	}
+	if len(linked) > 0 {
+		if _, err := s.Upsert(pointers(linked), clause.OnConflict{DoNothing: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAssociations 删除table中满足当前条件的记录前，删除tag中指定cascade:delete的关联记录
+func (s *Session) deleteAssociations(table *schema.Schema) error {
+	cascades := cascadeDeletes(table)
+	if len(cascades) == 0 {
+		return nil
+	}
+
+	// 查询与删除会清空条件，每次查询前恢复
+	where := s.where
+	restore := func() {
+		s.refTable = table
+		s.where = nil
+		if where != nil {
+			s.Where(where)
+		}
+	}
+	defer restore()
+	for _, rel := range cascades {
+		restore()
+		keys := reflect.New(reflect.SliceOf(fieldType(table, rel.References)))
+		if err := s.Pluck(rel.References, keys.Interface()); err != nil {
+			return err
+		}
+		values := interfaces(keys.Elem())
+		if len(values) == 0 {
+			continue
+		}
+		var err error
+		if rel.Type == schema.ManyToMany {
+			_, err = s.Where(clause.In(s.quote(rel.JoinForeignKey), values...)).Delete(rel.JoinTable)
+		} else {
+			_, err = s.Model(rel.FieldSchema.Model).Where(clause.In(s.quote(rel.FieldSchema.GetField(rel.ForeignKey).DBName), values...)).Delete(rel.FieldSchema.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cascadeDeletes tag中指定cascade:delete的关联关系，belongs-to不级联删除
+func cascadeDeletes(table *schema.Schema) []*schema.Relationship {
+	var cascades []*schema.Relationship
+	for _, rel := range table.Relationships {
+		if rel.CascadeDelete && rel.Type != schema.BelongsTo {
+			cascades = append(cascades, rel)
+		}
+	}
+	return cascades
+}
+
+func modelType(table *schema.Schema) reflect.Type {
+	return reflect.Indirect(reflect.ValueOf(table.Model)).Type()
+}
+
+func fieldType(table *schema.Schema, name string) reflect.Type {
+	f, _ := modelType(table).FieldByName(name)
+	return f.Type
+}
+
+// addressable 只有传入指针时才能回填外键，值类型跳过关联的保存
+func addressable(value interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return reflect.Value{}, false
+	}
+	return v.Elem(), true
+}
+
+// elems 将结构体、指针或切片字段展开为可寻址的结构体，忽略nil与零值结构体
+func elems(v reflect.Value) []reflect.Value {
+	var items []reflect.Value
+	add := func(item reflect.Value) {
+		if item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				return
+			}
+			item = item.Elem()
+		} else if item.IsZero() {
+			return
+		}
+		items = append(items, item)
+	}
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			add(v.Index(i))
+		}
+	} else {
+		add(v)
+	}
+	return items
+}
+
+// records 查询结果切片中可寻址的结构体
+func records(slice reflect.Value) []reflect.Value {
+	items := make([]reflect.Value, 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		items = append(items, reflect.Indirect(slice.Index(i)))
+	}
+	return items
+}
+
+// assign 将关联值写入字段，字段可以是T、*T、[]T、[]*T
+func assign(field reflect.Value, items []reflect.Value) {
+	switch field.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), 0, len(items))
+		for _, item := range items {
+			if field.Type().Elem().Kind() == reflect.Ptr {
+				item = item.Addr()
+			}
+			slice = reflect.Append(slice, item)
+		}
+		field.Set(slice)
+	case reflect.Ptr:
+		if len(items) > 0 {
+			field.Set(items[0].Addr())
+		}
+	default:
+		if len(items) > 0 {
+			field.Set(items[0])
+		}
+	}
+}
+
+// setField 外键与被引用列类型不同但可转换时进行转换
+func setField(dst, src reflect.Value) {
+	if !src.Type().AssignableTo(dst.Type()) {
+		src = src.Convert(dst.Type())
+	}
+	dst.Set(src)
+}
+
+// keyOf 关联键的字符串形式，用于匹配两侧的值
+func keyOf(v reflect.Value) string {
+	return fmt.Sprint(v.Interface())
+}
+
+// columnValues 去重后的列值
+func columnValues(items []reflect.Value, name string) []interface{} {
+	var values []interface{}
+	seen := make(map[string]bool)
+	for _, item := range items {
+		v := item.FieldByName(name)
+		if k := keyOf(v); !seen[k] {
+			seen[k] = true
+			values = append(values, v.Interface())
+		}
+	}
+	return values
+}
+
+func pointers(items []reflect.Value) []interface{} {
+	ptrs := make([]interface{}, len(items))
+	for i, item := range items {
+		ptrs[i] = item.Addr().Interface()
+	}
+	return ptrs
+}
+
+func interfaces(slice reflect.Value) []interface{} {
+	values := make([]interface{}, slice.Len())
+	for i := range values {
+		values[i] = slice.Index(i).Interface()
+	}
+	return values
+}
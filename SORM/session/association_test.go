@@ -0,0 +1,214 @@
+package session
+
+import (
+	"database/sql"
+	"sorm/dialect"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Author struct {
+	Name    string `sorm:"PRIMARY KEY"`
+	Profile *Bio   `sorm:"cascade:save,delete"`
+	Books   []Book `sorm:"cascade:save,delete"`
+	Tags    []*Tag `sorm:"many2many:author_tags;cascade:save,delete"`
+}
+
+type Bio struct {
+	ID         int `sorm:"PRIMARY KEY"`
+	AuthorName string
+	Text       string
+}
+
+type Book struct {
+	ID          int `sorm:"PRIMARY KEY"`
+	AuthorName  string
+	Title       string
+	PublisherID int
+	Publisher   Publisher
+}
+
+type Publisher struct {
+	ID   int `sorm:"PRIMARY KEY"`
+	Name string
+}
+
+type Tag struct {
+	ID    int `sorm:"PRIMARY KEY"`
+	Label string
+}
+
+func initAssociationTest(t *testing.T) *Session {
+	t.Helper()
+	s := NewSession()
+	_, _ = s.Raw("DROP TABLE IF EXISTS author_tags").Exec()
+	for _, model := range []interface{}{&Author{}, &Bio{}, &Book{}, &Publisher{}, &Tag{}} {
+		s.Model(model)
+		if s.HasTable() {
+			require.NoError(t, s.DropTable())
+		}
+		require.NoError(t, s.CreateTable())
+	}
+	return s
+}
+
+func TestSession_InsertAssociations(t *testing.T) {
+	s := initAssociationTest(t)
+	tag := &Tag{ID: 1, Label: "go"}
+	author := &Author{
+		Name:    "Tom",
+		Profile: &Bio{ID: 1, Text: "gopher"},
+		Books: []Book{
+			{ID: 1, Title: "A", Publisher: Publisher{ID: 7, Name: "P"}},
+			{ID: 2, Title: "B"},
+		},
+		Tags: []*Tag{tag, {ID: 2, Label: "orm"}},
+	}
+	affected, err := s.Insert(author)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+	// 外键被回填
+	assert.Equal(t, "Tom", author.Profile.AuthorName)
+	assert.Equal(t, "Tom", author.Books[0].AuthorName)
+	assert.Equal(t, 7, author.Books[0].PublisherID)
+
+	count, err := s.Model(&Book{}).Where("AuthorName = ?", "Tom").Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// 已存在的标签只写入连接表
+	_, err = s.Insert(&Author{Name: "Sam", Tags: []*Tag{{ID: 1, Label: "go"}}})
+	require.NoError(t, err)
+	count, err = s.Model(&Tag{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	var authors []Author
+	require.NoError(t, s.Preload("Profile", "Books.Publisher", "Tags").OrderBy("Name").Find(&authors))
+	require.Equal(t, 2, len(authors))
+	sam, tom := authors[0], authors[1]
+	assert.Nil(t, sam.Profile)
+	assert.Equal(t, 0, len(sam.Books))
+	require.Equal(t, 1, len(sam.Tags))
+	assert.Equal(t, "go", sam.Tags[0].Label)
+
+	assert.Equal(t, "gopher", tom.Profile.Text)
+	require.Equal(t, 2, len(tom.Books))
+	assert.Equal(t, "P", tom.Books[0].Publisher.Name)
+	assert.Equal(t, 0, tom.Books[1].Publisher.ID)
+	assert.Equal(t, 2, len(tom.Tags))
+
+	var book Book
+	require.NoError(t, s.Preload("Publisher").Where("ID = ?", 1).First(&book))
+	assert.Equal(t, "P", book.Publisher.Name)
+
+	err = s.Model(&Author{}).Preload("Unknown").Find(&authors)
+	assert.EqualError(t, err, "sorm: Author has no association Unknown")
+}
+
+// smallBinds 与sqlite连接的参数个数上限一致，使预加载的IN查询被拆分
+type smallBinds struct {
+	dialect.Dialector
+}
+
+func (smallBinds) MaxBindVars() int {
+	return 2
+}
+
+func init() {
+	sql.Register("sqlite3_small_binds", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			conn.SetLimit(sqlite3.SQLITE_LIMIT_VARIABLE_NUMBER, 2)
+			return nil
+		},
+	})
+}
+
+func TestSession_PreloadChunks(t *testing.T) {
+	s := initAssociationTest(t)
+	for _, name := range []string{"Amy", "Bob", "Cid"} {
+		_, err := s.Insert(&Author{
+			Name:  name,
+			Books: []Book{{ID: len(name) * int(name[0]), Title: name}},
+			Tags:  []*Tag{{ID: int(name[0]), Label: name}, {ID: 1, Label: "go"}},
+		})
+		require.NoError(t, err)
+	}
+
+	db, err := sql.Open("sqlite3_small_binds", "./sorm.db")
+	require.NoError(t, err)
+	defer db.Close()
+	var authors []Author
+	require.NoError(t, New(db, smallBinds{sqlite3Dialector}).
+		Preload("Books", "Tags").OrderBy("Name").Find(&authors))
+	require.Equal(t, 3, len(authors))
+	for _, author := range authors {
+		require.Equal(t, 1, len(author.Books))
+		assert.Equal(t, author.Name, author.Books[0].Title)
+		assert.Equal(t, 2, len(author.Tags))
+	}
+}
+
+func TestSession_InsertAssociationsRollback(t *testing.T) {
+	s := initAssociationTest(t)
+	_, err := s.Insert(&Book{ID: 1})
+	require.NoError(t, err)
+
+	// 关联记录插入失败时owner也不写入
+	_, err = s.Insert(&Author{Name: "Tom", Books: []Book{{ID: 2}, {ID: 1}}})
+	assert.Error(t, err)
+	count, err := s.Model(&Author{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	count, err = s.Model(&Book{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestSession_DeleteAssociations(t *testing.T) {
+	s := initAssociationTest(t)
+	_, err := s.Insert(
+		&Author{Name: "Tom", Profile: &Bio{ID: 1}, Books: []Book{{ID: 1}, {ID: 2}}, Tags: []*Tag{{ID: 1}}},
+		&Author{Name: "Sam", Books: []Book{{ID: 3}}},
+	)
+	require.NoError(t, err)
+
+	affected, err := s.Model(&Author{}).Where("Name = ?", "Tom").Delete("Author")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	var ids []int
+	require.NoError(t, s.Model(&Book{}).Pluck("ID", &ids))
+	assert.Equal(t, []int{3}, ids)
+	count, err := s.Model(&Bio{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	var joined int
+	require.NoError(t, s.Raw("SELECT COUNT(*) FROM author_tags").QueryRow().Scan(&joined))
+	assert.Equal(t, 0, joined)
+	// many-to-many只删除连接表中的记录
+	count, err = s.Model(&Tag{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestSession_DeleteAssociationsRollback(t *testing.T) {
+	s := initAssociationTest(t)
+	_, err := s.Insert(&Author{Name: "Tom", Books: []Book{{ID: 1}, {ID: 2}}, Tags: []*Tag{{ID: 1}}})
+	require.NoError(t, err)
+	_, err = s.Raw("CREATE TRIGGER author_locked BEFORE DELETE ON Author BEGIN SELECT RAISE(ABORT, 'locked'); END").Exec()
+	require.NoError(t, err)
+
+	// 删除记录失败时级联删除的关联记录一并回滚
+	_, err = s.Model(&Author{}).Where("Name = ?", "Tom").Delete("Author")
+	assert.Error(t, err)
+	count, err := s.Model(&Book{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	var joined int
+	require.NoError(t, s.Raw("SELECT COUNT(*) FROM author_tags").QueryRow().Scan(&joined))
+	assert.Equal(t, 1, joined)
+}
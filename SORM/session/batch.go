@@ -0,0 +1,115 @@
+// Package session ...
+// 分批写入与流式读取，处理大量数据时内存占用有上限
+package session
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// CreateInBatches 每次插入size条记录，values为模型切片或切片指针
+// 单条语句的参数个数不超过dialect的上限，各批之间不在同一事务中，需要时由调用方开启事务
+// 每批使用多行语句插入，数据库生成的自增主键不写回values，需要主键时使用Insert
+func (s *Session) CreateInBatches(values interface{}, size int) (int64, error) {
+	if size <= 0 {
+		return 0, fmt.Errorf("sorm: invalid batch size %d", size)
+	}
+	items := models(values)
+	var affected int64
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		n, err := s.create(items[start:end], true)
+		if err != nil {
+			return affected, err
+		}
+		affected += n
+	}
+	return affected, nil
+}
+
+// Rows 按当前的条件查询refTable，返回的rows由调用方关闭，可使用ScanRow逐行读取
+// rows, err := s.Model(&User{}).Where("Age > ?", 18).Rows()
+// for rows.Next() { var u User; s.ScanRow(rows, &u) }
+func (s *Session) Rows() (*sql.Rows, error) {
+	if s.refTable == nil {
+		s.Clear()
+		return nil, ErrModelRequired
+	}
+	fields := s.selects
+	if len(fields) == 0 {
+		fields = s.quoteAll(s.refTable.DBNames)
+	}
+	sql, vars := s.selectSQL(fields)
+	return s.Raw(sql, vars...).Query()
+}
+
+// ScanRow 将rows的当前行写入dest，dest为结构体指针或*map[string]interface{}，列与字段的对应规则与Find相同
+func (s *Session) ScanRow(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || (v.Elem().Kind() != reflect.Struct && v.Elem().Type() != mapType) {
+		return fmt.Errorf("sorm: unsupported destination %T", dest)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	return s.scanRow(rows, columns, v.Elem())
+}
+
+// FindInBatches 逐行读取查询结果，每读取size条写入dest并调用fn，batch从1开始，fn返回错误时停止
+// dest为切片指针，每批开始前清空并复用底层数组；fn执行时结果集仍未关闭，不要在同一事务中执行其他语句
+func (s *Session) FindInBatches(dest interface{}, size int, fn func(batch int) error) error {
+	if size <= 0 {
+		return fmt.Errorf("sorm: invalid batch size %d", size)
+	}
+	destSlice := reflect.Indirect(reflect.ValueOf(dest))
+	destType := destSlice.Type().Elem()
+	if destType.Kind() != reflect.Struct && destType != mapType {
+		return fmt.Errorf("sorm: unsupported destination %v", destType)
+	}
+	s.CallMethod(BeforeQuery, nil)
+	if destType.Kind() == reflect.Struct && (len(s.selects) == 0 || s.refTable == nil) {
+		s.Model(reflect.New(destType).Elem().Interface())
+	}
+	rows, err := s.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	batch := 0
+	flush := func() error {
+		batch++
+		err := fn(batch)
+		destSlice.Set(destSlice.Slice(0, 0))
+		return err
+	}
+	destSlice.Set(destSlice.Slice(0, 0))
+	for rows.Next() {
+		elem := reflect.New(destType).Elem()
+		if err := s.scanRow(rows, columns, elem); err != nil {
+			return err
+		}
+		destSlice.Set(reflect.Append(destSlice, elem))
+		if destSlice.Len() == size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if destSlice.Len() > 0 {
+		return flush()
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package session
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Metric struct {
+	ID    int64 `sorm:"primary key;autoIncrement"`
+	Name  string
+	Value int
+}
+
+func initBatchTest(t *testing.T, n int) *Session {
+	t.Helper()
+	s := NewSession().Model(&Metric{})
+	_ = s.DropTable()
+	require.NoError(t, s.CreateTable())
+	t.Cleanup(func() { _ = NewSession().Model(&Metric{}).DropTable() })
+	metrics := make([]Metric, n)
+	for i := range metrics {
+		metrics[i] = Metric{Name: "m", Value: i}
+	}
+	affected, err := s.CreateInBatches(metrics, 5000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(n), affected)
+	// 多行语句不写回生成的主键
+	assert.Equal(t, int64(0), metrics[n-1].ID)
+	return s
+}
+
+func TestSession_CreateInBatches(t *testing.T) {
+	s := initBatchTest(t, 12000)
+	count, err := s.Model(&Metric{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(12000), count)
+
+	// 一批的参数个数超过sqlite的上限时自动拆分
+	metrics := make([]Metric, 20000)
+	for i := range metrics {
+		metrics[i] = Metric{Name: "n", Value: i}
+	}
+	affected, err := s.CreateInBatches(metrics, len(metrics))
+	require.NoError(t, err)
+	assert.Equal(t, int64(20000), affected)
+	var last Metric
+	require.NoError(t, s.OrderBy("ID DESC").Limit(1).First(&last))
+	assert.Equal(t, Metric{ID: 32000, Name: "n", Value: 19999}, last)
+
+	// Insert逐条插入需要生成主键的记录并写回
+	m := &Metric{Name: "o"}
+	_, err = s.Insert(m, &Metric{Name: "o"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(32001), m.ID)
+
+	_, err = s.CreateInBatches([]Metric{{}}, 0)
+	assert.Error(t, err)
+}
+
+func TestSession_FindInBatches(t *testing.T) {
+	s := initBatchTest(t, 25)
+	var (
+		metrics []Metric
+		sizes   []int
+		sum     int
+	)
+	err := s.Where("Value >= ?", 3).OrderBy("ID").FindInBatches(&metrics, 10, func(batch int) error {
+		assert.Equal(t, len(sizes)+1, batch)
+		sizes = append(sizes, len(metrics))
+		for _, m := range metrics {
+			sum += m.Value
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{10, 10, 2}, sizes)
+	assert.Equal(t, 297, sum)
+
+	// fn返回错误时停止
+	stop := errors.New("stop")
+	calls := 0
+	err = s.FindInBatches(&metrics, 10, func(batch int) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestSession_RowsScanRow(t *testing.T) {
+	s := initBatchTest(t, 5)
+	rows, err := s.Model(&Metric{}).Where("Value < ?", 2).OrderBy("ID").Rows()
+	require.NoError(t, err)
+	var metrics []Metric
+	for rows.Next() {
+		var m Metric
+		require.NoError(t, s.ScanRow(rows, &m))
+		metrics = append(metrics, m)
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []Metric{{ID: 1, Name: "m", Value: 0}, {ID: 2, Name: "m", Value: 1}}, metrics)
+
+	rows, err = s.Model(&Metric{}).Select("Name", "COUNT(*) AS Total").GroupBy("Name").Rows()
+	require.NoError(t, err)
+	defer rows.Close()
+	require.True(t, rows.Next())
+	var row map[string]interface{}
+	require.NoError(t, s.ScanRow(rows, &row))
+	assert.Equal(t, int64(5), row["Total"])
+
+	// 失败时也清空条件，不影响同一session的下一次查询
+	s = NewSession()
+	_, err = s.Where("Value < ?", 2).Rows()
+	assert.Equal(t, ErrModelRequired, err)
+	var names []string
+	assert.Equal(t, ErrModelRequired, s.Where("Value < ?", 2).Pluck("Name", &names))
+	var max int
+	assert.Equal(t, ErrModelRequired, s.Where("Value < ?", 2).Max("Value", &max))
+	require.NoError(t, s.Model(&Metric{}).Pluck("Name", &names))
+	assert.Equal(t, 5, len(names))
+}
+
+// Seq 只有自增主键，插入时没有可写入的列
+type Seq struct {
+	ID int64 `sorm:"primary key;autoIncrement"`
+}
+
+func TestSession_InsertDefaultValues(t *testing.T) {
+	s := NewSession().Model(&Seq{})
+	_ = s.DropTable()
+	require.NoError(t, s.CreateTable())
+	t.Cleanup(func() { _ = NewSession().Model(&Seq{}).DropTable() })
+
+	seq := &Seq{}
+	_, err := s.Insert(seq)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), seq.ID)
+	affected, err := s.CreateInBatches(make([]Seq, 3), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	count, err := s.Model(&Seq{}).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	for name, want := range map[string]string{
+		"mysql":    "INSERT INTO `Seq` () VALUES ()",
+		"postgres": `INSERT INTO "seq" DEFAULT VALUES RETURNING "id"`,
+	} {
+		d := dryRun(t, name)
+		_, err := d.Insert(&Seq{})
+		require.NoError(t, err)
+		assert.Equal(t, want, d.Statements()[0].SQL, name)
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sorm/clause"
+	"sorm/dialect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type Post struct {
+	ID    int64 `sorm:"primary key;autoIncrement"`
+	Title string
+	Views int
+}
+
+// dryRun 不连接数据库，只生成SQL
+func dryRun(t *testing.T, name string) *Session {
+	t.Helper()
+	d, ok := dialect.GetDialect(name)
+	require.True(t, ok)
+	return New(nil, d).DryRun()
+}
+
+// assertGolden 与testdata/<name>.golden比较，-update时重新生成
+func assertGolden(t *testing.T, name string, statements []Statement) {
+	t.Helper()
+	var b strings.Builder
+	for _, st := range statements {
+		fmt.Fprintf(&b, "%s %v\n", st.SQL, st.Vars)
+	}
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, ioutil.WriteFile(path, []byte(b.String()), 0644))
+	}
+	want, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), b.String())
+}
+
+func TestDialectGolden(t *testing.T) {
+	for _, name := range []string{"sqlite3", "mysql", "postgres"} {
+		t.Run(name, func(t *testing.T) {
+			s := dryRun(t, name)
+			runGolden(t, s)
+			assertGolden(t, name, s.Statements())
+		})
+	}
+}
+
+// runGolden 生成golden文件的语句，dry run时只记录SQL，连接数据库时实际执行
+func runGolden(t *testing.T, s *Session) {
+	t.Helper()
+	check := func(err error) {
+		t.Helper()
+		if !s.dryRun || err != ErrDryRun {
+			require.NoError(t, err)
+		}
+	}
+	check(s.Model(&User{}).CreateTable())
+	check(s.Model(&Account{}).CreateTable())
+	_, err := s.Insert(&User{"Tom", 18}, &User{"Sam", 25})
+	check(err)
+	_, err = s.Model(&User{}).Where("Name = ?", "Tom").Update(map[string]interface{}{"Name": "Tom", "Age": 30})
+	check(err)
+	var users []User
+	check(s.Where("Age > ? AND Name <> '?'", 10).OrderBy("Age").Limit(5).Find(&users))
+	var rows []map[string]interface{}
+	check(s.Model(&User{}).Distinct("User.Name", "COUNT(*) AS Total").
+		LeftJoin("Account", "Account.UserName = Name AND Account.Balance > ?", 0).
+		Where("Age > ?", 10).GroupBy("User.Name").Having("COUNT(*) > ?", 1).Offset(10).Find(&rows))
+	var sum int
+	check(s.Model(&User{}).Where("Age > ?", 10).Sum("Age", &sum))
+	_, err = s.Where("Name = ?", "Sam").Delete("User")
+	check(err)
+
+	// RETURNING只在支持的dialect中使用
+	if s.dialect.SupportsReturning() {
+		s.clause.Set(clause.INSERT, s.quote("User"), s.quoteAll([]string{"Name"}))
+		s.clause.Set(clause.VALUES, []interface{}{"Amy"})
+		s.clause.Set(clause.RETURNING, s.quoteAll([]string{"Name", "Age"}))
+		sql, vars := s.clause.Build(clause.INSERT, clause.VALUES, clause.RETURNING)
+		_, err = s.Raw(sql, vars...).Exec()
+		check(err)
+	}
+	check(s.Model(&User{}).DropTable())
+	check(s.Model(&Account{}).DropTable())
+
+	// 自增主键与按主键更新、删除
+	check(s.Model(&Post{}).CreateTable())
+	_, err = s.Insert(&Post{Title: "a"}, &Post{Title: "b"}, &Post{ID: 9, Title: "c"})
+	check(err)
+	_, err = s.Updates(&Post{ID: 9, Title: "d"})
+	check(err)
+	_, err = s.Delete(&Post{ID: 9})
+	check(err)
+	_, err = s.Upsert([]Post{{ID: 1, Title: "a"}, {ID: 2, Title: "b"}}, clause.OnConflict{})
+	check(err)
+	_, err = s.Upsert(&Post{Title: "c", Views: 1}, clause.OnConflict{Columns: []string{"ID"}, DoUpdate: []string{"Views"}})
+	check(err)
+	_, err = s.Upsert(&Post{ID: 3, Title: "d"}, clause.OnConflict{DoNothing: true})
+	check(err)
+	check(s.Model(&Post{}).DropTable())
+}
@@ -10,6 +10,10 @@ func TestMain(m *testing.M) {
 	TestDB, _ = sql.Open("sqlite3", "./sorm.db")
 	TestDB.Exec("DROP TABLE IF EXISTS User;")
 	TestDB.Exec("DROP TABLE IF EXISTS Person;")
+	TestDB.Exec("DROP TABLE IF EXISTS Account;")
+	for _, table := range []string{"Author", "Bio", "Book", "Publisher", "Tag", "author_tags"} {
+		TestDB.Exec("DROP TABLE IF EXISTS " + table)
+	}
 	code := m.Run()
 	TestDB.Exec("DROP TABLE IF EXISTS User;")
 	_ = TestDB.Close()
@@ -0,0 +1,247 @@
+// Package session ...
+// 列选择、连接、分组与聚合查询
+package session
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sorm/clause"
+	"sorm/schema"
+	"strings"
+)
+
+// ErrModelRequired 查询结果不是模型结构体时需要先调用Model指定表
+var ErrModelRequired = errors.New("sorm: model required, call Model first")
+
+// Select 指定查询的列，多次调用时追加，可以是表达式
+// s.Model(&User{}).Select("Age", "COUNT(*) AS Total").GroupBy("Age").Find(&results)
+func (s *Session) Select(columns ...string) *Session {
+	for _, column := range columns {
+		s.selects = append(s.selects, s.quoteColumn(column))
+	}
+	return s
+}
+
+// Distinct SELECT DISTINCT，可同时指定查询的列
+func (s *Session) Distinct(columns ...string) *Session {
+	s.distinct = true
+	return s.Select(columns...)
+}
+
+// Join INNER JOIN table ON on
+// s.Join("Account", "Account.UserName = User.Name AND Account.Balance > ?", 10)
+func (s *Session) Join(table string, on string, values ...interface{}) *Session {
+	return s.join("INNER JOIN", table, on, values)
+}
+
+// LeftJoin LEFT JOIN table ON on
+func (s *Session) LeftJoin(table string, on string, values ...interface{}) *Session {
+	return s.join("LEFT JOIN", table, on, values)
+}
+
+func (s *Session) join(kind, table, on string, values []interface{}) *Session {
+	s.joins = append(s.joins, clause.Raw(fmt.Sprintf("%s %s ON %s", kind, s.quoteColumn(table), on), values...))
+	s.clause.Set(clause.JOIN, s.joins...)
+	return s
+}
+
+// GroupBy chain链式调用
+func (s *Session) GroupBy(columns ...string) *Session {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = s.quoteColumn(column)
+	}
+	s.clause.Set(clause.GROUPBY, quoted)
+	return s
+}
+
+// Having 分组后的过滤条件，参数与Where相同，多次调用时以AND连接
+func (s *Session) Having(query interface{}, values ...interface{}) *Session {
+	s.having = clause.And(s.having, s.toExpr(query, values))
+	sql, vars := s.having.Build()
+	s.clause.Set(clause.HAVING, append([]interface{}{sql}, vars...)...)
+	return s
+}
+
+// Offset chain链式调用
+func (s *Session) Offset(offset int) *Session {
+	s.clause.Set(clause.OFFSET, offset)
+	return s
+}
+
+// Pluck 查询单列的值，dest为切片指针
+// var names []string
+// s.Model(&User{}).Where("Age > ?", 18).Pluck("Name", &names)
+func (s *Session) Pluck(column string, dest interface{}) error {
+	destSlice := reflect.Indirect(reflect.ValueOf(dest))
+	if s.refTable == nil {
+		// 与执行语句后相同，清空已设置的条件，避免带入下一次调用
+		s.Clear()
+		return ErrModelRequired
+	}
+	sql, vars := s.selectSQL([]string{s.quoteColumn(column)})
+	rows, err := s.Raw(sql, vars...).Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		v := reflect.New(destSlice.Type().Elem())
+		if err := rows.Scan(v.Interface()); err != nil {
+			return err
+		}
+		destSlice.Set(reflect.Append(destSlice, v.Elem()))
+	}
+	return rows.Err()
+}
+
+// Sum SUM(column)，没有记录时为0
+func (s *Session) Sum(column string, dest interface{}) error {
+	return s.aggregate(fmt.Sprintf("COALESCE(SUM(%s), 0)", s.quoteColumn(column)), dest)
+}
+
+// Avg AVG(column)，没有记录时为NULL，dest可使用sql.NullFloat64
+func (s *Session) Avg(column string, dest interface{}) error {
+	return s.aggregate(fmt.Sprintf("AVG(%s)", s.quoteColumn(column)), dest)
+}
+
+// Max MAX(column)
+func (s *Session) Max(column string, dest interface{}) error {
+	return s.aggregate(fmt.Sprintf("MAX(%s)", s.quoteColumn(column)), dest)
+}
+
+// Min MIN(column)
+func (s *Session) Min(column string, dest interface{}) error {
+	return s.aggregate(fmt.Sprintf("MIN(%s)", s.quoteColumn(column)), dest)
+}
+
+// aggregate 查询单个聚合值，按列分组统计请使用Select、GroupBy与Find
+func (s *Session) aggregate(expr string, dest interface{}) error {
+	if s.refTable == nil {
+		s.Clear()
+		return ErrModelRequired
+	}
+	sql, vars := s.selectSQL([]string{expr})
+	rows, err := s.Raw(sql, vars...).Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return errors.New("Record Not Found")
+	}
+	return rows.Scan(dest)
+}
+
+// selectSQL 按当前的连接、条件、分组、排序与分页生成SELECT语句
+func (s *Session) selectSQL(fields []string) (string, []interface{}) {
+	s.clause.Set(clause.SELECT, s.quote(s.refTable.Name), fields, s.distinct)
+	if s.clause.Has(clause.OFFSET) && !s.clause.Has(clause.LIMIT) {
+		// sqlite与mysql不支持单独使用OFFSET
+		s.clause.Set(clause.LIMIT, int64(math.MaxInt64))
+	}
+	return s.clause.Build(clause.SELECT, clause.JOIN, clause.WHERE, clause.GROUPBY, clause.HAVING,
+		clause.ORDERBY, clause.LIMIT, clause.OFFSET)
+}
+
+var mapType = reflect.TypeOf(map[string]interface{}{})
+
+// scanField 列对应的结构体字段，dest为refTable的模型时按列名查找，否则按命名策略匹配字段名
+// 返回的field只在按refTable查找时不为nil
+func (s *Session) scanField(dest reflect.Value, column string) (reflect.Value, *schema.Field) {
+	if s.refTable != nil && modelType(s.refTable) == dest.Type() {
+		if field := s.refTable.LookUpField(column); field != nil {
+			return field.Settable(dest), field
+		}
+	}
+	namer := s.namer
+	if namer == nil {
+		namer = schema.DefaultNamer
+	}
+	// postgres会将未加引号的别名转为小写
+	return dest.FieldByNameFunc(func(name string) bool {
+		return strings.EqualFold(name, column) || strings.EqualFold(namer.ColumnName(name), column)
+	}), nil
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// scanTarget 扫描到字段f的目标，apply在Scan之后调用
+// 指针与实现了sql.Scanner的字段直接扫描；其他字段先扫描到指针，NULL时置为零值
+func scanTarget(f reflect.Value, field *schema.Field) (target interface{}, apply func()) {
+	if field != nil && field.Serializer == "json" {
+		return schema.JSONScanner{Dest: f}, nil
+	}
+	if f.Kind() == reflect.Ptr || reflect.PtrTo(f.Type()).Implements(scannerType) {
+		return f.Addr().Interface(), nil
+	}
+	holder := reflect.New(reflect.PtrTo(f.Type()))
+	return holder.Interface(), func() {
+		if holder.Elem().IsNil() {
+			f.Set(reflect.Zero(f.Type()))
+		} else {
+			f.Set(holder.Elem().Elem())
+		}
+	}
+}
+
+// scanRows 将查询结果按列名写入destSlice，元素可以是结构体或map[string]interface{}
+// 结构体中没有对应字段的列被忽略，列为NULL时字段为零值
+func (s *Session) scanRows(rows *sql.Rows, destSlice reflect.Value) error {
+	defer rows.Close()
+	destType := destSlice.Type().Elem()
+	if destType.Kind() != reflect.Struct && destType != mapType {
+		return fmt.Errorf("sorm: unsupported destination %v", destType)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		dest := reflect.New(destType).Elem()
+		if err := s.scanRow(rows, columns, dest); err != nil {
+			return err
+		}
+		destSlice.Set(reflect.Append(destSlice, dest))
+	}
+	return rows.Err()
+}
+
+// scanRow 将当前行写入dest，dest为可赋值的结构体或map[string]interface{}
+func (s *Session) scanRow(rows *sql.Rows, columns []string, dest reflect.Value) error {
+	values := make([]interface{}, len(columns))
+	var applies []func()
+	for i, column := range columns {
+		values[i] = new(interface{})
+		if dest.Kind() == reflect.Struct {
+			if f, field := s.scanField(dest, column); f.IsValid() && f.CanSet() {
+				var apply func()
+				if values[i], apply = scanTarget(f, field); apply != nil {
+					applies = append(applies, apply)
+				}
+			}
+		}
+	}
+	if err := rows.Scan(values...); err != nil {
+		return err
+	}
+	for _, apply := range applies {
+		apply()
+	}
+	if dest.Type() == mapType {
+		m := reflect.MakeMapWithSize(mapType, len(columns))
+		for i, column := range columns {
+			m.SetMapIndex(reflect.ValueOf(column), reflect.ValueOf(values[i]).Elem())
+		}
+		dest.Set(m)
+	} else {
+		s.CallMethod(AfterQuery, dest.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/go-playground/assert"
+)
+
+type Account struct {
+	UserName string
+	Balance  int
+}
+
+func initQueryTest(t *testing.T) *Session {
+	t.Helper()
+	s := initTest(t)
+	_, _ = s.Insert(&User{"Sam", 14}, &User{"Amy", 20})
+	s.Model(&Account{})
+	if s.HasTable() {
+		_ = s.DropTable()
+	}
+	if err := s.CreateTable(); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	_, _ = s.Insert(&Account{"Tom", 100}, &Account{"Tom", 50}, &Account{"Sam", 30})
+	return s.Model(&User{})
+}
+
+func TestSession_SelectDistinct(t *testing.T) {
+	s := initQueryTest(t)
+	var users []User
+	if err := s.Select("Name").OrderBy("Name").Offset(1).Limit(2).Find(&users); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "John", users[0].Name)
+	assert.Equal(t, 0, users[0].Age)
+
+	// 单独使用Offset
+	users = nil
+	if err := s.OrderBy("Name").Offset(3).Find(&users); err != nil {
+		t.Fatalf("Offset failed: %v", err)
+	}
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "Tom", users[0].Name)
+
+	var ages []int
+	if err := s.Distinct().OrderBy("Age").Pluck("Age", &ages); err != nil {
+		t.Fatalf("Pluck failed: %v", err)
+	}
+	assert.Equal(t, []int{12, 14, 20}, ages)
+}
+
+func TestSession_GroupByHaving(t *testing.T) {
+	s := initQueryTest(t)
+	type result struct {
+		Age   int
+		Total int
+	}
+	var results []result
+	err := s.Select("Age", "COUNT(*) AS Total").GroupBy("Age").Having("COUNT(*) > ?", 1).Find(&results)
+	if err != nil {
+		t.Fatalf("GroupBy failed: %v", err)
+	}
+	assert.Equal(t, []result{{Age: 14, Total: 2}}, results)
+
+	var rows []map[string]interface{}
+	err = s.Select("Age", "COUNT(*) AS Total").GroupBy("Age").OrderBy("Age").Find(&rows)
+	if err != nil {
+		t.Fatalf("Find into map failed: %v", err)
+	}
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, int64(12), rows[0]["Age"])
+	assert.Equal(t, int64(1), rows[0]["Total"])
+}
+
+func TestSession_Join(t *testing.T) {
+	s := initQueryTest(t)
+	type balance struct {
+		Name    string
+		Balance int
+	}
+	var balances []balance
+	err := s.Select("User.Name", "SUM(Account.Balance) AS Balance").
+		Join("Account", "Account.UserName = User.Name").
+		GroupBy("User.Name").OrderBy("User.Name").Find(&balances)
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	assert.Equal(t, []balance{{"Sam", 30}, {"Tom", 150}}, balances)
+
+	// LEFT JOIN保留没有账户的用户
+	count, err := s.LeftJoin("Account", "Account.UserName = User.Name").Where("Account.UserName IS NULL").Count()
+	if err != nil {
+		t.Fatalf("LeftJoin failed: %v", err)
+	}
+	assert.Equal(t, 2, int(count))
+}
+
+func TestSession_Aggregate(t *testing.T) {
+	s := initQueryTest(t)
+	var sum, max, min int
+	var avg float64
+	if err := s.Sum("Age", &sum); err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	assert.Equal(t, 60, sum)
+	_ = s.Avg("Age", &avg)
+	assert.Equal(t, 15.0, avg)
+	_ = s.Where("Age < ?", 20).Max("Age", &max)
+	assert.Equal(t, 14, max)
+	_ = s.Min("Age", &min)
+	assert.Equal(t, 12, min)
+
+	// 没有记录时Sum为0，Avg为NULL
+	_ = s.Where("Age > ?", 100).Sum("Age", &sum)
+	assert.Equal(t, 0, sum)
+	var none sql.NullFloat64
+	_ = s.Where("Age > ?", 100).Avg("Age", &none)
+	assert.Equal(t, false, none.Valid)
+}
@@ -4,6 +4,8 @@ package session
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"sorm/clause"
 	"sorm/dialect"
 	"sorm/logger"
@@ -22,8 +24,30 @@ type Session struct {
 	sql      strings.Builder
 	// sql占位符对应的值
 	sqlVars []interface{}
+	// Where与OrWhere累积的条件
+	where clause.Expr
+	// Select、Join与Having累积的查询状态
+	selects  []string
+	distinct bool
+	joins    []interface{}
+	having   clause.Expr
+	preloads []string
+	// 表名与列名的命名策略，为nil时使用schema.DefaultNamer
+	namer schema.Namer
+	// 只生成SQL而不执行
+	dryRun     bool
+	statements []Statement
 }
 
+// Statement 生成的SQL语句与参数，占位符已按dialect转换
+type Statement struct {
+	SQL  string
+	Vars []interface{}
+}
+
+// ErrDryRun DryRun模式下查询语句不会执行
+var ErrDryRun = errors.New("sorm: dry run, query not executed")
+
 // CommonDB commonDB interface
 type CommonDB interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
@@ -59,11 +83,48 @@ func (s *Session) Raw(sql string, vars ...interface{}) *Session {
 	return s
 }
 
+// DryRun 开启后Exec、Query与QueryRow只记录SQL而不访问数据库
+// Exec返回影响0行，Query与QueryRow().Scan返回ErrDryRun
+func (s *Session) DryRun() *Session {
+	s.dryRun = true
+	return s
+}
+
+// Statements 返回DryRun模式下记录的SQL
+func (s *Session) Statements() []Statement {
+	return s.statements
+}
+
+// statement 当前待执行的SQL，占位符已按dialect转换
+// 没有参数时 ? 不是占位符（如postgres jsonb的 ?、?|、?& 运算符），原样执行
+func (s *Session) statement() string {
+	sql := strings.TrimSpace(s.sql.String())
+	if len(s.sqlVars) == 0 {
+		return sql
+	}
+	return dialect.Rebind(s.dialect, sql)
+}
+
+// record DryRun模式下记录SQL，返回true表示不需要执行
+func (s *Session) record(sql string) bool {
+	if !s.dryRun {
+		return false
+	}
+	s.statements = append(s.statements, Statement{SQL: sql, Vars: s.sqlVars})
+	return true
+}
+
 // Clear clear sql and vars
 func (s *Session) Clear() {
 	s.sql.Reset()
 	s.sqlVars = nil
 	s.clause = clause.Clause{}
+	s.where = nil
+	s.selects = nil
+	s.distinct = false
+	s.joins = nil
+	s.having = nil
+	s.preloads = nil
 }
 
 // Exec execute sql statement
@@ -71,8 +132,12 @@ func (s *Session) Exec() (result sql.Result, err error) {
 	// 执行完方法清空sql和vars
 	// 复用session，一次会话可执行多个sql
 	defer s.Clear()
-	logger.Info(s.sql.String(), s.sqlVars)
-	result, err = s.DB().Exec(s.sql.String(), s.sqlVars...)
+	sql := s.statement()
+	logger.Info(sql, s.sqlVars)
+	if s.record(sql) {
+		return driver.RowsAffected(0), nil
+	}
+	result, err = s.DB().Exec(sql, s.sqlVars...)
 	if err != nil {
 		logger.Error(err)
 		return
@@ -80,19 +145,48 @@ func (s *Session) Exec() (result sql.Result, err error) {
 	return
 }
 
+// Row QueryRow的结果，与sql.Row用法相同
+type Row struct {
+	row *sql.Row
+	err error
+}
+
+// Scan 将结果写入dest，没有结果时返回sql.ErrNoRows
+func (r *Row) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.row.Scan(dest...)
+}
+
+// Err 返回执行查询时的错误
+func (r *Row) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.row.Err()
+}
+
 // QueryRow ...
-func (s *Session) QueryRow() *sql.Row {
+func (s *Session) QueryRow() *Row {
 	defer s.Clear()
-	logger.Info(s.sql.String(), s.sqlVars)
-	res := s.DB().QueryRow(s.sql.String(), s.sqlVars...)
-	return res
+	sql := s.statement()
+	logger.Info(sql, s.sqlVars)
+	if s.record(sql) {
+		return &Row{err: ErrDryRun}
+	}
+	return &Row{row: s.DB().QueryRow(sql, s.sqlVars...)}
 }
 
 // Query ...
 func (s *Session) Query() (rows *sql.Rows, err error) {
 	defer s.Clear()
-	logger.Info(s.sql.String(), s.sqlVars)
-	if rows, err = s.DB().Query(s.sql.String(), s.sqlVars...); err != nil {
+	sql := s.statement()
+	logger.Info(sql, s.sqlVars)
+	if s.record(sql) {
+		return nil, ErrDryRun
+	}
+	if rows, err = s.DB().Query(sql, s.sqlVars...); err != nil {
 		logger.Error(err)
 		return nil, err
 	}
@@ -45,3 +45,31 @@ func TestQuery(t *testing.T) {
 	assert.Equal(t, 2, count)
 
 }
+
+func TestDryRunQueryRow(t *testing.T) {
+	// 没有数据库连接时不访问数据库
+	s := dryRun(t, "postgres")
+	count, err := s.Model(&User{}).Where("Age > ?", 10).Count()
+	assert.Equal(t, ErrDryRun, err)
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, false, s.Model(&User{}).HasTable())
+	assert.Equal(t, ErrDryRun, s.Save(&Post{ID: 1, Title: "a"}))
+
+	statements := s.Statements()
+	assert.Equal(t, 3, len(statements))
+	assert.Equal(t, `SELECT COUNT(*) FROM "user" WHERE Age > $1`, statements[0].SQL)
+	assert.Equal(t, `SELECT COUNT(*) FROM "post" WHERE "id" = $1`, statements[2].SQL)
+}
+
+func TestRawWithoutVarsNotRebound(t *testing.T) {
+	// 没有参数时不转换 ?，迁移文件中postgres的jsonb运算符保持不变
+	s := dryRun(t, "postgres")
+	_, err := s.Raw(`CREATE INDEX idx ON docs ((data ? 'a'), (data ?| array['b']))`).Exec()
+	assert.Equal(t, nil, err)
+	_, err = s.Raw(`SELECT * FROM docs WHERE id = ?`, 1).Exec()
+	assert.Equal(t, nil, err)
+
+	statements := s.Statements()
+	assert.Equal(t, `CREATE INDEX idx ON docs ((data ? 'a'), (data ?| array['b']))`, statements[0].SQL)
+	assert.Equal(t, `SELECT * FROM docs WHERE id = $1`, statements[1].SQL)
+}
@@ -2,78 +2,344 @@ package session
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"sorm/clause"
+	"sorm/schema"
+)
+
+var (
+	// ErrNoPrimaryKey 模型没有主键
+	ErrNoPrimaryKey = errors.New("sorm: model has no primary key")
+	// ErrMissingPrimaryKey 记录的主键为零值
+	ErrMissingPrimaryKey = errors.New("sorm: primary key is zero")
+	// ErrConflictTarget dialect无法生成冲突处理子句，如mysql保留已有记录时需要冲突的列或主键
+	ErrConflictTarget = errors.New("sorm: on conflict requires conflict columns or a primary key")
 )
 
 // Insert orm insert
 // session.Insert(&User{Name:"Tom",Age:12})
+// 传入指针时同时保存嵌套的关联值，可通过tag cascade关闭
+// 自增主键为零值时不写入该列，由数据库生成后写回，需要传入指针
+// 这类记录每条使用一条语句插入，其余记录合并为多行语句
 func (s *Session) Insert(values ...interface{}) (int64, error) {
-	recordValues := make([]interface{}, 0)
+	return s.create(values, false)
+}
+
+// create 插入values并级联保存关联值
+// bulk为true时自增主键为零值的记录也合并为多行语句，不写回生成的主键
+// 需要级联保存关联值且不在事务中时，在一个事务中执行，任一语句失败时全部回滚
+func (s *Session) create(values []interface{}, bulk bool) (affected int64, err error) {
+	if !s.hasAssociations(values) {
+		return s.createValues(values, bulk)
+	}
+	err = s.inTx(func() error {
+		affected, err = s.createValues(values, bulk)
+		return err
+	})
+	return affected, err
+}
+
+func (s *Session) createValues(values []interface{}, bulk bool) (affected int64, err error) {
+	for _, value := range values {
+		if err := s.saveBelongsTo(value); err != nil {
+			return 0, err
+		}
+	}
+	for _, batch := range s.insertBatches(values, bulk) {
+		n, err := s.insert(batch)
+		if err != nil {
+			return 0, err
+		}
+		affected += n
+	}
+	for _, value := range values {
+		if err := s.saveAssociations(value); err != nil {
+			return 0, err
+		}
+	}
+
+	s.CallMethod(AfterInsert, nil)
+	return affected, nil
+}
+
+// hasAssociations values中是否有需要保存关联值的记录
+func (s *Session) hasAssociations(values []interface{}) bool {
+	for _, value := range values {
+		if _, ok := addressable(value); ok && len(s.Model(value).GetRefTable().Relationships) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Upsert 插入values，与已有记录冲突时按conflict更新或保留已有记录
+// values为模型指针或模型切片，同一表的记录在一条语句中写入，不保存关联值
+// 只有一条记录时写回生成的主键
+// s.Upsert(users, clause.OnConflict{Columns: []string{"Name"}, DoUpdate: []string{"Age"}})
+func (s *Session) Upsert(values interface{}, conflict clause.OnConflict) (int64, error) {
+	var affected int64
+	for _, batch := range s.insertBatches(models(values), true) {
+		batch.conflict = &conflict
+		n, err := s.insert(batch)
+		if err != nil {
+			return 0, err
+		}
+		affected += n
+	}
+	s.CallMethod(AfterInsert, nil)
+	return affected, nil
+}
+
+// models 将模型切片展开为各元素的指针，单个模型原样返回
+func models(values interface{}) []interface{} {
+	v := reflect.ValueOf(values)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return []interface{}{values}
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		item := v.Index(i)
+		if item.Kind() == reflect.Struct && item.CanAddr() {
+			item = item.Addr()
+		}
+		items[i] = item.Interface()
+	}
+	return items
+}
+
+// insertBatch 可以在一条INSERT语句中写入的连续记录，表相同且是否由数据库生成主键相同
+type insertBatch struct {
+	table    *schema.Schema
+	autoID   bool
+	values   []interface{}
+	conflict *clause.OnConflict
+}
+
+// insertBatches 调用BeforeInsert钩子并将values按表与主键是否自增分组
+// bulk为false时需要生成主键的记录单独成组，以便写回主键
+func (s *Session) insertBatches(values []interface{}, bulk bool) []*insertBatch {
+	var batches []*insertBatch
 	for _, value := range values {
 		s.CallMethod(BeforeInsert, value)
 		// value为要映射的表实例
 		table := s.Model(value).GetRefTable()
-		s.clause.Set(clause.INSERT, table.Name, table.FieldNames)
-		recordValues = append(recordValues, table.RecordValues(value))
+		autoID := autoIncrement(table, value)
+		if n := len(batches); n > 0 && batches[n-1].table == table && batches[n-1].autoID == autoID && (bulk || !autoID) {
+			batches[n-1].values = append(batches[n-1].values, value)
+			continue
+		}
+		batches = append(batches, &insertBatch{table: table, autoID: autoID, values: []interface{}{value}})
+	}
+	return batches
+}
+
+// autoIncrement 记录的自增主键是否为零值
+func autoIncrement(table *schema.Schema, value interface{}) bool {
+	pk := table.PrimaryField
+	return pk != nil && pk.AutoIncrement && pk.ValueOf(reflect.ValueOf(value)).IsZero()
+}
+
+// insert 执行一条INSERT语句，生成的主键通过RETURNING或LastInsertId写回
+// 多行语句中RETURNING的行序与生成的id是否连续都没有保证，只在单行语句中写回主键
+func (s *Session) insert(batch *insertBatch) (int64, error) {
+	table, pk := batch.table, batch.table.PrimaryField
+	var fields []*schema.Field
+	for _, field := range table.Fields {
+		if !batch.autoID || field != pk {
+			fields = append(fields, field)
+		}
+	}
+	// 参数个数超过dialect的上限时拆分为多条语句，没有可写入的列时每条记录一条语句
+	limit := 1
+	if len(fields) > 0 && s.dialect.MaxBindVars()/len(fields) > 1 {
+		limit = s.dialect.MaxBindVars() / len(fields)
+	}
+	if len(batch.values) > limit {
+		var affected int64
+		for start := 0; start < len(batch.values); start += limit {
+			end := start + limit
+			if end > len(batch.values) {
+				end = len(batch.values)
+			}
+			chunk := *batch
+			chunk.values = batch.values[start:end]
+			n, err := s.insert(&chunk)
+			if err != nil {
+				return 0, err
+			}
+			affected += n
+		}
+		return affected, nil
+	}
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = s.quote(field.DBName)
+	}
+	recordValues := make([]interface{}, len(batch.values))
+	for i, value := range batch.values {
+		v := reflect.ValueOf(value)
+		record := make([]interface{}, len(fields))
+		for j, field := range fields {
+			record[j] = field.DBValue(v)
+		}
+		recordValues[i] = record
 	}
-	s.clause.Set(clause.VALUES, recordValues...)
-	sql, vars := s.clause.Build(clause.INSERT, clause.VALUES)
+	s.clause.Set(clause.INSERT, s.quote(table.Name), columns)
+	if len(fields) == 0 {
+		// 只有自增主键的模型，所有列使用默认值
+		s.clause.Set(clause.DEFAULTVALUES, s.dialect.DefaultValuesSQL())
+	} else {
+		s.clause.Set(clause.VALUES, recordValues...)
+	}
+	updates := true
+	if batch.conflict != nil {
+		// 不能省略冲突处理子句，否则冲突时插入失败而不是保留或更新已有记录
+		var sql string
+		if sql, updates = s.onConflictSQL(table, fields, batch.conflict); sql == "" {
+			s.Clear()
+			return 0, ErrConflictTarget
+		}
+		s.clause.Set(clause.ONCONFLICT, sql)
+	}
+
+	writeBack := batch.autoID && len(batch.values) == 1
+	// 冲突时保留已有记录的行不会返回，此时不写回主键
+	if writeBack && updates && s.dialect.SupportsReturning() {
+		s.clause.Set(clause.RETURNING, []string{s.quote(pk.DBName)})
+		sql, vars := s.clause.Build(clause.INSERT, clause.VALUES, clause.DEFAULTVALUES, clause.ONCONFLICT, clause.RETURNING)
+		rows, err := s.Raw(sql, vars...).Query()
+		if err == ErrDryRun {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		var n int64
+		for ; rows.Next(); n++ {
+			id := reflect.New(pk.ValueOf(reflect.ValueOf(batch.values[0])).Type())
+			if err := rows.Scan(id.Interface()); err != nil {
+				return 0, err
+			}
+			setPrimaryKey(pk, batch.values[0], id.Elem())
+		}
+		return n, rows.Err()
+	}
+
+	sql, vars := s.clause.Build(clause.INSERT, clause.VALUES, clause.DEFAULTVALUES, clause.ONCONFLICT)
 	result, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
 		return 0, err
 	}
-
-	s.CallMethod(AfterInsert, nil)
+	if writeBack && batch.conflict == nil && !s.dryRun {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		setPrimaryKey(pk, batch.values[0], reflect.ValueOf(id))
+	}
 	return result.RowsAffected()
 }
 
+// onConflictSQL 按dialect生成冲突处理子句，列名可以是字段名或列名
+// updates为false表示冲突时保留已有记录
+func (s *Session) onConflictSQL(table *schema.Schema, fields []*schema.Field, conflict *clause.OnConflict) (sql string, updates bool) {
+	quote := func(name string) string {
+		if field := table.LookUpField(name); field != nil {
+			name = field.DBName
+		}
+		return s.quote(name)
+	}
+	var columns, sets []string
+	for _, column := range conflict.Columns {
+		columns = append(columns, quote(column))
+	}
+	if len(columns) == 0 && table.PrimaryField != nil {
+		columns = []string{s.quote(table.PrimaryField.DBName)}
+	}
+	switch {
+	case conflict.DoNothing:
+	case len(conflict.DoUpdate) > 0:
+		for _, column := range conflict.DoUpdate {
+			sets = append(sets, quote(column))
+		}
+	default:
+		excluded := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			excluded[column] = true
+		}
+		for _, field := range fields {
+			if column := s.quote(field.DBName); !excluded[column] {
+				sets = append(sets, column)
+			}
+		}
+	}
+	return s.dialect.OnConflictSQL(columns, sets), len(sets) > 0
+}
+
+// setPrimaryKey 将生成的主键写回value，value不是指针时忽略
+func setPrimaryKey(pk *schema.Field, value interface{}, id reflect.Value) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr {
+		return
+	}
+	setField(pk.Settable(v), id)
+}
+
 // Find find of orm
 // var users []Users
 // session.Find(&users)
 // 根据获取到的值构造出相应对象
+// 指定Select时元素可以是任意结构体或map[string]interface{}，表名取自Model
 func (s *Session) Find(values interface{}) error {
 	destSlice := reflect.Indirect(reflect.ValueOf(values))
 	destType := destSlice.Type().Elem()
+	preloads := s.preloads
 	s.CallMethod(BeforeQuery, nil)
-	table := s.Model(reflect.New(destType).Elem().Interface()).GetRefTable()
-
-	s.clause.Set(clause.SELECT, table.Name, table.FieldNames)
-	sql, vars := s.clause.Build(clause.SELECT, clause.WHERE, clause.ORDERBY, clause.LIMIT)
-	rows, err := s.Raw(sql, vars...).Query()
+	if destType.Kind() == reflect.Struct && (len(s.selects) == 0 || s.refTable == nil) {
+		s.Model(reflect.New(destType).Elem().Interface())
+	}
+	rows, err := s.Rows()
 	if err != nil {
 		return err
 	}
-	for rows.Next() {
-		dest := reflect.New(destType).Elem()
-		var values []interface{}
-		for _, fieldName := range table.FieldNames {
-			values = append(values, dest.FieldByName(fieldName).Addr().Interface())
+	if err := s.scanRows(rows, destSlice); err != nil {
+		return err
+	}
+
+	table := s.refTable
+	defer func() { s.refTable = table }()
+	for _, path := range preloads {
+		if destType.Kind() != reflect.Struct {
+			return fmt.Errorf("sorm: cannot preload %s into %v", path, destType)
 		}
-		if err := rows.Scan(values...); err != nil {
+		if err := s.preload(records(destSlice), table, path); err != nil {
 			return err
 		}
-		s.CallMethod(AfterQuery, dest.Addr().Interface())
-		destSlice.Set(reflect.Append(destSlice, dest))
 	}
-
-	return rows.Close()
+	return nil
 }
 
 // Update update of orm
 // 1. map[string]interface{}
 // 2. k-v list : "Name","Tome","Age",12
 func (s *Session) Update(kvs ...interface{}) (int64, error) {
-	m, ok := kvs[0].(map[string]interface{})
-	if !ok {
-		m = make(map[string]interface{})
+	m := make(map[string]interface{})
+	if kv, ok := kvs[0].(map[string]interface{}); ok {
+		for k, v := range kv {
+			m[s.quote(s.columnName(k))] = v
+		}
+	} else {
 		for i := 0; i < len(kvs); i += 2 {
-			m[kvs[i].(string)] = kvs[i+1]
+			m[s.quote(s.columnName(kvs[i].(string)))] = kvs[i+1]
 		}
 	}
 	s.CallMethod(BeforeUpdate, nil)
 
-	s.clause.Set(clause.UPDATE, s.refTable.Name, m)
+	s.clause.Set(clause.UPDATE, s.quote(s.refTable.Name), m)
 	sql, vars := s.clause.Build(clause.UPDATE, clause.WHERE)
 	result, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
@@ -84,23 +350,134 @@ func (s *Session) Update(kvs ...interface{}) (int64, error) {
 }
 
 // Delete delete of orm
+// 1. 表名：Where(...).Delete("User")
+// 2. 模型：Delete(&user)，按主键删除，与Where中的条件以AND连接
 func (s *Session) Delete(values ...interface{}) (int64, error) {
 	// DELETE FROM $tableName
-	table := values[0]
-	s.clause.Set(clause.DELETE, table)
+	table, ok := values[0].(string)
+	var model interface{}
+	if !ok {
+		model = values[0]
+		if err := s.wherePrimaryKey(model); err != nil {
+			return 0, err
+		}
+		table = s.refTable.Name
+		s.CallMethod(BeforeDelete, model)
+	}
+	// 级联删除与删除记录在同一个事务中执行，删除记录失败时关联记录不会被删除
+	if s.refTable != nil && s.refTable.Name == table && len(cascadeDeletes(s.refTable)) > 0 {
+		var affected int64
+		err := s.inTx(func() (err error) {
+			if err = s.deleteAssociations(s.refTable); err != nil {
+				return err
+			}
+			affected, err = s.deleteFrom(table, model)
+			return err
+		})
+		return affected, err
+	}
+	return s.deleteFrom(table, model)
+}
+
+// deleteFrom 按当前条件删除table中的记录
+func (s *Session) deleteFrom(table string, model interface{}) (int64, error) {
+	s.clause.Set(clause.DELETE, s.quote(table))
 	sql, vars := s.clause.Build(clause.DELETE, clause.WHERE)
 	result, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
 		return 0, err
 	}
+	s.CallMethod(AfterDelete, model)
 
 	return result.RowsAffected()
 }
 
+// Save 主键为零值或记录不存在时插入，否则按主键更新所有列
+func (s *Session) Save(value interface{}) error {
+	table := s.Model(value).GetRefTable()
+	pk := table.PrimaryField
+	if pk == nil {
+		return ErrNoPrimaryKey
+	}
+	id := pk.ValueOf(reflect.ValueOf(value))
+	if id.IsZero() {
+		_, err := s.Insert(value)
+		return err
+	}
+	// 不依赖RowsAffected判断记录是否存在，mysql中未变化的行不计入RowsAffected
+	count, err := s.Where(clause.Eq(s.quote(pk.DBName), id.Interface())).Count()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		_, err = s.Model(value).Insert(value)
+		return err
+	}
+	_, err = s.update(value, false)
+	return err
+}
+
+// Updates 按主键更新value中的非零值字段，零值字段与主键不更新
+// 与Where中的条件以AND连接
+func (s *Session) Updates(value interface{}) (int64, error) {
+	return s.update(value, true)
+}
+
+// update 按主键更新value的列，skipZero为true时跳过零值字段
+func (s *Session) update(value interface{}, skipZero bool) (int64, error) {
+	if err := s.wherePrimaryKey(value); err != nil {
+		return 0, err
+	}
+	v := reflect.ValueOf(value)
+	m := make(map[string]interface{})
+	for _, field := range s.refTable.Fields {
+		if field == s.refTable.PrimaryField || (skipZero && field.ValueOf(v).IsZero()) {
+			continue
+		}
+		m[s.quote(field.DBName)] = field.DBValue(v)
+	}
+	if len(m) == 0 {
+		s.Clear()
+		return 0, nil
+	}
+	s.CallMethod(BeforeUpdate, value)
+	s.clause.Set(clause.UPDATE, s.quote(s.refTable.Name), m)
+	sql, vars := s.clause.Build(clause.UPDATE, clause.WHERE)
+	result, err := s.Raw(sql, vars...).Exec()
+	if err != nil {
+		return 0, err
+	}
+	s.CallMethod(AfterUpdate, value)
+	return result.RowsAffected()
+}
+
+// FindByID 按主键查询一条记录到value
+func (s *Session) FindByID(value interface{}, id interface{}) error {
+	pk := s.Model(value).GetRefTable().PrimaryField
+	if pk == nil {
+		return ErrNoPrimaryKey
+	}
+	return s.Where(clause.Eq(s.quote(pk.DBName), id)).First(value)
+}
+
+// wherePrimaryKey 以value的主键作为条件，并将refTable设置为value的模型
+func (s *Session) wherePrimaryKey(value interface{}) error {
+	pk := s.Model(value).GetRefTable().PrimaryField
+	if pk == nil {
+		return ErrNoPrimaryKey
+	}
+	id := pk.ValueOf(reflect.ValueOf(value))
+	if id.IsZero() {
+		return ErrMissingPrimaryKey
+	}
+	s.Where(clause.Eq(s.quote(pk.DBName), id.Interface()))
+	return nil
+}
+
 // Count count of orm
 func (s *Session) Count() (int64, error) {
-	s.clause.Set(clause.COUNT, s.refTable.Name)
-	sql, vars := s.clause.Build(clause.COUNT, clause.WHERE)
+	s.clause.Set(clause.COUNT, s.quote(s.refTable.Name))
+	sql, vars := s.clause.Build(clause.COUNT, clause.JOIN, clause.WHERE)
 	row := s.Raw(sql, vars...).QueryRow()
 	var count int64
 	if err := row.Scan(&count); err != nil {
@@ -115,13 +492,47 @@ func (s *Session) Limit(limit int) *Session {
 	return s
 }
 
-// Where chain链式调用
-func (s *Session) Where(desc string, values ...interface{}) *Session {
-	var vars []interface{}
-	s.clause.Set(clause.WHERE, append(append(vars, desc), values...)...)
+// Where chain链式调用，多次调用时以AND连接
+// 1. 原始条件: Where("Age > ?", 18)
+// 2. 条件表达式: Where(clause.In("Name", "Tom", "Sam"))
+// 3. map: Where(map[string]interface{}{"Name": "Tom"})
+// 条件表达式与map中的字段名按命名策略转换为列名并加引号
+func (s *Session) Where(query interface{}, values ...interface{}) *Session {
+	s.where = clause.And(s.where, s.toExpr(query, values))
+	return s.setWhere()
+}
+
+// OrWhere 与之前的所有条件以OR连接
+// Where(a).OrWhere(b).Where(c) 等价于 WHERE ((a) OR (b)) AND (c)
+func (s *Session) OrWhere(query interface{}, values ...interface{}) *Session {
+	s.where = clause.Or(s.where, s.toExpr(query, values))
+	return s.setWhere()
+}
+
+func (s *Session) setWhere() *Session {
+	if s.where != nil {
+		sql, vars := s.where.Build()
+		s.clause.Set(clause.WHERE, append([]interface{}{sql}, vars...)...)
+	}
 	return s
 }
 
+func (s *Session) toExpr(query interface{}, values []interface{}) clause.Expr {
+	switch q := query.(type) {
+	case clause.Expr:
+		return clause.QuoteColumns(q, s.quoteColumn)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(q))
+		for k, v := range q {
+			m[s.quoteColumn(k)] = v
+		}
+		return clause.Map(m)
+	case string:
+		return clause.Raw(q, values...)
+	}
+	panic(fmt.Sprintf("sorm: unsupported where condition %T", query))
+}
+
 // OrderBy chain链式调用
 func (s *Session) OrderBy(desc string) *Session {
 	s.clause.Set(clause.ORDERBY, desc)
@@ -1,7 +1,10 @@
 package session
 
 import (
+	"database/sql"
 	"fmt"
+	"sorm/clause"
+	"sorm/schema"
 	"testing"
 
 	"github.com/go-playground/assert"
@@ -105,3 +108,204 @@ func TestSession_Delete(t *testing.T) {
 	_ = session.Find(&users)
 	assert.Equal(t, 1, len(users))
 }
+
+func TestSession_WhereOr(t *testing.T) {
+	session := initTest(t)
+	_, _ = session.Insert(&User{"Sam", 25})
+
+	var users []User
+	err := session.Where("Age > ?", 12).Where(clause.Lt("Age", 20)).Find(&users)
+	if err != nil {
+		t.Fatalf("Where failed: %v", err)
+	}
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "John", users[0].Name)
+
+	// (Name = Tom OR Name = Sam) AND Age > 20
+	users = nil
+	err = session.Where(map[string]interface{}{"Name": "Tom"}).
+		OrWhere(clause.Eq("Name", "Sam")).
+		Where("Age > ?", 20).
+		Find(&users)
+	if err != nil {
+		t.Fatalf("OrWhere failed: %v", err)
+	}
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "Sam", users[0].Name)
+
+	count, err := session.Where(clause.In("Name", []string{"Tom", "John", "April"})).Count()
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	assert.Equal(t, 2, int(count))
+}
+
+type Base struct {
+	ID int `sorm:"PRIMARY KEY"`
+}
+
+type Contact struct {
+	*Base
+	Name    string
+	Phone   *string
+	Email   sql.NullString
+	Age     int
+	Address map[string]string `sorm:"serializer:json"`
+}
+
+func TestSession_NullableFields(t *testing.T) {
+	s := NewSession().Model(&Contact{})
+	_ = s.DropTable()
+	if err := s.CreateTable(); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	phone := "123"
+	_, err := s.Insert(
+		&Contact{Base: &Base{ID: 1}, Name: "Tom", Phone: &phone, Email: sql.NullString{String: "tom@a.com", Valid: true},
+			Address: map[string]string{"city": "Beijing"}},
+		&Contact{Base: &Base{ID: 2}, Name: "Sam"})
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	// 普通字段中的NULL读取为零值
+	if _, err := s.Raw("INSERT INTO Contact (ID, Name, Age) VALUES (?, NULL, NULL)", 3).Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	var contacts []Contact
+	if err := s.OrderBy("ID").Find(&contacts); err != nil {
+		t.Fatalf("failed to find: %v", err)
+	}
+	assert.Equal(t, 3, len(contacts))
+	assert.Equal(t, 1, contacts[0].ID)
+	assert.Equal(t, "123", *contacts[0].Phone)
+	assert.Equal(t, "tom@a.com", contacts[0].Email.String)
+	assert.Equal(t, "Beijing", contacts[0].Address["city"])
+	assert.Equal(t, (*string)(nil), contacts[1].Phone)
+	assert.Equal(t, false, contacts[1].Email.Valid)
+	assert.Equal(t, 0, len(contacts[1].Address))
+	assert.Equal(t, "", contacts[2].Name)
+	assert.Equal(t, 0, contacts[2].Age)
+	_ = s.DropTable()
+}
+
+func TestSession_PrimaryKey(t *testing.T) {
+	s := NewSession().Model(&Post{})
+	_ = s.DropTable()
+	if err := s.CreateTable(); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	posts := []*Post{{Title: "a"}, {Title: "b"}, {ID: 10, Title: "c"}, {Title: "d"}}
+	affected, err := s.Insert(posts[0], posts[1], posts[2], posts[3])
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	assert.Equal(t, 4, int(affected))
+	assert.Equal(t, []int64{1, 2, 10, 11}, []int64{posts[0].ID, posts[1].ID, posts[2].ID, posts[3].ID})
+
+	// Updates只更新非零值字段
+	if _, err := s.Updates(&Post{ID: 1, Views: 5}); err != nil {
+		t.Fatal(err)
+	}
+	var post Post
+	if err := s.FindByID(&post, 1); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Post{ID: 1, Title: "a", Views: 5}, post)
+
+	// Save按主键更新所有列，主键为零值或记录不存在时插入
+	post.Title, post.Views = "a2", 0
+	if err := s.Save(&post); err != nil {
+		t.Fatal(err)
+	}
+	created := &Post{Title: "e"}
+	if err := s.Save(created); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(12), created.ID)
+	if err := s.Save(&Post{ID: 20, Title: "f"}); err != nil {
+		t.Fatal(err)
+	}
+	var saved []Post
+	if err := s.Where(clause.In("ID", 1, 20)).OrderBy("ID").Find(&saved); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Post{{ID: 1, Title: "a2"}, {ID: 20, Title: "f"}}, saved)
+
+	affected, err = s.Delete(&Post{ID: 2})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, int(affected))
+	_, err = s.Delete(&Post{})
+	assert.Equal(t, ErrMissingPrimaryKey, err)
+	assert.NotEqual(t, nil, s.FindByID(&post, 2))
+	count, _ := s.Model(&Post{}).Count()
+	assert.Equal(t, 5, int(count))
+	_ = s.DropTable()
+}
+
+type Sku struct {
+	ID   int64  `sorm:"primary key;autoIncrement"`
+	Code string `sorm:"uniqueIndex"`
+	Name string
+	Qty  int
+}
+
+func TestSession_Upsert(t *testing.T) {
+	s := NewSession().Model(&Sku{})
+	_ = s.DropTable()
+	if err := s.CreateTable(); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := s.Insert(&Sku{Code: "A", Name: "apple", Qty: 1}, &Sku{Code: "B", Name: "banana", Qty: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 冲突时只更新Qty，多行语句不写回生成的主键
+	skus := []Sku{{Code: "A", Name: "apricot", Qty: 10}, {Code: "C", Name: "cherry", Qty: 3}}
+	affected, err := s.Upsert(skus, clause.OnConflict{Columns: []string{"Code"}, DoUpdate: []string{"Qty"}})
+	if err != nil {
+		t.Fatalf("failed to upsert: %v", err)
+	}
+	assert.Equal(t, 2, int(affected))
+	assert.Equal(t, int64(0), skus[0].ID)
+	assert.Equal(t, int64(0), skus[1].ID)
+
+	// 冲突时保留已有记录
+	if _, err := s.Upsert(&Sku{ID: 2, Code: "B", Name: "blueberry"}, clause.OnConflict{DoNothing: true}); err != nil {
+		t.Fatal(err)
+	}
+	// 默认更新除冲突列外的所有列
+	if _, err := s.Upsert(&Sku{ID: 3, Code: "C", Name: "coconut", Qty: 4}, clause.OnConflict{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var result []Sku
+	if err := s.OrderBy("ID").Find(&result); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Sku{
+		{ID: 1, Code: "A", Name: "apple", Qty: 10},
+		{ID: 2, Code: "B", Name: "banana", Qty: 2},
+		{ID: 3, Code: "C", Name: "coconut", Qty: 4},
+	}, result)
+	_ = s.DropTable()
+
+	// mysql没有冲突的列时无法保留已有记录，不退化为普通INSERT
+	mysql := dryRun(t, "mysql")
+	_, err = mysql.Upsert(&Account{UserName: "Tom"}, clause.OnConflict{DoNothing: true})
+	assert.Equal(t, ErrConflictTarget, err)
+	assert.Equal(t, 0, len(mysql.Statements()))
+	_, err = mysql.Upsert(&Account{UserName: "Tom"}, clause.OnConflict{Columns: []string{"UserName"}, DoNothing: true})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(mysql.Statements()))
+}
+
+func TestSession_WhereQuotesColumns(t *testing.T) {
+	s := dryRun(t, "postgres").SetNamer(schema.NamingStrategy{SnakeCase: true, PluralTables: true})
+	var items []OrderItem
+	_ = s.Model(&OrderItem{}).Where(clause.Between("UnitPrice", 1, 9)).
+		Where(map[string]interface{}{"OrderCode": "A1"}).Find(&items)
+	statements := s.Statements()
+	assert.Equal(t, `SELECT "id","code","unit_price" FROM "order_items" WHERE "unit_price" BETWEEN $1 AND $2 AND "code" = $3`,
+		statements[len(statements)-1].SQL)
+}
@@ -14,11 +14,28 @@ import (
 func (s *Session) Model(value interface{}) *Session {
 	// refTable==nil 或传入的类型发生变化才更新refTable
 	if s.refTable == nil || reflect.TypeOf(value) != reflect.TypeOf(s.refTable.Model) {
-		s.refTable = schema.Parse(value, s.dialect)
+		s.refTable = schema.ParseWithNamer(value, s.dialect, s.namer)
 	}
 	return s
 }
 
+// SetNamer 设置命名策略，之后的Model按新的策略解析
+func (s *Session) SetNamer(namer schema.Namer) *Session {
+	s.namer = namer
+	s.refTable = nil
+	return s
+}
+
+// columnName 将refTable的字段名转换为列名，不是字段名时原样返回
+func (s *Session) columnName(name string) string {
+	if s.refTable != nil {
+		if field := s.refTable.GetField(name); field != nil {
+			return field.DBName
+		}
+	}
+	return name
+}
+
 // GetRefTable get refTable
 func (s *Session) GetRefTable() *schema.Schema {
 	if s.refTable == nil {
@@ -28,22 +45,25 @@ func (s *Session) GetRefTable() *schema.Schema {
 }
 
 // CreateTable create a new table
+// 同时创建模型声明的索引与many-to-many的连接表
 func (s *Session) CreateTable() error {
-	var columns []string
 	table := s.refTable
-	for _, col := range table.Fields {
-		// 拼接字段名、类型和标签
-		columns = append(columns, fmt.Sprintf("%s %s %s", col.Name, col.Type, col.Tag))
+	sqls := []string{table.CreateTableSQL(s.dialect, "")}
+	for _, idx := range table.Indexes {
+		sqls = append(sqls, idx.CreateSQL(s.dialect, table.Name))
 	}
-
-	desc := strings.Join(columns, ",")
-	_, err := s.Raw(fmt.Sprintf("CREATE TABLE %s (%s);", table.Name, desc)).Exec()
-	return err
+	sqls = append(sqls, table.JoinTablesSQL(s.dialect)...)
+	for _, sql := range sqls {
+		if _, err := s.Raw(sql).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // DropTable drop a table
 func (s *Session) DropTable() error {
-	_, err := s.Raw(fmt.Sprintf("DROP TABLE %s", s.refTable.Name)).Exec()
+	_, err := s.Raw(fmt.Sprintf("DROP TABLE %s", s.quote(s.refTable.Name))).Exec()
 	return err
 }
 
@@ -53,5 +73,50 @@ func (s *Session) HasTable() bool {
 	row := s.Raw(sql, args...).QueryRow()
 	var tmp string
 	_ = row.Scan(&tmp)
-	return tmp == s.GetRefTable().Name
+	// postgres中的表名为小写
+	return strings.EqualFold(tmp, s.GetRefTable().Name)
+}
+
+// quote 按dialect为标识符加引号
+func (s *Session) quote(name string) string {
+	return s.dialect.Quote(name)
+}
+
+func (s *Session) quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = s.quote(name)
+	}
+	return quoted
+}
+
+// quoteColumn 为列名加引号，table.column分别加引号，表达式如 COUNT(*) AS Total 保持不变
+// 不带表名或表名为refTable时，字段名转换为列名
+func (s *Session) quoteColumn(name string) string {
+	parts := strings.Split(name, ".")
+	for _, part := range parts {
+		if !isIdentifier(part) {
+			return name
+		}
+	}
+	last := len(parts) - 1
+	if last == 0 || (s.refTable != nil && parts[0] == s.refTable.Name) {
+		parts[last] = s.columnName(parts[last])
+	}
+	return strings.Join(s.quoteAll(parts), ".")
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
 }
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"sorm/schema"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -31,3 +32,32 @@ func TestSession_HasTable(t *testing.T) {
 	exists := session.HasTable()
 	assert.True(t, exists)
 }
+
+type OrderItem struct {
+	ID        int    `sorm:"primary key"`
+	OrderCode string `sorm:"column:code;not null;index"`
+	UnitPrice int    `sorm:"default:0"`
+	Note      string `sorm:"-"`
+}
+
+func TestSession_NamingStrategy(t *testing.T) {
+	s := NewSession().SetNamer(schema.NamingStrategy{SnakeCase: true, PluralTables: true}).Model(&OrderItem{})
+	assert.Equal(t, "order_items", s.GetRefTable().Name)
+	_ = s.DropTable()
+	require.NoError(t, s.CreateTable())
+	require.True(t, s.HasTable())
+
+	_, err := s.Insert(&OrderItem{ID: 1, OrderCode: "A1", UnitPrice: 10, Note: "skipped"}, &OrderItem{ID: 2, OrderCode: "B2", UnitPrice: 20})
+	require.NoError(t, err)
+	_, err = s.Where(map[string]interface{}{"OrderCode": "B2"}).Update("UnitPrice", 25)
+	require.NoError(t, err)
+
+	var items []OrderItem
+	require.NoError(t, s.Where("unit_price > ?", 15).Find(&items))
+	assert.Equal(t, []OrderItem{{ID: 2, OrderCode: "B2", UnitPrice: 25}}, items)
+
+	var codes []string
+	require.NoError(t, s.OrderBy("id").Pluck("OrderCode", &codes))
+	assert.Equal(t, []string{"A1", "B2"}, codes)
+	require.NoError(t, s.DropTable())
+}
@@ -8,7 +8,7 @@ import (
 // Begin start a transaction
 func (s *Session) Begin() (tx *sql.Tx, err error) {
 	if s.tx, err = s.db.Begin(); err != nil {
-		logger.Error("Start transaction failed: %v", err)
+		logger.Errorf("Start transaction failed: %v", err)
 		return nil, err
 	}
 
@@ -18,7 +18,7 @@ func (s *Session) Begin() (tx *sql.Tx, err error) {
 // Commit commit a transaction
 func (s *Session) Commit() error {
 	if err := s.tx.Commit(); err != nil {
-		logger.Error("Commit failed: %v", err)
+		logger.Errorf("Commit failed: %v", err)
 		return err
 	}
 
@@ -28,9 +28,28 @@ func (s *Session) Commit() error {
 // Rollback rollback a transaction
 func (s *Session) Rollback() error {
 	if err := s.tx.Rollback(); err != nil {
-		logger.Error("Rollback failed: %v", err)
+		logger.Errorf("Rollback failed: %v", err)
 		return err
 	}
 
 	return nil
 }
+
+// inTx 在事务中执行fn，fn返回错误时回滚，已在事务中或DryRun时直接执行，由调用方提交或回滚
+func (s *Session) inTx(fn func() error) (err error) {
+	if s.tx != nil || s.dryRun {
+		return fn()
+	}
+	if _, err = s.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = s.Rollback()
+		} else {
+			err = s.Commit()
+		}
+		s.tx = nil
+	}()
+	return fn()
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"sorm/dialect"
 	"sorm/logger"
+	"sorm/migrate"
+	"sorm/schema"
 	"sorm/session"
-	"strings"
 
 	log "sorm/logger"
 )
@@ -15,6 +16,7 @@ import (
 type Engine struct {
 	db      *sql.DB
 	dialect dialect.Dialector
+	namer   schema.Namer
 }
 
 // NewEngine create an engine
@@ -32,8 +34,11 @@ func NewEngine(driver, source string) (engine *Engine, err error) {
 
 	dialect, ok := dialect.GetDialect(driver)
 	if !ok {
-		logger.Errorf("dialect %s not found", driver)
-		return
+		// logger.Errorf不会退出进程，需要返回错误，避免调用方拿到nil的engine
+		err = fmt.Errorf("sorm: dialect %s not found", driver)
+		logger.Error(err)
+		_ = db.Close()
+		return nil, err
 	}
 	engine = &Engine{db: db, dialect: dialect}
 	log.Info("Connecting to database successfully")
@@ -43,13 +48,19 @@ func NewEngine(driver, source string) (engine *Engine, err error) {
 
 // NewSession create a session
 func (engine *Engine) NewSession() *session.Session {
-	return session.New(engine.db, engine.dialect)
+	return session.New(engine.db, engine.dialect).SetNamer(engine.namer)
+}
+
+// SetNamingStrategy 设置表名与列名的命名策略，对之后创建的session与迁移生效
+// engine.SetNamingStrategy(schema.NamingStrategy{SnakeCase: true, PluralTables: true})
+func (engine *Engine) SetNamingStrategy(namer schema.Namer) {
+	engine.namer = namer
 }
 
 // Close close session
 func (engine *Engine) Close() error {
 	if err := engine.db.Close(); err != nil {
-		log.Error("close session failed :%v", err)
+		log.Errorf("close session failed :%v", err)
 		return err
 	}
 	log.Info("session closed")
@@ -81,62 +92,14 @@ func (engine *Engine) Transaction(fs TxFunc) (result interface{}, err error) {
 	return fs(session)
 }
 
-// return difference a - b
-func difference(a, b []string) []string {
-	m := make(map[string]bool)
-	for _, v := range b {
-		m[v] = true
-	}
-
-	var diff []string
-	for _, v := range a {
-		if _, ok := m[v]; !ok {
-			diff = append(diff, v)
-		}
-	}
-
-	return diff
+// Migrator 创建迁移执行器
+func (engine *Engine) Migrator() *migrate.Migrator {
+	return migrate.New(engine.db, engine.dialect).SetNamer(engine.namer)
 }
 
-// Migrate only add columns and delete columns
-// delete columns 采用先创建临时表，临时表替换原表的方式实现
-// CREATE TABLE tmp AS SELECT field1, field2 ... FROM TABLE_SOURCE
-// DROP TABLE TABLE_SOURCE;
-// ALTER TABLE tmp RENAME TO TABLE_SOURCE;
-func (engine *Engine) Migrate(value interface{}) (err error) {
-	engine.Transaction(func(s *session.Session) (result interface{}, err error) {
-		if !s.Model(value).HasTable() {
-			logger.Infof("table %v not exists", s.Model(&value).GetRefTable().Name)
-			s.CreateTable()
-		}
-
-		table := s.GetRefTable()
-		rows, _ := s.Raw(fmt.Sprintf("SELECT * FROM %s LIMIT 1;", table.Name)).Query()
-		columns, _ := rows.Columns()
-		// add columns
-		addCols := difference(table.FieldNames, columns)
-		// delete columns
-		deleteCols := difference(columns, table.FieldNames)
-		logger.Infof("add cols %v ; delete cols %v", addCols, deleteCols)
-
-		for _, col := range addCols {
-			field := table.GetField(col)
-			if _, err = s.Raw(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table.Name, field.Name, field.Type)).Exec(); err != nil {
-				return
-			}
-		}
-
-		if len(deleteCols) == 0 {
-			return
-		}
-
-		tmp := "tmp_" + table.Name
-		fieldStr := strings.Join(table.FieldNames, ", ")
-		s.Raw(fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s ;", tmp, fieldStr, table.Name))
-		s.Raw(fmt.Sprintf("DROP TABLE %s;", table.Name))
-		s.Raw(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmp, table.Name))
-		_, err = s.Exec()
-		return
-	})
-	return err
+// Migrate 使表结构与模型一致：新增、删除列，修改列类型与索引
+// 不支持修改列类型的数据库通过新建临时表替换原表实现
+// 所有语句在一个事务中执行，任一语句失败时回滚并返回错误
+func (engine *Engine) Migrate(value interface{}) error {
+	return engine.Migrator().AutoMigrate(value)
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultReplicas 与HTTPPool的默认值一致
+const defaultReplicas = 3
+
+// Config ccache-server配置文件
+type Config struct {
+	// 本节点地址，需出现在Peers中，如 http://10.0.0.1:8081
+	Self string `json:"self" yaml:"self"`
+	// 节点间通信监听地址
+	Listen string `json:"listen" yaml:"listen"`
+	// 存活/就绪探针及管理接口监听地址
+	OpsListen string `json:"ops_listen" yaml:"ops_listen"`
+	// 管理接口的访问令牌，为空时不开启管理接口
+	AdminToken string   `json:"admin_token" yaml:"admin_token"`
+	Peers      []string `json:"peers" yaml:"peers"`
+	// ring(默认)、rendezvous、jump、bounded
+	Partitioner string `json:"partitioner" yaml:"partitioner"`
+	// 一致性哈希虚拟节点倍数，默认为3，bounded使用同样的倍数
+	Replicas int `json:"replicas" yaml:"replicas"`
+	// 收到退出信号后，就绪探针先返回失败，等待DrainDelay后再停止接收请求
+	DrainDelay Duration `json:"drain_delay" yaml:"drain_delay"`
+	// 等待进行中的请求结束的最长时间
+	ShutdownTimeout Duration      `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	TLS             *TLSConfig    `json:"tls" yaml:"tls"`
+	Auth            *AuthConfig   `json:"auth" yaml:"auth"`
+	Groups          []GroupConfig `json:"groups" yaml:"groups"`
+}
+
+// TLSConfig 节点间TLS配置
+type TLSConfig struct {
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+	CAFile   string `json:"ca_file" yaml:"ca_file"`
+	Mutual   bool   `json:"mutual" yaml:"mutual"`
+}
+
+// AuthConfig 节点间HMAC签名密钥
+type AuthConfig struct {
+	KeyID  string `json:"key_id" yaml:"key_id"`
+	Secret string `json:"secret" yaml:"secret"`
+}
+
+// GroupConfig 缓存组配置
+type GroupConfig struct {
+	Name       string       `json:"name" yaml:"name"`
+	CacheBytes int64        `json:"cache_bytes" yaml:"cache_bytes"`
+	Origin     OriginConfig `json:"origin" yaml:"origin"`
+}
+
+// OriginConfig 数据源配置
+type OriginConfig struct {
+	// http、sorm 或 static
+	Type string `json:"type" yaml:"type"`
+
+	// http: 源站地址，{key}会被替换为转义后的key，如 http://origin/api/{key}
+	URL     string   `json:"url" yaml:"url"`
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+
+	// sorm: 数据库驱动、数据源及查询语句，查询语句以key为唯一参数并返回一列
+	Driver string `json:"driver" yaml:"driver"`
+	Source string `json:"source" yaml:"source"`
+	Query  string `json:"query" yaml:"query"`
+
+	// static: 固定的键值对，用于演示和测试
+	Values map[string]string `json:"values" yaml:"values"`
+}
+
+// Duration 支持在配置文件中使用 "10s" 形式的时长
+type Duration time.Duration
+
+// UnmarshalYAML 与UnmarshalJSON相同，时长为 "10s" 形式的字符串
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalJSON ...
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadConfig 读取并校验JSON或YAML配置文件
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Listen:          ":8081",
+		OpsListen:       ":9091",
+		Replicas:        defaultReplicas,
+		DrainDelay:      Duration(5 * time.Second),
+		ShutdownTimeout: Duration(15 * time.Second),
+	}
+	// .yaml与.yml按YAML解析，其他按JSON解析
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, cfg)
+	default:
+		err = json.Unmarshal(b, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Self == "" {
+		return errors.New("self is required")
+	}
+	found := false
+	for _, peer := range c.Peers {
+		if peer == c.Self {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("self %s is not in peers", c.Self)
+	}
+	if c.Replicas <= 0 {
+		return fmt.Errorf("replicas must be positive, got %d", c.Replicas)
+	}
+	if len(c.Groups) == 0 {
+		return errors.New("at least one group is required")
+	}
+	names := make(map[string]bool)
+	for _, g := range c.Groups {
+		if g.Name == "" {
+			return errors.New("group name is required")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate group %s", g.Name)
+		}
+		names[g.Name] = true
+	}
+	return nil
+}
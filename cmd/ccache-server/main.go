@@ -0,0 +1,199 @@
+// ccache-server 独立运行的ccache节点
+//
+//	ccache-server -config config.json
+//
+// 节点间通信监听在 listen，存活/就绪探针与管理接口监听在 ops_listen:
+//
+//	GET /healthz               存活探针
+//	GET /readyz                就绪探针，监听成功后返回200，退出时先返回503
+//	    /_ccache/admin/...     管理接口，配置admin_token后才开启，请求需携带 Authorization: Bearer <admin_token>
+package main
+
+import (
+	"ccache"
+	"ccache/consistenthash"
+	"context"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	var path string
+	flag.StringVar(&path, "config", "ccache.json", "Config file path")
+	flag.Parse()
+
+	if err := run(path); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run 启动节点直到收到退出信号或服务异常退出，返回前关闭数据源
+func run(path string) error {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	closers, err := registerGroups(cfg.Groups)
+	defer func() {
+		for _, closer := range closers {
+			_ = closer()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	pool, err := newPool(cfg)
+	if err != nil {
+		return err
+	}
+
+	// 先绑定端口，两个监听都成功后才标记为就绪
+	peerListener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return err
+	}
+	opsListener, err := net.Listen("tcp", cfg.OpsListen)
+	if err != nil {
+		peerListener.Close()
+		return err
+	}
+
+	var ready int32
+	peerServer := &http.Server{Handler: pool, TLSConfig: pool.ServerTLSConfig()}
+	opsServer := &http.Server{Handler: opsHandler(&ready, cfg.AdminToken)}
+
+	errCh := make(chan error, 2)
+	go func() {
+		log.Println("ccache is running at:", peerListener.Addr())
+		if peerServer.TLSConfig != nil {
+			errCh <- peerServer.ServeTLS(peerListener, "", "")
+		} else {
+			errCh <- peerServer.Serve(peerListener)
+		}
+	}()
+	go func() {
+		log.Println("ops server is running at:", opsListener.Addr())
+		errCh <- opsServer.Serve(opsListener)
+	}()
+	atomic.StoreInt32(&ready, 1)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	// 服务异常退出时同样优雅关闭，并以非0状态退出
+	var serveErr error
+	select {
+	case sig := <-sigCh:
+		log.Println("received signal:", sig)
+	case err := <-errCh:
+		log.Println("server stopped:", err)
+		if err != http.ErrServerClosed {
+			serveErr = err
+		}
+	}
+
+	// 先摘除流量，再等待进行中的请求结束
+	atomic.StoreInt32(&ready, 0)
+	time.Sleep(time.Duration(cfg.DrainDelay))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	if err := peerServer.Shutdown(ctx); err != nil {
+		log.Println("shutdown peer server:", err)
+	}
+	if err := opsServer.Shutdown(ctx); err != nil {
+		log.Println("shutdown ops server:", err)
+	}
+	log.Println("ccache stopped")
+	return serveErr
+}
+
+func registerGroups(groups []GroupConfig) (closers []func() error, err error) {
+	for _, g := range groups {
+		getter, closer, err := newGetter(g.Origin)
+		if err != nil {
+			return closers, err
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		if _, err := ccache.DefaultRegistry.NewGroup(g.Name, g.CacheBytes, getter, ccache.GroupOptions{}); err != nil {
+			return closers, err
+		}
+	}
+	return closers, nil
+}
+
+func newPool(cfg *Config) (*ccache.HTTPPool, error) {
+	opts := ccache.HTTPPoolOptions{Replicas: cfg.Replicas}
+	switch cfg.Partitioner {
+	case "", "ring":
+	case "rendezvous":
+		opts.NewPartitioner = func() ccache.Partitioner { return consistenthash.NewRendezvous(nil) }
+	case "jump":
+		opts.NewPartitioner = func() ccache.Partitioner { return consistenthash.NewJump(nil) }
+	case "bounded":
+		opts.NewPartitioner = func() ccache.Partitioner { return consistenthash.NewBounded(cfg.Replicas, 0, nil) }
+	default:
+		return nil, fmt.Errorf("unknown partitioner %q", cfg.Partitioner)
+	}
+	if cfg.TLS != nil {
+		tlsOpts, err := ccache.LoadTLSOptions(cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile, cfg.TLS.Mutual)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLS = tlsOpts
+	}
+	if cfg.Auth != nil {
+		opts.Auth = ccache.NewHMACAuth(cfg.Auth.KeyID, []byte(cfg.Auth.Secret))
+	}
+
+	pool := ccache.NewHTTPPoolWithOpts(cfg.Self, opts)
+	pool.Set(cfg.Peers...)
+	for _, g := range cfg.Groups {
+		ccache.GetGroup(g.Name).RegisterPeers(pool)
+	}
+	return pool, nil
+}
+
+func opsHandler(ready *int32, adminToken string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(ready) == 0 {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	if adminToken != "" {
+		mux.Handle("/_ccache/admin/", requireToken(adminToken, ccache.NewAdminHandler()))
+	}
+	return mux
+}
+
+// requireToken 校验 Authorization: Bearer <token>，不匹配时返回401
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
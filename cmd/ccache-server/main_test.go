@@ -0,0 +1,192 @@
+package main
+
+import (
+	"ccache"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	return writeConfigFile(t, "ccache.json", content)
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `{
+		"self": "http://a:8081",
+		"peers": ["http://a:8081", "http://b:8081"],
+		"drain_delay": "1s",
+		"groups": [{"name": "scores", "cache_bytes": 64, "origin": {"type": "static"}}]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, ":8081", cfg.Listen)
+	assert.Equal(t, ":9091", cfg.OpsListen)
+	assert.Equal(t, Duration(time.Second), cfg.DrainDelay)
+	assert.Equal(t, Duration(15*time.Second), cfg.ShutdownTimeout)
+	assert.Equal(t, int64(64), cfg.Groups[0].CacheBytes)
+
+	// 扩展名为.yaml或.yml时按YAML解析
+	cfg, err = LoadConfig(writeConfigFile(t, "ccache.yaml", `
+self: http://a:8081
+peers: [http://a:8081, http://b:8081]
+partitioner: bounded
+drain_delay: 1s
+groups:
+  - name: scores
+    cache_bytes: 64
+    origin: {type: static, values: {Tom: 630}}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "bounded", cfg.Partitioner)
+	assert.Equal(t, defaultReplicas, cfg.Replicas)
+	assert.Equal(t, Duration(time.Second), cfg.DrainDelay)
+	assert.Equal(t, "630", cfg.Groups[0].Origin.Values["Tom"])
+	_, err = LoadConfig(writeConfigFile(t, "ccache.yml", "drain_delay: [1]"))
+	assert.Contains(t, err.Error(), "duration must be a string")
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+	_, err = LoadConfig(writeConfig(t, `{"drain_delay": 5}`))
+	assert.Contains(t, err.Error(), "duration must be a string")
+}
+
+func TestConfigValidate(t *testing.T) {
+	group := GroupConfig{Name: "scores"}
+	cases := []struct {
+		cfg Config
+		err string
+	}{
+		{Config{}, "self is required"},
+		{Config{Self: "a", Peers: []string{"b"}}, "self a is not in peers"},
+		{Config{Self: "a", Peers: []string{"a"}}, "replicas must be positive, got 0"},
+		{Config{Self: "a", Peers: []string{"a"}, Replicas: 3}, "at least one group is required"},
+		{Config{Self: "a", Peers: []string{"a"}, Replicas: 3, Groups: []GroupConfig{{}}}, "group name is required"},
+		{Config{Self: "a", Peers: []string{"a"}, Replicas: 3, Groups: []GroupConfig{group, group}}, "duplicate group scores"},
+	}
+	for _, c := range cases {
+		assert.EqualError(t, c.cfg.validate(), c.err)
+	}
+	assert.NoError(t, (&Config{Self: "a", Peers: []string{"a"}, Replicas: 3, Groups: []GroupConfig{group}}).validate())
+}
+
+func TestNewPoolBounded(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `{
+		"self": "http://a:8081",
+		"peers": ["http://a:8081", "http://b:8081", "http://c:8081"],
+		"partitioner": "bounded",
+		"groups": [{"name": "pool-bounded", "origin": {"type": "static"}}]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, defaultReplicas, cfg.Replicas)
+	_, err = registerGroups(cfg.Groups)
+	require.NoError(t, err)
+	pool, err := newPool(cfg)
+	require.NoError(t, err)
+
+	// 虚拟节点倍数为0时哈希环为空，所有key都会在本节点加载
+	remote := 0
+	for i := 0; i < 30; i++ {
+		if _, ok := pool.PickPeer(fmt.Sprintf("key%d", i)); ok {
+			remote++
+		}
+	}
+	assert.NotZero(t, remote)
+}
+
+func TestNewGetter(t *testing.T) {
+	getter, closer, err := newGetter(OriginConfig{Type: "static", Values: map[string]string{"Tom": "630"}})
+	require.NoError(t, err)
+	assert.Nil(t, closer)
+	v, err := getter.Get("Tom")
+	require.NoError(t, err)
+	assert.Equal(t, "630", string(v))
+	// 不存在的key包装ErrNotFound，可进入负缓存
+	_, err = getter.Get("Sam")
+	assert.True(t, errors.Is(err, ccache.ErrNotFound))
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// key按路径段转义
+		if r.URL.EscapedPath() != "/pages/a%2Fb" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("page"))
+	}))
+	defer origin.Close()
+	getter, _, err = newGetter(OriginConfig{Type: "http", URL: origin.URL + "/pages/{key}"})
+	require.NoError(t, err)
+	v, err = getter.Get("a/b")
+	require.NoError(t, err)
+	assert.Equal(t, "page", string(v))
+	_, err = getter.Get("missing")
+	assert.True(t, errors.Is(err, ccache.ErrNotFound))
+
+	getter, closer, err = newGetter(OriginConfig{Type: "sorm", Driver: "sqlite3", Source: ":memory:", Query: "SELECT ? || '!'"})
+	require.NoError(t, err)
+	defer closer()
+	v, err = getter.Get("hi")
+	require.NoError(t, err)
+	assert.Equal(t, "hi!", string(v))
+	getter, closer, err = newGetter(OriginConfig{Type: "sorm", Driver: "sqlite3", Source: ":memory:", Query: "SELECT 1 WHERE ? = 'hi'"})
+	require.NoError(t, err)
+	defer closer()
+	_, err = getter.Get("missing")
+	assert.True(t, errors.Is(err, ccache.ErrNotFound))
+
+	_, _, err = newGetter(OriginConfig{Type: "sorm"})
+	assert.EqualError(t, err, "sorm origin requires query")
+	_, _, err = newGetter(OriginConfig{Type: "redis"})
+	assert.EqualError(t, err, `unknown origin type "redis"`)
+}
+
+func TestOpsHandler(t *testing.T) {
+	var ready int32
+	handler := opsHandler(&ready, "secret")
+	serve := func(method, path, auth string) int {
+		r := httptest.NewRequest(method, path, nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/healthz", ""))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodGet, "/readyz", ""))
+	ready = 1
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/readyz", ""))
+
+	// 管理接口需要令牌
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "/_ccache/admin/scores/", ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "/_ccache/admin/scores/", "Bearer wrong"))
+	assert.NotEqual(t, http.StatusUnauthorized, serve(http.MethodGet, "/_ccache/admin/scores/", "Bearer secret"))
+
+	// 未配置令牌时不开启管理接口
+	handler = opsHandler(&ready, "")
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/_ccache/admin/scores/", "Bearer "))
+}
+
+func TestExampleConfigs(t *testing.T) {
+	j, err := LoadConfig("example.json")
+	require.NoError(t, err)
+	y, err := LoadConfig("example.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, j, y)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"ccache"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sorm"
+	"strings"
+	"time"
+)
+
+const defaultOriginTimeout = 5 * time.Second
+
+// newGetter 根据配置创建数据源适配器，返回的closer用于退出时释放资源
+func newGetter(cfg OriginConfig) (getter ccache.Getter, closer func() error, err error) {
+	switch cfg.Type {
+	case "http":
+		return newHTTPOrigin(cfg), nil, nil
+	case "sorm":
+		return newSORMOrigin(cfg)
+	case "static":
+		return staticOrigin(cfg.Values), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown origin type %q", cfg.Type)
+	}
+}
+
+// httpOrigin 从HTTP源站获取数据，404视为不存在，其他非200响应视为错误
+type httpOrigin struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPOrigin(cfg OriginConfig) *httpOrigin {
+	timeout := time.Duration(cfg.Timeout)
+	if timeout == 0 {
+		timeout = defaultOriginTimeout
+	}
+	return &httpOrigin{url: cfg.URL, client: &http.Client{Timeout: timeout}}
+}
+
+func (o *httpOrigin) Get(key string) ([]byte, error) {
+	res, err := o.client.Get(strings.ReplaceAll(o.url, "{key}", url.PathEscape(key)))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", key, ccache.ErrNotFound)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("origin response status: %v", res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// sormOrigin 通过SORM查询数据库
+type sormOrigin struct {
+	engine *sorm.Engine
+	query  string
+}
+
+func newSORMOrigin(cfg OriginConfig) (ccache.Getter, func() error, error) {
+	if cfg.Query == "" {
+		return nil, nil, errors.New("sorm origin requires query")
+	}
+	engine, err := sorm.NewEngine(cfg.Driver, cfg.Source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &sormOrigin{engine: engine, query: cfg.Query}, engine.Close, nil
+}
+
+func (o *sormOrigin) Get(key string) ([]byte, error) {
+	var value []byte
+	err := o.engine.NewSession().Raw(o.query, key).QueryRow().Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: %w", key, ccache.ErrNotFound)
+	}
+	return value, err
+}
+
+// staticOrigin 固定的键值对
+type staticOrigin map[string]string
+
+func (o staticOrigin) Get(key string) ([]byte, error) {
+	if v, ok := o[key]; ok {
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("%s: %w", key, ccache.ErrNotFound)
+}
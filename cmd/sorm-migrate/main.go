@@ -0,0 +1,120 @@
+// sorm-migrate 执行目录中的SQL迁移文件
+//
+//	sorm-migrate -driver sqlite3 -dsn sorm.db -dir migrations [-dry-run] <command>
+//
+// 迁移文件命名为 <version>_<name>.up.sql 与 <version>_<name>.down.sql，command为:
+//
+//	up [version]    执行未执行的迁移，指定version时只执行到该版本
+//	down [steps]    回滚最近执行的steps个迁移，默认为1
+//	status          查看各迁移的执行状态
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sorm"
+	"sorm/logger"
+	"sorm/migrate"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func main() {
+	var (
+		driver, dsn, dir string
+		dryRun, verbose  bool
+	)
+	flag.StringVar(&driver, "driver", "sqlite3", "Database driver")
+	flag.StringVar(&dsn, "dsn", "sorm.db", "Data source name")
+	flag.StringVar(&dir, "dir", "migrations", "Directory of migration files")
+	flag.BoolVar(&dryRun, "dry-run", false, "Print SQL without executing")
+	flag.BoolVar(&verbose, "v", false, "Log executed SQL")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] up [version] | down [steps] | status\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if !verbose {
+		logger.SetLevel(logger.Errorlevel)
+	}
+
+	if err := run(driver, dsn, dir, dryRun, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(driver, dsn, dir string, dryRun bool, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("missing command")
+	}
+	migrations, err := migrate.LoadDir(dir)
+	if err != nil {
+		return err
+	}
+	engine, err := sorm.NewEngine(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	m := engine.Migrator()
+	if err := m.Register(migrations...); err != nil {
+		return err
+	}
+	if dryRun {
+		m.DryRun()
+	}
+
+	switch args[0] {
+	case "up":
+		var target int64
+		if len(args) > 1 {
+			if target, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return fmt.Errorf("invalid version %q", args[1])
+			}
+		}
+		err = m.Up(target)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 0 {
+				return fmt.Errorf("invalid steps %q", args[1])
+			}
+		}
+		err = m.Down(steps)
+	case "status":
+		return printStatus(m)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	for _, st := range m.Statements() {
+		fmt.Printf("%s %v\n", st.SQL, st.Vars)
+	}
+	return err
+}
+
+func printStatus(m *migrate.Migrator) error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, st := range status {
+		applied := "pending"
+		if st.Applied {
+			applied = st.AppliedAt.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", st.Version, st.Name, applied)
+	}
+	return w.Flush()
+}
@@ -5,11 +5,17 @@ go 1.17
 require (
 	ccache v0.0.0
 	github.com/mattn/go-sqlite3 v1.14.14
+	github.com/stretchr/testify v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 	sorm v0.0.0-00010101000000-000000000000
 	surpc v0.0.0
 )
 
-require google.golang.org/protobuf v1.28.0 // indirect
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	google.golang.org/protobuf v1.28.0 // indirect
+)
 
 replace ccache => ./CCache
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"ccache"
+	"ccache/gateway"
 	"context"
 	"flag"
 	"fmt"
@@ -27,26 +28,17 @@ var db = map[string]string{
 }
 
 func createGroup() *ccache.Group {
-	return ccache.NewGroup("source", 2<<10, ccache.GetterFunc(func(key string) ([]byte, error) {
+	return ccache.NewGroupWithOpts("source", 2<<10, ccache.GetterFunc(func(key string) ([]byte, error) {
 		if v, ok := db[key]; ok {
 			return []byte(v), nil
 		}
-		return []byte(fmt.Sprintf("%s not exists \n", key)), nil
-	}))
+		return nil, fmt.Errorf("%s: %w", key, ccache.ErrNotFound)
+	}), ccache.GroupOptions{NegativeTTL: time.Minute})
 }
 
 func startAPIServer(apiAddr string, group *ccache.Group) {
-	http.Handle("/api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := r.URL.Query().Get("key")
-		value, err := group.Get(key)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Write(value.ByteSlice())
-	}))
+	// GET /api/<key>
+	http.Handle("/api/", gateway.New(gateway.Route{Prefix: "/api/", Group: group, MaxAge: time.Minute}))
 	log.Println("fontend server is running at:", apiAddr)
 	log.Fatal(http.ListenAndServe(apiAddr, nil))
 }
@@ -84,17 +76,19 @@ func ccacheRun() {
 func ormRun() {
 	driver, source := "sqlite3", "sorm.db"
 	engine, err := sorm.NewEngine(driver, source)
-	defer engine.Close()
-
 	if err != nil {
 		logger.Error("failed to create engine")
+		return
 	}
+	defer engine.Close()
+
 	session := engine.NewSession()
 	session.DB().Exec("CREATE TABLE IF NOT EXISTS users(name text,age int);")
 	session.DB().Exec("INSERT INTO users VALUES(?,?),(?,?);", "John", 13, "Amy", 15)
 	rows, err := session.DB().Query("SELECT * FROM users;")
 	if err != nil {
 		logger.Error(err.Error())
+		return
 	}
 	fmt.Println(rows)
 }